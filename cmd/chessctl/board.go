@@ -0,0 +1,182 @@
+/*
+Printing positions and reading moves.
+*/
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/game_logic"
+	"github.com/matetirpak/chess-server-and-api-for-developers/pkg/client"
+)
+
+func boardState(state client.State) game_logic.BoardState {
+	return game_logic.BoardState{
+		Board:          state.Board,
+		WhiteKingPos:   state.WhiteKingPos,
+		BlackKingPos:   state.BlackKingPos,
+		WhiteKingMoved: state.WhiteKingMoved,
+		BlackKingMoved: state.BlackKingMoved,
+		Winner:         state.Winner,
+		TurnColor:      state.TurnColor,
+		EnPassant:      state.EnPassant,
+	}
+}
+
+// Prints the board with white pieces in upper case, from the side of
+// color.
+func printBoard(w io.Writer, board [8][8]rune, color string) {
+	rows := []int{0, 1, 2, 3, 4, 5, 6, 7}
+	files := "a b c d e f g h"
+	if color == "b" {
+		rows = []int{7, 6, 5, 4, 3, 2, 1, 0}
+		files = "h g f e d c b a"
+	}
+	fmt.Fprintln(w, "  +-----------------+")
+	for _, row := range rows {
+		fmt.Fprintf(w, "%d |", 8-row)
+		for i := range 8 {
+			col := i
+			if color == "b" {
+				col = 7 - i
+			}
+			fmt.Fprintf(w, " %c", pieceLetter(board[row][col]))
+		}
+		fmt.Fprintln(w, " |")
+	}
+	fmt.Fprintln(w, "  +-----------------+")
+	fmt.Fprintf(w, "    %s\n", files)
+}
+
+func pieceLetter(piece rune) rune {
+	switch {
+	case piece >= 'a' && piece <= 'z':
+		return game_logic.FENLetters[piece] - 'a' + 'A'
+	case piece >= 'A' && piece <= 'Z':
+		return game_logic.FENLetters[piece-'A'+'a']
+	}
+	return '.'
+}
+
+// Prints the turn or result, the clocks and the last move.
+func printStatus(w io.Writer, state client.State) {
+	// Earlier positions of finished games come with the final state
+	// but without a winner.
+	switch {
+	case state.State == "waiting":
+		fmt.Fprintln(w, "Waiting for players")
+	case state.State == "aborted" || (state.State == "finished" && state.Winner != "n"):
+		fmt.Fprintf(w, "Game %s: %s", state.State, result(state.Winner))
+		if state.Termination != "" {
+			fmt.Fprintf(w, " (%s)", state.Termination)
+		}
+		fmt.Fprintln(w)
+	default:
+		fmt.Fprintf(w, "%s to move\n", colorName(state.TurnColor))
+	}
+	if state.DrawOffer != "" {
+		fmt.Fprintf(w, "%s offers a draw\n", colorName(state.DrawOffer))
+	}
+	if state.Clock != nil {
+		fmt.Fprintf(w, "Clock: white %s, black %s\n",
+			formatClock(state.Clock.WhiteMs), formatClock(state.Clock.BlackMs))
+	}
+	if state.LastMove != nil {
+		fmt.Fprintf(w, "Last move: %s %s after %s\n", colorName(state.LastMove.Color),
+			state.LastMove.Move, time.Duration(state.LastMove.ThinkMs)*time.Millisecond)
+	}
+}
+
+func colorName(color string) string {
+	switch color {
+	case "w":
+		return "White"
+	case "b":
+		return "Black"
+	}
+	return color
+}
+
+// Returns the PGN result of a winner.
+func result(winner string) string {
+	switch winner {
+	case "w":
+		return "1-0"
+	case "b":
+		return "0-1"
+	case "r":
+		return "1/2-1/2"
+	}
+	return "*"
+}
+
+func formatClock(ms int64) string {
+	if ms < 0 {
+		ms = 0
+	}
+	return fmt.Sprintf("%d:%02d.%d", ms/60000, ms/1000%60, ms/100%10)
+}
+
+// Finds the legal move meant by input, written like "e2 e4", "e2e4",
+// "e2-e4" or in SAN like "Nf3", and returns it in the notation of the
+// API.
+func parseMove(state client.State, input string) (string, error) {
+	bstate := boardState(state)
+	input = strings.TrimSpace(input)
+	coordinates := strings.NewReplacer(" ", "", "-", "").Replace(strings.ToLower(input))
+	san := strings.TrimRight(input, "+#!?")
+	for _, move := range game_logic.LegalMoves(&bstate) {
+		notation := move.String()
+		if coordinates == strings.ReplaceAll(notation, " ", "") {
+			return notation, nil
+		}
+		moveSAN, err := game_logic.SAN(&move, &bstate)
+		if err == nil && strings.TrimRight(moveSAN, "+#") == san {
+			return notation, nil
+		}
+	}
+	return "", fmt.Errorf("%q is not a legal move", input)
+}
+
+// Returns the SAN of a move in the notation of the API, or the move
+// itself if it isn't legal in the position.
+func sanOf(state client.State, notation string) string {
+	if state.TurnColor == "" {
+		return notation
+	}
+	bstate := boardState(state)
+	move, err := game_logic.StringToMoveStruct(notation, rune(state.TurnColor[0]))
+	if err != nil {
+		return notation
+	}
+	san, err := game_logic.SAN(&move, &bstate)
+	if err != nil {
+		return notation
+	}
+	return san
+}
+
+// Converts moves in the notation of the API, played from the initial
+// position, to SAN.
+func sanMoves(records []client.MoveRecord) ([]string, error) {
+	var states []game_logic.BoardState
+	game_logic.InitializeBoard(&states)
+	bstate := states[0]
+	var sans []string
+	for i, record := range records {
+		move, err := game_logic.StringToMoveStruct(record.Move, rune(record.Color[0]))
+		if err != nil {
+			return nil, fmt.Errorf("move %d %q: %w", i+1, record.Move, err)
+		}
+		san, err := game_logic.SAN(&move, &bstate)
+		if err != nil {
+			return nil, fmt.Errorf("move %d %q: %w", i+1, record.Move, err)
+		}
+		sans = append(sans, san)
+		bstate = game_logic.MakeMove(&move, bstate)
+	}
+	return sans, nil
+}
@@ -0,0 +1,106 @@
+/*
+Tests of reading moves and converting them to SAN.
+*/
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/game_logic"
+	"github.com/matetirpak/chess-server-and-api-for-developers/pkg/client"
+)
+
+// Returns the state after moves in the notation of the API, played
+// from the initial position.
+func stateAfter(t *testing.T, moves ...string) client.State {
+	t.Helper()
+	var states []game_logic.BoardState
+	game_logic.InitializeBoard(&states)
+	bstate := states[0]
+	for _, moveStr := range moves {
+		move, err := game_logic.StringToMoveStruct(moveStr, rune(bstate.TurnColor[0]))
+		if err != nil {
+			t.Fatalf("fail in StringToMoveStruct %q: %v", moveStr, err)
+		}
+		if err := game_logic.ValidateMove(&move, &bstate); err != nil {
+			t.Fatalf("fail in ValidateMove %q: %v", moveStr, err)
+		}
+		bstate = game_logic.MakeMove(&move, bstate)
+	}
+	return client.State{
+		Board:          bstate.Board,
+		WhiteKingPos:   bstate.WhiteKingPos,
+		BlackKingPos:   bstate.BlackKingPos,
+		WhiteKingMoved: bstate.WhiteKingMoved,
+		BlackKingMoved: bstate.BlackKingMoved,
+		Winner:         bstate.Winner,
+		TurnColor:      bstate.TurnColor,
+		EnPassant:      bstate.EnPassant,
+		State:          "active",
+	}
+}
+
+func TestParseMove(t *testing.T) {
+	state := stateAfter(t, "e2 e4", "e7 e5")
+	for _, test := range []struct {
+		input    string
+		expected string
+	}{
+		{"g1 f3", "g1 f3"},
+		{"g1f3", "g1 f3"},
+		{"g1-f3", "g1 f3"},
+		{" G1F3 ", "g1 f3"},
+		{"Nf3", "g1 f3"},
+		{"Nf3+", "g1 f3"},
+		{"d4", "d2 d4"},
+		// A lowercase b is the file, an uppercase B the bishop.
+		{"b4", "b2 b4"},
+		{"Bb5", "f1 b5"},
+		{"Qh5!?", "d1 h5"},
+	} {
+		move, err := parseMove(state, test.input)
+		if err != nil || move != test.expected {
+			t.Errorf("%q: expected %q, got %q, %v", test.input, test.expected, move, err)
+		}
+	}
+	for _, input := range []string{"", "e4", "e2 e5", "bb5", "Nf6", "z9 z8"} {
+		if move, err := parseMove(state, input); err == nil {
+			t.Errorf("%q: expected an error, got %q", input, move)
+		}
+	}
+}
+
+func TestSANMoves(t *testing.T) {
+	// White captures its way to the last rank. The server doesn't
+	// promote pawns, so neither does the SAN.
+	moves := []string{"h2 h4", "g7 g5", "h4 g5", "g8 f6", "g5 f6", "h7 h6", "f6 e7", "a7 a6", "e7 d8"}
+	colors := []string{"w", "b"}
+	var records []client.MoveRecord
+	for i, move := range moves {
+		records = append(records, client.MoveRecord{Color: colors[i%2], Move: move})
+	}
+	sans, err := sanMoves(records)
+	if err != nil {
+		t.Fatalf("fail in sanMoves: %v", err)
+	}
+	expected := "h4 g5 hxg5 Nf6 gxf6 h6 fxe7 a6 exd8"
+	if got := strings.Join(sans, " "); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+
+	records[2].Move = "h4 h6"
+	if _, err := sanMoves(records); err == nil {
+		t.Errorf("expected an illegal move to be reported")
+	}
+}
+
+func TestSANOf(t *testing.T) {
+	state := stateAfter(t, "e2 e4", "e7 e5")
+	if san := sanOf(state, "g1 f3"); san != "Nf3" {
+		t.Errorf("expected Nf3, got %q", san)
+	}
+	if san := sanOf(state, "a1 a8"); san != "a1 a8" {
+		t.Errorf("expected an illegal move to be kept, got %q", san)
+	}
+}
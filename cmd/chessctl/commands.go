@@ -0,0 +1,418 @@
+/*
+Implementations of the commands.
+*/
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/matetirpak/chess-server-and-api-for-developers/pkg/client"
+)
+
+// Returns a context cancelled by Ctrl-C.
+func interruptible() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt)
+}
+
+func parseBoardID(arg string) (int32, error) {
+	id, err := strconv.ParseInt(arg, 10, 32)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid board ID %q", arg)
+	}
+	return int32(id), nil
+}
+
+func parseColor(arg string) (string, error) {
+	switch arg {
+	case "w", "white":
+		return "w", nil
+	case "b", "black":
+		return "b", nil
+	}
+	return "", fmt.Errorf("invalid color %q, enter w or b", arg)
+}
+
+// Returns the seat to use for a board: the saved token of color, or
+// of the only saved color if color is empty. The API key stands in
+// for a missing token.
+func (a *app) seat(boardID int32, color string) (client.Seat, error) {
+	var tokens map[string]string
+	if saved := a.store.get(a.server, boardID); saved != nil {
+		tokens = saved.Tokens
+	}
+	if color == "" {
+		switch {
+		case len(tokens) == 1:
+			for c := range tokens {
+				color = c
+			}
+		case len(tokens) > 1:
+			color = "w"
+		default:
+			return client.Seat{}, fmt.Errorf("no seat of board %d joined, run join first or pass -color with -apikey", boardID)
+		}
+	}
+	token := tokens[color]
+	if token == "" {
+		token = a.client.APIKey
+	}
+	if token == "" {
+		return client.Seat{}, fmt.Errorf("no token for %s on board %d, run join first", colorName(color), boardID)
+	}
+	return client.Seat{BoardID: boardID, Color: color, Token: token}, nil
+}
+
+// Returns the password given as flag, or the saved one.
+func (a *app) password(boardID int32, password string) string {
+	if password != "" {
+		return password
+	}
+	if saved := a.store.get(a.server, boardID); saved != nil {
+		return saved.Password
+	}
+	return ""
+}
+
+func runCreate(a *app, args []string) error {
+	fs := newFlagSet("create", "[flags]")
+	var options client.SessionOptions
+	fs.StringVar(&options.Name, "name", "", "name of the session")
+	base := fs.Duration("base", 0, "time of each player, no clock if 0")
+	inc := fs.Duration("inc", 0, "increment per move")
+	delay := fs.Duration("delay", 0, "delay per move")
+	fs.StringVar(&options.DelayMode, "delaymode", "", `"simple" or "bronstein"`)
+	moveTime := fs.Duration("movetime", 0, "time limit of a single move")
+	fs.StringVar(&options.OnMoveTimeout, "onmovetimeout", "", `"forfeit" or "random" when the move time runs out`)
+	if _, err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+	options.BaseMs = base.Milliseconds()
+	options.IncrementMs = inc.Milliseconds()
+	options.DelayMs = delay.Milliseconds()
+	options.MoveTimeMs = moveTime.Milliseconds()
+
+	ctx, cancel := interruptible()
+	defer cancel()
+	session, err := a.client.CreateSession(ctx, options)
+	if err != nil {
+		return err
+	}
+	a.store.session(a.server, session.BoardID).Password = session.Password
+	if err := a.store.save(); err != nil {
+		return err
+	}
+	fmt.Printf("Created board %d, password %s\n", session.BoardID, session.Password)
+	return nil
+}
+
+func runList(a *app, args []string) error {
+	fs := newFlagSet("list", "")
+	if _, err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+	ctx, cancel := interruptible()
+	defer cancel()
+	sessions, err := a.client.ListSessions(ctx)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "BOARD\tNAME\tSTATE\tWHITE\tBLACK\tCREATED")
+	for _, s := range sessions {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", s.BoardID, orDash(s.Name), s.State,
+			orDash(s.White), orDash(s.Black), s.Created.Local().Format(time.DateTime))
+	}
+	return tw.Flush()
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+func runDelete(a *app, args []string) error {
+	fs := newFlagSet("delete", "[-password p] board")
+	password := fs.String("password", "", "password of the session, the saved one if empty")
+	rest, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	boardID, err := parseBoardID(rest[0])
+	if err != nil {
+		return err
+	}
+	ctx, cancel := interruptible()
+	defer cancel()
+	if err := a.client.DeleteSession(ctx, boardID, a.password(boardID, *password)); err != nil {
+		return err
+	}
+	a.store.remove(a.server, boardID)
+	if err := a.store.save(); err != nil {
+		return err
+	}
+	fmt.Printf("Deleted board %d\n", boardID)
+	return nil
+}
+
+func runJoin(a *app, args []string) error {
+	fs := newFlagSet("join", "[-password p] board w|b")
+	password := fs.String("password", "", "password of the session, the saved one if empty")
+	rest, err := parseArgs(fs, args, 2, 2)
+	if err != nil {
+		return err
+	}
+	boardID, err := parseBoardID(rest[0])
+	if err != nil {
+		return err
+	}
+	color, err := parseColor(rest[1])
+	if err != nil {
+		return err
+	}
+	ctx, cancel := interruptible()
+	defer cancel()
+	pw := a.password(boardID, *password)
+	seat, err := a.client.JoinSession(ctx, boardID, pw, color)
+	if err != nil {
+		return err
+	}
+	saved := a.store.session(a.server, boardID)
+	saved.Password = pw
+	saved.Tokens[color] = seat.Token
+	if err := a.store.save(); err != nil {
+		return err
+	}
+	fmt.Printf("Joined board %d as %s\n", boardID, colorName(color))
+	return nil
+}
+
+func runShow(a *app, args []string) error {
+	fs := newFlagSet("show", "[-color c] [-move n] board")
+	colorFlag := fs.String("color", "", "seat to use, the saved one if empty")
+	moveidx := fs.Int("move", -1, "show the position after this many moves instead of the current one")
+	rest, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	boardID, err := parseBoardID(rest[0])
+	if err != nil {
+		return err
+	}
+	color := ""
+	if *colorFlag != "" {
+		if color, err = parseColor(*colorFlag); err != nil {
+			return err
+		}
+	}
+	seat, err := a.seat(boardID, color)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := interruptible()
+	defer cancel()
+	state, err := a.client.StateAt(ctx, seat, *moveidx)
+	if err != nil {
+		return err
+	}
+	printBoard(os.Stdout, state.Board, seat.Color)
+	printStatus(os.Stdout, state)
+	return nil
+}
+
+func runMove(a *app, args []string) error {
+	fs := newFlagSet("move", "[-color c] board move")
+	colorFlag := fs.String("color", "", "seat to move with, the one to move if both are saved")
+	rest, err := parseArgs(fs, args, 2, 3)
+	if err != nil {
+		return err
+	}
+	boardID, err := parseBoardID(rest[0])
+	if err != nil {
+		return err
+	}
+	input := rest[1]
+	if len(rest) == 3 {
+		// chessctl move 1 e2 e4
+		input += " " + rest[2]
+	}
+	color := ""
+	if *colorFlag != "" {
+		if color, err = parseColor(*colorFlag); err != nil {
+			return err
+		}
+	}
+	seat, err := a.seat(boardID, color)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := interruptible()
+	defer cancel()
+	state, err := a.client.State(ctx, seat)
+	if err != nil {
+		return err
+	}
+	if color == "" && state.TurnColor != seat.Color {
+		// Playing both sides, move with the one whose turn it is.
+		if other, err := a.seat(boardID, state.TurnColor); err == nil {
+			seat = other
+		}
+	}
+	if state.TurnColor != seat.Color {
+		return fmt.Errorf("it is not the turn of %s", colorName(seat.Color))
+	}
+	move, err := parseMove(state, input)
+	if err != nil {
+		return err
+	}
+	if err := a.client.Move(ctx, seat, move); err != nil {
+		return err
+	}
+	if state, err = a.client.State(ctx, seat); err != nil {
+		return err
+	}
+	printBoard(os.Stdout, state.Board, seat.Color)
+	printStatus(os.Stdout, state)
+	return nil
+}
+
+// Stops watching once the game is over.
+var errWatchDone = errors.New("done")
+
+func runWatch(a *app, args []string) error {
+	fs := newFlagSet("watch", "[-password p] board")
+	password := fs.String("password", "", "password of the session, the saved password or token if empty")
+	rest, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	boardID, err := parseBoardID(rest[0])
+	if err != nil {
+		return err
+	}
+	secret := a.password(boardID, *password)
+	side := "w"
+	if secret == "" {
+		seat, err := a.seat(boardID, "")
+		if err != nil {
+			return fmt.Errorf("no password of board %d, pass -password", boardID)
+		}
+		secret, side = seat.Token, seat.Color
+	}
+
+	ctx, cancel := interruptible()
+	defer cancel()
+	var position *client.State
+	err = a.client.Watch(ctx, boardID, secret, func(event client.Event) error {
+		switch event.Type {
+		case "move":
+			san := event.Move
+			if position != nil {
+				san = sanOf(*position, event.Move)
+			}
+			number := fmt.Sprintf("%d.", (event.Moveidx+1)/2)
+			if event.Color == "b" {
+				number += ".."
+			}
+			fmt.Printf("\n%s %s\n", number, san)
+		case "clock", "drawdeclined":
+			if event.Type == "drawdeclined" {
+				fmt.Printf("\n%s declines the draw\n", colorName(event.Color))
+			}
+			return nil
+		case "joined":
+			fmt.Printf("\n%s joined\n", colorName(event.Color))
+		default:
+			if event.Final() {
+				fmt.Printf("\nGame %s\n", event.Type)
+				return errWatchDone
+			}
+		}
+		if event.State == nil {
+			return nil
+		}
+		position = event.State
+		printBoard(os.Stdout, event.State.Board, side)
+		printStatus(os.Stdout, *event.State)
+		if event.Type == "gameover" || event.Type == "aborted" {
+			return errWatchDone
+		}
+		return nil
+	})
+	if errors.Is(err, errWatchDone) || errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+func runPGN(a *app, args []string) error {
+	fs := newFlagSet("pgn", "[-color c] [-o file] board")
+	colorFlag := fs.String("color", "", "seat to use, the saved one if empty")
+	out := fs.String("o", "", "file to write, standard output if empty")
+	rest, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	boardID, err := parseBoardID(rest[0])
+	if err != nil {
+		return err
+	}
+	color := ""
+	if *colorFlag != "" {
+		if color, err = parseColor(*colorFlag); err != nil {
+			return err
+		}
+	}
+	seat, err := a.seat(boardID, color)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := interruptible()
+	defer cancel()
+	records, err := a.client.History(ctx, seat)
+	if err != nil {
+		return err
+	}
+	state, err := a.client.State(ctx, seat)
+	if err != nil {
+		return err
+	}
+	sessions, err := a.client.ListSessions(ctx)
+	if err != nil {
+		return err
+	}
+	var info *client.SessionInfo
+	for i := range sessions {
+		if sessions[i].BoardID == boardID {
+			info = &sessions[i]
+		}
+	}
+	moves, err := sanMoves(records)
+	if err != nil {
+		return err
+	}
+	game := newPGNGame(a.server, info, state, moves)
+
+	if *out == "" {
+		return writePGN(os.Stdout, game)
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := writePGN(f, game); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,153 @@
+/*
+Command-line client of the chess server.
+
+	chessctl [-server url] [-apikey key] command [flags] [args]
+
+Commands:
+
+	create [-name n] [-base d] [-inc d] [-delay d] [-delaymode m] [-movetime d] [-onmovetimeout a]
+	list
+	delete [-password p] board
+	join [-password p] board w|b
+	show [-color c] [-move n] board
+	move [-color c] board move
+	watch [-password p] board
+	pgn [-color c] [-o file] board
+	play [-password p] [-nocolor] board [w|b]
+
+Passwords of created sessions and tokens of joined seats are saved in
+the config file, so later commands only need the board ID. Moves are
+accepted as "e2 e4", "e2e4" or in SAN like "Nf3". play opens a
+terminal UI for the seat, joining it first if needed. The server and API
+key default to $CHESS_SERVER and $CHESS_API_KEY.
+*/
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/matetirpak/chess-server-and-api-for-developers/pkg/client"
+)
+
+// Returned for wrong arguments, after the usage was printed.
+var errUsage = errors.New("invalid arguments")
+
+type command struct {
+	name string
+	args string
+	run  func(app *app, args []string) error
+}
+
+var commands = []command{
+	{"create", "[flags]", runCreate},
+	{"list", "", runList},
+	{"delete", "[-password p] board", runDelete},
+	{"join", "[-password p] board w|b", runJoin},
+	{"show", "[-color c] [-move n] board", runShow},
+	{"move", "[-color c] board move", runMove},
+	{"watch", "[-password p] board", runWatch},
+	{"pgn", "[-color c] [-o file] board", runPGN},
+	{"play", "[-password p] [-nocolor] board [w|b]", runPlay},
+}
+
+// State shared by the commands.
+type app struct {
+	server string
+	client *client.Client
+	store  *store
+}
+
+func main() {
+	flag.Usage = usage
+	server := flag.String("server", envOr("CHESS_SERVER", "http://localhost:8080"), "URL of the chess server")
+	apiKey := flag.String("apikey", os.Getenv("CHESS_API_KEY"), "API key joining sessions as its account")
+	config := flag.String("config", defaultConfig(), "file the passwords and tokens are saved in")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	st, err := openStore(*config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "chessctl:", err)
+		os.Exit(1)
+	}
+	c := client.New(*server)
+	c.APIKey = *apiKey
+	a := &app{server: c.BaseURL, client: c, store: st}
+
+	name := flag.Arg(0)
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		err := cmd.run(a, flag.Args()[1:])
+		if errors.Is(err, errUsage) {
+			os.Exit(2)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "chessctl:", err)
+			os.Exit(1)
+		}
+		return
+	}
+	fmt.Fprintf(os.Stderr, "chessctl: unknown command %q\n", name)
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintln(out, "Usage: chessctl [flags] command [flags] [args]")
+	fmt.Fprintln(out, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %s\n", strings.TrimSpace(cmd.name+" "+cmd.args))
+	}
+	fmt.Fprintln(out, "\nFlags:")
+	flag.PrintDefaults()
+}
+
+// Returns a flag set of a command that reports errors instead of
+// exiting.
+func newFlagSet(name string, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: chessctl %s %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// Parses the flags of a command and checks the number of remaining
+// arguments.
+func parseArgs(fs *flag.FlagSet, args []string, min int, max int) ([]string, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, errUsage
+	}
+	if fs.NArg() < min || fs.NArg() > max {
+		fs.Usage()
+		return nil, errUsage
+	}
+	return fs.Args(), nil
+}
+
+func envOr(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func defaultConfig() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ".chessctl.json"
+	}
+	return filepath.Join(dir, "chessctl", "sessions.json")
+}
@@ -0,0 +1,125 @@
+/*
+Export of games in the Portable Game Notation (PGN).
+*/
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/matetirpak/chess-server-and-api-for-developers/pkg/client"
+)
+
+// Tags and moves of a game.
+type pgnGame struct {
+	Event       string
+	Site        string
+	Date        time.Time
+	White       string
+	Black       string
+	Result      string
+	Termination string
+	Moves       []string // SAN
+}
+
+// Writes the game with the seven tags PGN requires, the termination
+// if known, and the moves wrapped at 80 columns.
+func writePGN(w io.Writer, game pgnGame) error {
+	date := "????.??.??"
+	if !game.Date.IsZero() {
+		date = game.Date.UTC().Format("2006.01.02")
+	}
+	tags := [][2]string{
+		{"Event", orUnknown(game.Event)},
+		{"Site", orUnknown(game.Site)},
+		{"Date", date},
+		{"Round", "-"},
+		{"White", orUnknown(game.White)},
+		{"Black", orUnknown(game.Black)},
+		{"Result", game.Result},
+	}
+	if game.Termination != "" {
+		tags = append(tags, [2]string{"Termination", game.Termination})
+	}
+
+	var sb strings.Builder
+	for _, tag := range tags {
+		fmt.Fprintf(&sb, "[%s \"%s\"]\n", tag[0], escapePGN(tag[1]))
+	}
+	sb.WriteByte('\n')
+
+	var tokens []string
+	for i, move := range game.Moves {
+		if i%2 == 0 {
+			tokens = append(tokens, fmt.Sprintf("%d.", i/2+1))
+		}
+		tokens = append(tokens, move)
+	}
+	tokens = append(tokens, game.Result)
+	line := 0
+	for i, token := range tokens {
+		if i > 0 && line+1+len(token) > 79 {
+			sb.WriteByte('\n')
+			line = 0
+		} else if i > 0 {
+			sb.WriteByte(' ')
+			line++
+		}
+		sb.WriteString(token)
+		line += len(token)
+	}
+	sb.WriteString("\n\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func orUnknown(value string) string {
+	if value == "" {
+		return "?"
+	}
+	return value
+}
+
+func escapePGN(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value)
+}
+
+// Returns the PGN termination of a finished game ended for reason.
+func termination(reason string) string {
+	switch reason {
+	case "timeout", "movetime":
+		return "time forfeit"
+	case "tablebase":
+		return "adjudication"
+	}
+	// Checkmate, stalemate, agreement and resignation.
+	return "normal"
+}
+
+// Collects the tags of a game from its session and final state.
+func newPGNGame(site string, info *client.SessionInfo, state client.State, moves []string) pgnGame {
+	game := pgnGame{
+		Site:   site,
+		Result: "*",
+		Moves:  moves,
+	}
+	if state.State == "finished" {
+		game.Result = result(state.Winner)
+		game.Termination = termination(state.Termination)
+	}
+	if state.State == "aborted" {
+		game.Termination = "abandoned"
+	}
+	if info != nil {
+		game.Event = info.Name
+		game.White = info.White
+		game.Black = info.Black
+		game.Date = info.Created
+		if info.Started != nil {
+			game.Date = *info.Started
+		}
+	}
+	return game
+}
@@ -0,0 +1,87 @@
+/*
+Tests of the PGN export.
+*/
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matetirpak/chess-server-and-api-for-developers/pkg/client"
+)
+
+func TestWritePGN(t *testing.T) {
+	var moves []string
+	for range 12 {
+		moves = append(moves, "Nf3", "Nf6", "Ng1", "Ng8")
+	}
+	game := pgnGame{
+		Event:       `Club "open" \ 2026`,
+		Site:        "http://localhost:8080",
+		Date:        time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC),
+		White:       "alice",
+		Result:      "1/2-1/2",
+		Termination: "normal",
+		Moves:       moves,
+	}
+	var sb strings.Builder
+	if err := writePGN(&sb, game); err != nil {
+		t.Fatalf("fail in writePGN: %v", err)
+	}
+	tags, movetext, _ := strings.Cut(sb.String(), "\n\n")
+
+	expected := strings.Join([]string{
+		`[Event "Club \"open\" \\ 2026"]`,
+		`[Site "http://localhost:8080"]`,
+		`[Date "2026.10.19"]`,
+		`[Round "-"]`,
+		`[White "alice"]`,
+		`[Black "?"]`,
+		`[Result "1/2-1/2"]`,
+		`[Termination "normal"]`,
+	}, "\n")
+	if tags != expected {
+		t.Errorf("expected the tags\n%s\ngot\n%s", expected, tags)
+	}
+	lines := strings.Split(strings.TrimRight(movetext, "\n"), "\n")
+	if len(lines) < 2 {
+		t.Fatalf("expected the moves to be wrapped, got %q", movetext)
+	}
+	for _, line := range lines {
+		if len(line) > 79 {
+			t.Errorf("line longer than 79 columns: %q", line)
+		}
+	}
+	if !strings.HasPrefix(lines[0], "1. Nf3 Nf6 2. Ng1 Ng8 ") || !strings.HasSuffix(lines[len(lines)-1], "24. Ng1 Ng8 1/2-1/2") {
+		t.Errorf("unexpected movetext %q", movetext)
+	}
+}
+
+func TestNewPGNGame(t *testing.T) {
+	started := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	info := &client.SessionInfo{Name: "final", White: "alice", Black: "bob", Created: started.Add(-time.Hour), Started: &started}
+	for _, test := range []struct {
+		state       client.State
+		result      string
+		termination string
+	}{
+		{client.State{State: "active", Winner: "n"}, "*", ""},
+		{client.State{State: "finished", Winner: "w", Termination: "checkmate"}, "1-0", "normal"},
+		{client.State{State: "finished", Winner: "b", Termination: "forfeit"}, "0-1", "normal"},
+		{client.State{State: "finished", Winner: "r", Termination: "agreement"}, "1/2-1/2", "normal"},
+		{client.State{State: "finished", Winner: "b", Termination: "timeout"}, "0-1", "time forfeit"},
+		{client.State{State: "finished", Winner: "w", Termination: "movetime"}, "1-0", "time forfeit"},
+		{client.State{State: "finished", Winner: "r", Termination: "tablebase"}, "1/2-1/2", "adjudication"},
+		{client.State{State: "aborted", Winner: "n", Termination: "inactivity"}, "*", "abandoned"},
+	} {
+		game := newPGNGame("site", info, test.state, nil)
+		if game.Result != test.result || game.Termination != test.termination {
+			t.Errorf("%s by %q: expected %s (%s), got %s (%s)", test.state.State, test.state.Termination,
+				test.result, test.termination, game.Result, game.Termination)
+		}
+		if game.Event != "final" || game.White != "alice" || game.Black != "bob" || !game.Date.Equal(started) {
+			t.Errorf("expected the tags of the session, got %+v", game)
+		}
+	}
+}
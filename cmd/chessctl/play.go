@@ -0,0 +1,515 @@
+/*
+Interactive terminal UI for playing a seat by hand, e.g. against a
+bot. The board is redrawn whenever something happens in the game and
+moves are read line by line.
+*/
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/game_logic"
+	"github.com/matetirpak/chess-server-and-api-for-developers/pkg/client"
+)
+
+// Unicode pieces by their lowercase letters on the board. Without
+// colors, white pieces are drawn hollow.
+var (
+	solidPieces  = map[rune]string{'p': "♟", 'r': "♜", 'k': "♞", 'b': "♝", 'q': "♛", 'x': "♚"}
+	hollowPieces = map[rune]string{'p': "♙", 'r': "♖", 'k': "♘", 'b': "♗", 'q': "♕", 'x': "♔"}
+)
+
+// 256-color backgrounds of the squares and foregrounds of the pieces.
+const (
+	lightSquare  = 180
+	darkSquare   = 137
+	hintSquare   = 107 // Targets of the selected piece.
+	recentSquare = 143 // Fields of the last move.
+	whitePiece   = 231
+	blackPiece   = 16
+)
+
+// Columns of the rank numbers and the fields.
+const boardWidth = 2 + 8*3
+
+const playHelp = "Moves like Nf3 or e2 e4. A field with a piece like g1 shows where it can go. " +
+	"Commands: draw, decline, resign, flip, help, quit."
+
+// What the UI shows.
+type view struct {
+	seat     client.Seat
+	state    client.State
+	received time.Time // When state.Clock was read.
+	moves    []string  // SAN
+	flipped  bool
+	color    bool
+	selected *[2]int // Field whose moves are hinted.
+	message  string
+}
+
+func runPlay(a *app, args []string) error {
+	fs := newFlagSet("play", "[-password p] [-nocolor] board [w|b]")
+	password := fs.String("password", "", "password of the session, the saved one if empty")
+	noColor := fs.Bool("nocolor", os.Getenv("NO_COLOR") != "", "draw without colors")
+	rest, err := parseArgs(fs, args, 1, 2)
+	if err != nil {
+		return err
+	}
+	boardID, err := parseBoardID(rest[0])
+	if err != nil {
+		return err
+	}
+	color := ""
+	if len(rest) == 2 {
+		if color, err = parseColor(rest[1]); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := interruptible()
+	defer cancel()
+	var seat client.Seat
+	if saved := a.store.get(a.server, boardID); color != "" && (saved == nil || saved.Tokens[color] == "") {
+		// Take the seat first.
+		pw := a.password(boardID, *password)
+		if seat, err = a.client.JoinSession(ctx, boardID, pw, color); err != nil {
+			return err
+		}
+		saved := a.store.session(a.server, boardID)
+		saved.Password = pw
+		saved.Tokens[color] = seat.Token
+		if err := a.store.save(); err != nil {
+			return err
+		}
+	} else if seat, err = a.seat(boardID, color); err != nil {
+		return err
+	}
+
+	v := &view{seat: seat, color: !*noColor}
+	if err := v.reload(ctx, a.client); err != nil {
+		return err
+	}
+
+	events := make(chan client.Event)
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- a.client.Watch(ctx, boardID, seat.Token, func(event client.Event) error {
+			select {
+			case events <- event:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	return v.run(ctx, a.client, events, watchErr, lines, ticker.C, os.Stdout)
+}
+
+// Draws the game to w until it ends or the player quits, handling
+// the events of the game, the lines of input and the ticks redrawing
+// the clocks.
+func (v *view) run(ctx context.Context, c *client.Client, events <-chan client.Event, watchErr <-chan error,
+	lines <-chan string, ticks <-chan time.Time, w io.Writer) error {
+	v.render(w, time.Now())
+	for {
+		select {
+		case event := <-events:
+			if err := v.apply(ctx, c, event); err != nil {
+				return err
+			}
+			if event.Final() {
+				v.message = fmt.Sprintf("The game was %s.", event.Type)
+				v.render(w, time.Now())
+				return nil
+			}
+		case err := <-watchErr:
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err == nil {
+				err = fmt.Errorf("the server closed the game feed")
+			}
+			return err
+		case line, ok := <-lines:
+			if !ok {
+				return nil
+			}
+			if quit := v.command(ctx, c, strings.TrimSpace(line)); quit {
+				return nil
+			}
+		case <-ticks:
+			// Redraws the clocks while the opponent thinks, not
+			// while the player may be typing.
+			if v.over() || v.myTurn() || v.state.Clock == nil || !v.state.Clock.Running {
+				continue
+			}
+		case <-ctx.Done():
+			return nil
+		}
+		v.render(w, time.Now())
+		if v.over() {
+			return nil
+		}
+	}
+}
+
+// Reads the state and the moves of the game.
+func (v *view) reload(ctx context.Context, c *client.Client) error {
+	records, err := c.History(ctx, v.seat)
+	if err != nil {
+		return err
+	}
+	state, err := c.State(ctx, v.seat)
+	if err != nil {
+		return err
+	}
+	moves, err := sanMoves(records)
+	if err != nil {
+		return err
+	}
+	v.state, v.received, v.moves = state, time.Now(), moves
+	return nil
+}
+
+func (v *view) apply(ctx context.Context, c *client.Client, event client.Event) error {
+	if event.Type == "clock" && event.Clock != nil {
+		v.state.Clock, v.received = event.Clock, time.Now()
+		return nil
+	}
+	if event.State == nil {
+		return nil
+	}
+	switch {
+	case event.Type == "move" && event.Moveidx == len(v.moves)+1:
+		v.moves = append(v.moves, sanOf(v.state, event.Move))
+		if event.Color != v.seat.Color {
+			v.message = fmt.Sprintf("%s played %s.", colorName(event.Color), v.moves[len(v.moves)-1])
+		}
+	case event.Moveidx != len(v.moves):
+		// Missed moves, e.g. while the feed was opened.
+		return v.reload(ctx, c)
+	case event.Type == "drawoffer" && event.Color != v.seat.Color:
+		v.message = fmt.Sprintf("%s offers a draw, type draw to accept or decline.", colorName(event.Color))
+	case event.Type == "drawdeclined" && event.Color != v.seat.Color:
+		v.message = "The draw offer was declined."
+	case event.Type == "joined" && event.Color != v.seat.Color:
+		v.message = fmt.Sprintf("%s joined.", colorName(event.Color))
+	}
+	v.state, v.received = *event.State, time.Now()
+	v.selected = nil
+	return nil
+}
+
+// Handles a line of input and reports whether to quit.
+func (v *view) command(ctx context.Context, c *client.Client, line string) bool {
+	v.message = ""
+	var err error
+	switch strings.ToLower(line) {
+	case "":
+		return false
+	case "quit", "exit", "q":
+		return true
+	case "help", "?":
+		v.message = playHelp
+		return false
+	case "flip":
+		v.flipped = !v.flipped
+		return false
+	case "resign":
+		err = c.Forfeit(ctx, v.seat)
+	case "draw":
+		err = c.Draw(ctx, v.seat, "offer")
+		if err == nil && v.state.DrawOffer == "" {
+			v.message = "Draw offered."
+		}
+	case "decline":
+		err = c.Draw(ctx, v.seat, "decline")
+	default:
+		// Pawn moves like e4 go to empty fields, so a field with a
+		// piece selects it.
+		if field, ok := parseSquare(line); ok && v.state.Board[field[0]][field[1]] != game_logic.Empty {
+			v.selected = &field
+			if len(v.targets()) == 0 {
+				v.message = fmt.Sprintf("No moves from %s.", line)
+			}
+			return false
+		}
+		if !v.myTurn() {
+			v.message = "It's not your turn."
+			return false
+		}
+		var move string
+		if move, err = parseMove(v.state, line); err == nil {
+			err = c.Move(ctx, v.seat, move)
+		}
+	}
+	if err != nil {
+		v.message = err.Error()
+	}
+	return false
+}
+
+func parseSquare(input string) ([2]int, bool) {
+	input = strings.ToLower(input)
+	if len(input) != 2 || input[0] < 'a' || input[0] > 'h' || input[1] < '1' || input[1] > '8' {
+		return [2]int{}, false
+	}
+	return [2]int{8 - int(input[1]-'0'), int(input[0] - 'a')}, true
+}
+
+func (v *view) myTurn() bool {
+	return v.state.State == "active" && v.state.TurnColor == v.seat.Color
+}
+
+func (v *view) over() bool {
+	return v.state.State == "finished" || v.state.State == "aborted"
+}
+
+// Returns the legal moves of the side to move.
+func (v *view) legalMoves() []game_logic.Move {
+	if v.state.State != "active" {
+		return nil
+	}
+	bstate := boardState(v.state)
+	return game_logic.LegalMoves(&bstate)
+}
+
+// Returns the fields the selected piece can move to.
+func (v *view) targets() map[[2]int]bool {
+	targets := make(map[[2]int]bool)
+	if v.selected == nil {
+		return targets
+	}
+	for _, move := range v.legalMoves() {
+		if move.From() == *v.selected {
+			targets[move.To()] = true
+		}
+	}
+	return targets
+}
+
+// Returns the time left of a color at now, counting down the running
+// clock.
+func (v *view) clock(color string, now time.Time) time.Duration {
+	clock := v.state.Clock
+	ms := clock.WhiteMs
+	if color == "b" {
+		ms = clock.BlackMs
+	}
+	left := time.Duration(ms) * time.Millisecond
+	if clock.Running && v.state.TurnColor == color && !v.over() {
+		left -= now.Sub(v.received)
+	}
+	return max(left, 0)
+}
+
+// Draws the board with the move list next to it, and the status,
+// hints and prompt below, with the clocks at now.
+func (v *view) render(w io.Writer, now time.Time) {
+	var board []string
+	board = append(board, v.playerLine(opponentColor(v.bottom()), now))
+	board = append(board, v.boardLines()...)
+	board = append(board, v.playerLine(v.bottom(), now))
+
+	var sb strings.Builder
+	sb.WriteString("\x1b[H\x1b[2J")
+	moveList := v.moveLines(len(board))
+	for i, line := range board {
+		sb.WriteString(line)
+		if i < len(moveList) {
+			sb.WriteString("    " + moveList[i])
+		}
+		sb.WriteByte('\n')
+	}
+	sb.WriteByte('\n')
+
+	switch {
+	case v.over():
+		fmt.Fprintf(&sb, "Game %s: %s", v.state.State, result(v.state.Winner))
+		if v.state.Termination != "" {
+			fmt.Fprintf(&sb, " (%s)", v.state.Termination)
+		}
+		sb.WriteByte('\n')
+	case v.state.State == "waiting":
+		sb.WriteString("Waiting for the opponent to join.\n")
+	case v.myTurn():
+		var hints []string
+		bstate := boardState(v.state)
+		for _, move := range v.legalMoves() {
+			if san, err := game_logic.SAN(&move, &bstate); err == nil {
+				hints = append(hints, san)
+			}
+		}
+		fmt.Fprintf(&sb, "Your move. Legal: %s\n", wrap(strings.Join(hints, " "), 72, "  "))
+	default:
+		fmt.Fprintf(&sb, "%s is thinking.\n", colorName(v.state.TurnColor))
+	}
+	if v.state.DrawOffer != "" && !v.over() {
+		fmt.Fprintf(&sb, "%s offers a draw.\n", colorName(v.state.DrawOffer))
+	}
+	if v.message != "" {
+		sb.WriteString(v.message + "\n")
+	}
+	if !v.over() {
+		sb.WriteString("> ")
+	}
+	io.WriteString(w, sb.String())
+}
+
+// Returns the color drawn at the bottom.
+func (v *view) bottom() string {
+	if v.flipped {
+		return opponentColor(v.seat.Color)
+	}
+	return v.seat.Color
+}
+
+func opponentColor(color string) string {
+	if color == "b" {
+		return "w"
+	}
+	return "b"
+}
+
+func (v *view) playerLine(color string, now time.Time) string {
+	line := "  " + colorName(color)
+	if color == v.seat.Color {
+		line += " (you)"
+	}
+	if v.state.Clock != nil {
+		line = fmt.Sprintf("%-16s %s", line, formatClock(v.clock(color, now).Milliseconds()))
+	}
+	if color == v.state.TurnColor && !v.over() {
+		line += " *"
+	}
+	return fmt.Sprintf("%-*s", boardWidth, line)
+}
+
+func (v *view) boardLines() []string {
+	targets := v.targets()
+	recent := make(map[[2]int]bool)
+	if last := v.state.LastMove; last != nil {
+		if from, ok := parseSquare(last.Move[:2]); ok {
+			recent[from] = true
+		}
+		if to, ok := parseSquare(last.Move[len(last.Move)-2:]); ok {
+			recent[to] = true
+		}
+	}
+
+	var lines []string
+	for i := range 8 {
+		row := i
+		if v.bottom() == "b" {
+			row = 7 - i
+		}
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "%d ", 8-row)
+		for j := range 8 {
+			col := j
+			if v.bottom() == "b" {
+				col = 7 - j
+			}
+			field := [2]int{row, col}
+			sb.WriteString(v.square(v.state.Board[row][col], field, targets[field], recent[field]))
+		}
+		lines = append(lines, sb.String())
+	}
+	files := "   a  b  c  d  e  f  g  h"
+	if v.bottom() == "b" {
+		files = "   h  g  f  e  d  c  b  a"
+	}
+	return append(lines, fmt.Sprintf("%-*s", boardWidth, files))
+}
+
+// Draws a field three columns wide.
+func (v *view) square(piece rune, field [2]int, target bool, recent bool) string {
+	glyph := " "
+	switch {
+	case piece >= 'a' && piece <= 'z':
+		glyph = hollowPieces[piece]
+		if v.color {
+			glyph = solidPieces[piece]
+		}
+	case piece >= 'A' && piece <= 'Z':
+		glyph = solidPieces[piece-'A'+'a']
+	}
+	if !v.color {
+		switch {
+		case target && glyph == " ":
+			glyph = "·"
+		case target:
+			return "(" + glyph + ")"
+		}
+		return " " + glyph + " "
+	}
+
+	background := lightSquare
+	if (field[0]+field[1])%2 == 1 {
+		background = darkSquare
+	}
+	if recent {
+		background = recentSquare
+	}
+	if target {
+		background = hintSquare
+	}
+	foreground := whitePiece
+	if piece >= 'A' && piece <= 'Z' {
+		foreground = blackPiece
+	}
+	return fmt.Sprintf("\x1b[48;5;%dm\x1b[38;5;%dm %s \x1b[0m", background, foreground, glyph)
+}
+
+// Returns the last moves that fit into height lines, a move pair per
+// line.
+func (v *view) moveLines(height int) []string {
+	var lines []string
+	for i := 0; i < len(v.moves); i += 2 {
+		line := fmt.Sprintf("%3d. %-8s", i/2+1, v.moves[i])
+		if i+1 < len(v.moves) {
+			line += v.moves[i+1]
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) > height {
+		lines = lines[len(lines)-height:]
+	}
+	return lines
+}
+
+// Breaks text into lines of at most width columns, indenting the
+// following lines.
+func wrap(text string, width int, indent string) string {
+	var sb strings.Builder
+	line := 0
+	for i, word := range strings.Fields(text) {
+		if i > 0 && line+1+len(word) > width {
+			sb.WriteString("\n" + indent)
+			line = len(indent)
+		} else if i > 0 {
+			sb.WriteByte(' ')
+			line++
+		}
+		sb.WriteString(word)
+		line += len(word)
+	}
+	return sb.String()
+}
@@ -0,0 +1,199 @@
+/*
+Tests of the board drawn by play and of the events and input it
+handles.
+*/
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matetirpak/chess-server-and-api-for-developers/pkg/client"
+)
+
+// Collects what every render wrote.
+type renders []string
+
+func (r *renders) Write(p []byte) (int, error) {
+	*r = append(*r, string(p))
+	return len(p), nil
+}
+
+func TestViewApply(t *testing.T) {
+	ctx := context.Background()
+	v := &view{seat: client.Seat{Color: "w"}, state: stateAfter(t), selected: &[2]int{7, 6}}
+
+	after := stateAfter(t, "e2 e4")
+	if err := v.apply(ctx, nil, client.Event{Type: "move", Color: "w", Move: "e2 e4", Moveidx: 1, State: &after}); err != nil {
+		t.Fatalf("fail in apply: %v", err)
+	}
+	if len(v.moves) != 1 || v.moves[0] != "e4" || v.state.TurnColor != "b" || v.selected != nil || v.message != "" {
+		t.Errorf("expected the own move to be recorded silently, got %+v", v)
+	}
+
+	after = stateAfter(t, "e2 e4", "e7 e5")
+	if err := v.apply(ctx, nil, client.Event{Type: "move", Color: "b", Move: "e7 e5", Moveidx: 2, State: &after}); err != nil {
+		t.Fatalf("fail in apply: %v", err)
+	}
+	if len(v.moves) != 2 || v.moves[1] != "e5" || v.message != "Black played e5." {
+		t.Errorf("expected the move of the opponent to be announced, got %q, %q", v.moves, v.message)
+	}
+
+	clock := &client.Clock{WhiteMs: 60000, BlackMs: 50000, Running: true}
+	v.apply(ctx, nil, client.Event{Type: "clock", Clock: clock})
+	if v.state.Clock != clock || v.state.TurnColor != "w" {
+		t.Errorf("expected only the clock to be updated, got %+v", v.state)
+	}
+
+	for _, test := range []struct {
+		event    client.Event
+		expected string
+	}{
+		{client.Event{Type: "drawoffer", Color: "b"}, "Black offers a draw, type draw to accept or decline."},
+		{client.Event{Type: "drawdeclined", Color: "b"}, "The draw offer was declined."},
+		{client.Event{Type: "joined", Color: "b"}, "Black joined."},
+		{client.Event{Type: "drawoffer", Color: "w"}, ""},
+	} {
+		v.message = ""
+		test.event.Moveidx, test.event.State = 2, &after
+		if err := v.apply(ctx, nil, test.event); err != nil {
+			t.Fatalf("fail in apply: %v", err)
+		}
+		if v.message != test.expected {
+			t.Errorf("%s by %s: expected %q, got %q", test.event.Type, test.event.Color, test.expected, v.message)
+		}
+	}
+}
+
+func TestBoardHints(t *testing.T) {
+	state := stateAfter(t, "e2 e4", "d7 d5")
+	state.LastMove = &client.MoveRecord{Color: "b", Move: "d7 d5"}
+	v := &view{seat: client.Seat{Color: "w"}, state: state}
+	if quit := v.command(context.Background(), nil, "e4"); quit || v.selected == nil || v.message != "" {
+		t.Fatalf("expected e4 to be selected, got %v, %q", v.selected, v.message)
+	}
+
+	// Rank 5 holds the targets e5 and d5, the latter also a field of
+	// the last move.
+	rank5 := v.boardLines()[3]
+	if !strings.Contains(rank5, "(♟)") || strings.Count(rank5, "·") != 1 {
+		t.Errorf("expected a capture and a move to be hinted, got %q", rank5)
+	}
+
+	v.color = true
+	lines := v.boardLines()
+	if strings.Count(lines[3], "48;5;107m") != 2 {
+		t.Errorf("expected two hinted fields, got %q", lines[3])
+	}
+	if strings.Count(lines[1], "48;5;143m") != 1 || strings.Contains(lines[3], "48;5;143m") {
+		t.Errorf("expected d7 to be drawn as part of the last move and d5 as a target, got %q, %q", lines[1], lines[3])
+	}
+
+	v.command(context.Background(), nil, "a1")
+	if v.message != "No moves from a1." || len(v.targets()) != 0 {
+		t.Errorf("expected a1 to have no moves, got %q", v.message)
+	}
+}
+
+func TestBoardFlipped(t *testing.T) {
+	for _, test := range []struct {
+		color   string
+		flipped bool
+		rank    string
+		files   string
+	}{
+		{"w", false, "8 ", "   a  b"},
+		{"w", true, "1 ", "   h  g"},
+		{"b", false, "1 ", "   h  g"},
+		{"b", true, "8 ", "   a  b"},
+	} {
+		v := &view{seat: client.Seat{Color: test.color}, state: stateAfter(t), flipped: test.flipped}
+		lines := v.boardLines()
+		if !strings.HasPrefix(lines[0], test.rank) || !strings.HasPrefix(lines[8], test.files) {
+			t.Errorf("%s flipped %v: expected rank %q and files %q first, got %q, %q",
+				test.color, test.flipped, test.rank, test.files, lines[0], lines[8])
+		}
+	}
+
+	v := &view{seat: client.Seat{Color: "w"}, state: stateAfter(t)}
+	v.command(context.Background(), nil, "flip")
+	if !v.flipped || v.bottom() != "b" {
+		t.Errorf("expected flip to turn the board")
+	}
+}
+
+func TestClockCountsDown(t *testing.T) {
+	received := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	state := stateAfter(t, "e2 e4")
+	state.Clock = &client.Clock{WhiteMs: 60000, BlackMs: 60000, Running: true}
+	v := &view{seat: client.Seat{Color: "w"}, state: state, received: received}
+
+	now := received.Add(10 * time.Second)
+	if left := v.clock("b", now); left != 50*time.Second {
+		t.Errorf("expected the clock of the side to move to run, got %s", left)
+	}
+	if left := v.clock("w", now); left != time.Minute {
+		t.Errorf("expected the other clock to stand, got %s", left)
+	}
+	if left := v.clock("b", received.Add(2*time.Minute)); left != 0 {
+		t.Errorf("expected the clock to stop at 0, got %s", left)
+	}
+	var out renders
+	v.render(&out, now)
+	if !strings.Contains(out[0], "Black") || !strings.Contains(out[0], "0:50.0 *") || !strings.Contains(out[0], "1:00.0") {
+		t.Errorf("expected the clocks to be drawn at now, got %q", out[0])
+	}
+
+	state.Clock.Running = false
+	if left := v.clock("b", now); left != time.Minute {
+		t.Errorf("expected a stopped clock to stand, got %s", left)
+	}
+}
+
+func TestViewRun(t *testing.T) {
+	state := stateAfter(t, "e2 e4")
+	state.Clock = &client.Clock{WhiteMs: 60000, BlackMs: 60000, Running: true}
+	v := &view{seat: client.Seat{Color: "w"}, state: state, received: time.Now(), moves: []string{"e4"}}
+
+	events := make(chan client.Event)
+	lines := make(chan string)
+	ticks := make(chan time.Time)
+	var out renders
+	done := make(chan error)
+	go func() {
+		done <- v.run(context.Background(), nil, events, nil, lines, ticks, &out)
+	}()
+
+	// The sends are received once the previous render is done.
+	ticks <- time.Now()
+	after := stateAfter(t, "e2 e4", "e7 e5")
+	after.Clock = state.Clock
+	events <- client.Event{Type: "move", Color: "b", Move: "e7 e5", Moveidx: 2, State: &after}
+	ticks <- time.Now()
+	lines <- "quit"
+	if err := <-done; err != nil {
+		t.Fatalf("fail in run: %v", err)
+	}
+
+	// The first render, the tick while black thinks and the move; the
+	// tick on the own turn draws nothing.
+	if len(out) != 3 {
+		t.Fatalf("expected 3 renders, got %d", len(out))
+	}
+	if !strings.Contains(out[1], "Black is thinking.") || !strings.Contains(out[2], "Black played e5.") {
+		t.Errorf("unexpected renders %q", out)
+	}
+
+	final := after
+	final.State = "finished"
+	v.state = after
+	go func() {
+		done <- v.run(context.Background(), nil, events, nil, lines, ticks, &out)
+	}()
+	events <- client.Event{Type: "archived", Moveidx: 2, State: &final}
+	if err := <-done; err != nil || !strings.Contains(out[len(out)-1], "The game was archived.") {
+		t.Errorf("expected the final event to end the game, got %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Passwords and tokens saved between invocations.
+*/
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+type savedSession struct {
+	Password string            `json:"password,omitempty"`
+	Tokens   map[string]string `json:"tokens,omitempty"` // By color.
+}
+
+type store struct {
+	path     string
+	Sessions map[string]*savedSession `json:"sessions"` // By server and board ID.
+}
+
+func openStore(path string) (*store, error) {
+	st := &store{path: path, Sessions: make(map[string]*savedSession)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if st.Sessions == nil {
+		st.Sessions = make(map[string]*savedSession)
+	}
+	return st, nil
+}
+
+// Writes the store, readable by the user only since it holds secrets.
+func (st *store) save() error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(st.path), 0o700); err != nil {
+		return err
+	}
+	tmp := st.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, st.path)
+}
+
+func sessionKey(server string, boardID int32) string {
+	return fmt.Sprintf("%s#%d", server, boardID)
+}
+
+// Returns the saved session, nil if there is none.
+func (st *store) get(server string, boardID int32) *savedSession {
+	return st.Sessions[sessionKey(server, boardID)]
+}
+
+func (st *store) session(server string, boardID int32) *savedSession {
+	key := sessionKey(server, boardID)
+	saved, exists := st.Sessions[key]
+	if !exists {
+		saved = &savedSession{Tokens: make(map[string]string)}
+		st.Sessions[key] = saved
+	}
+	if saved.Tokens == nil {
+		saved.Tokens = make(map[string]string)
+	}
+	return saved
+}
+
+func (st *store) remove(server string, boardID int32) {
+	delete(st.Sessions, sessionKey(server, boardID))
+}
@@ -0,0 +1,52 @@
+/*
+Tests of the saved passwords and tokens.
+*/
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chessctl", "sessions.json")
+	st, err := openStore(path)
+	if err != nil {
+		t.Fatalf("fail in openStore: %v", err)
+	}
+	if st.get("http://a", 1) != nil {
+		t.Errorf("expected an empty store")
+	}
+	st.session("http://a", 1).Password = "secret"
+	st.session("http://a", 1).Tokens["w"] = "white-token"
+	st.session("http://b", 1).Tokens["b"] = "black-token"
+	st.session("http://a", 2)
+	st.remove("http://a", 2)
+	if err := st.save(); err != nil {
+		t.Fatalf("fail in save: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("expected the file to be readable by the user only, got %v", info.Mode())
+	}
+
+	st, err = openStore(path)
+	if err != nil {
+		t.Fatalf("fail in openStore: %v", err)
+	}
+	if len(st.Sessions) != 2 {
+		t.Errorf("expected 2 sessions, got %d", len(st.Sessions))
+	}
+	saved := st.get("http://a", 1)
+	if saved == nil || saved.Password != "secret" || saved.Tokens["w"] != "white-token" {
+		t.Errorf("expected the session to be restored, got %+v", saved)
+	}
+	if saved := st.get("http://b", 1); saved == nil || saved.Password != "" || saved.Tokens["b"] != "black-token" {
+		t.Errorf("expected sessions to be kept apart by server, got %+v", saved)
+	}
+
+	os.WriteFile(path, []byte("{"), 0o600)
+	if _, err := openStore(path); err == nil {
+		t.Errorf("expected a broken file to be reported")
+	}
+}
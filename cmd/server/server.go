@@ -1,13 +1,92 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"net/http"
 
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/api"
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/tablebase"
 	"github.com/matetirpak/chess-server-and-api-for-developers/pkg/server"
 )
 
 func main() {
+	tablebases := flag.String("tablebases", "", "directory with endgame tables used for adjudication")
+	data := flag.String("data", "", "file the game journals are persisted in, next to the archive and the accounts; everything is kept in memory only if empty")
+	var expiry api.Expiry
+	flag.DurationVar(&expiry.Waiting, "waiting-expiry", 0, "delete games still waiting for players after this long, e.g. 30m; kept if 0")
+	flag.DurationVar(&expiry.Inactive, "inactive-expiry", 0, "abort active games without a move for this long, e.g. 1h; kept if 0")
+	flag.DurationVar(&expiry.Archive, "archive-after", 0, "archive finished and aborted games after this long, e.g. 24h; kept if 0")
+	flag.Float64Var(&api.RatingConfig.K, "elo-k", api.RatingConfig.K, "K-factor of Elo ratings")
+	flag.Float64Var(&api.RatingConfig.Tau, "glicko-tau", api.RatingConfig.Tau, "system constant of Glicko-2 ratings, between 0.3 and 1.2")
+	flag.Parse()
+
+	if *tablebases != "" {
+		set, err := tablebase.LoadDir(*tablebases)
+		if err != nil {
+			log.Fatal(err)
+		}
+		api.Tablebases = set
+		log.Printf("Loaded tablebases %v", set.Names())
+	}
+
+	if *data != "" {
+		store, err := db.OpenFileStore(*data)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer store.Close()
+		repo, err := db.OpenRepository(store)
+		if err != nil {
+			log.Fatal(err)
+		}
+		db.Games = repo
+		log.Printf("Loaded %d games from %s", len(repo.List()), *data)
+
+		archived, err := store.Archived()
+		if err != nil {
+			log.Fatal(err)
+		}
+		db.Results.AddArchived(archived)
+		db.Results.AddGames(repo.List())
+		log.Printf("Indexed %d results, %d games archived", len(db.Results.List()), len(archived))
+
+		accountStore, err := db.OpenFileAccountStore(db.AccountsPath(*data))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer accountStore.Close()
+		accounts, err := db.OpenAccountRepository(accountStore)
+		if err != nil {
+			log.Fatal(err)
+		}
+		db.Accounts = accounts
+		log.Printf("Loaded %d accounts", len(accounts.List()))
+
+		tournamentStore, err := db.OpenFileTournamentStore(db.TournamentsPath(*data))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer tournamentStore.Close()
+		tournaments, err := db.OpenTournamentRepository(tournamentStore)
+		if err != nil {
+			log.Fatal(err)
+		}
+		db.Tournaments = tournaments
+		log.Printf("Loaded %d tournaments", len(tournaments.List()))
+
+		// Resumed games can end right away, by a flag or a webhook,
+		// so everything they write to has to be loaded first.
+		api.ResumeGames()
+		api.ResumeTournaments()
+	}
+
+	if expiry != (api.Expiry{}) {
+		stopJanitor := api.StartJanitor(expiry)
+		defer stopJanitor()
+	}
+
 	log.Printf("Server started")
 
 	router := server.NewRouter()
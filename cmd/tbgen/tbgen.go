@@ -0,0 +1,64 @@
+/*
+Generates endgame tablebases by retrograde analysis.
+
+	tbgen [-out dir] [material ...]
+
+Materials list the white pieces first, e.g. KQK or KRKN. Tables of
+materials reached by captures are generated as well. Tables already
+present in the output directory are reused.
+*/
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/tablebase"
+)
+
+var defaultMaterials = []string{"KQK", "KRK", "KPK", "KBNK"}
+
+func main() {
+	out := flag.String("out", "tablebases", "directory the tables are written to")
+	flag.Parse()
+
+	materials := flag.Args()
+	if len(materials) == 0 {
+		materials = defaultMaterials
+	}
+
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		log.Fatal(err)
+	}
+	set, err := tablebase.LoadDir(*out)
+	if err != nil {
+		set = tablebase.NewSet()
+	}
+	existing := make(map[string]bool)
+	for _, name := range set.Names() {
+		existing[name] = true
+	}
+
+	for _, material := range materials {
+		start := time.Now()
+		table, err := set.Generate(material)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("%s ready after %s", table.Name(), time.Since(start))
+	}
+
+	for _, name := range set.Names() {
+		if existing[name] {
+			continue
+		}
+		path := filepath.Join(*out, tablebase.FileName(name))
+		if err := set.Table(name).Save(path); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Wrote %s", path)
+	}
+}
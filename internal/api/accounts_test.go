@@ -0,0 +1,148 @@
+/*
+Tests of accounts and games played with API keys.
+*/
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+)
+
+// Calls a handler with a JSON body and an API key as Bearer token.
+func doKey(handler http.HandlerFunc, method string, target string, key string, body interface{}) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	json.NewEncoder(&buf).Encode(body)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(method, target, &buf)
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	handler(rec, req)
+	return rec
+}
+
+func createAccount(t *testing.T, name string, kind string, ownerKey string) RespPostAccounts {
+	rec := doKey(PostAccounts, "POST", "/accounts", ownerKey, ReqPostAccounts{Name: name, Kind: kind})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("fail in PostAccounts: %s", rec.Body)
+	}
+	var resp RespPostAccounts
+	json.NewDecoder(rec.Body).Decode(&resp)
+	return resp
+}
+
+func TestBotAccounts(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+	db.Accounts = db.NewAccountRepository()
+
+	user := createAccount(t, "alice", db.AccountUser, "")
+	bot := createAccount(t, "alicebot", db.AccountBot, user.Key)
+	if bot.Account.Owner != "alice" {
+		t.Errorf("expected alice to own the bot, got %q", bot.Account.Owner)
+	}
+	if rec := doKey(PostAccounts, "POST", "/accounts", "", ReqPostAccounts{Name: "stray", Kind: db.AccountBot}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected bots without owner to be rejected, got %d", rec.Code)
+	}
+
+	// The bot joins with its key and plays without a seat token.
+	session := createGame(t)
+	rec := doKey(PutSessions, "PUT", "/sessions", bot.Key, ReqPutSessions{
+		BoardID:  session.BoardID,
+		Password: session.Password,
+		Color:    "w",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("fail in PutSessions: %s", rec.Body)
+	}
+	joinColor(session, "b")
+	rec = doKey(PutGame, "PUT", "/game", bot.Key, ReqPutGame{BoardID: session.BoardID, Color: "w", Move: "e2 e4"})
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected the bot to move with its key, got %d %s", rec.Code, rec.Body)
+	}
+	rec = doKey(PutGame, "PUT", "/game", bot.Key, ReqPutGame{BoardID: session.BoardID, Color: "b", Move: "e7 e5"})
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected the key to be rejected for the other seat, got %d", rec.Code)
+	}
+
+	rec = do(GetSessions, "GET", "/sessions", nil)
+	var sessions RespGetSessions
+	json.NewDecoder(rec.Body).Decode(&sessions)
+	if len(sessions.Games) != 1 || sessions.Games[0].White != "alicebot" || sessions.Games[0].Black != "" {
+		t.Errorf("expected the game to record the bot as white, got %+v", sessions.Games)
+	}
+
+	// The owner revokes the key of the bot.
+	rec = doKey(DeleteAccountKeys, "DELETE", "/accounts/keys", user.Key, ReqDeleteAccountKeys{
+		AccountID: bot.Account.AccountID,
+		KeyID:     bot.KeyID,
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("fail in DeleteAccountKeys: %s", rec.Body)
+	}
+	rec = doKey(PutGame, "PUT", "/game", bot.Key, ReqPutGame{BoardID: session.BoardID, Color: "w", Forfeit: true})
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected the revoked key to be rejected, got %d", rec.Code)
+	}
+	rec = doKey(PostAccountKeys, "POST", "/accounts/keys", bot.Key, ReqPostAccountKeys{AccountID: user.Account.AccountID})
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected a revoked key not to create keys, got %d", rec.Code)
+	}
+}
+
+func TestRatedGames(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+	db.Accounts = db.NewAccountRepository()
+	user := createAccount(t, "alice", db.AccountUser, "")
+	white := createAccount(t, "white", db.AccountBot, user.Key)
+	black := createAccount(t, "black", db.AccountBot, user.Key)
+
+	rec := do(PostSessions, "POST", "/sessions", ReqPostSessions{Name: "rated", BaseMs: 5 * 60 * 1000})
+	var session RespPostSessions
+	json.NewDecoder(rec.Body).Decode(&session)
+	for color, bot := range map[string]RespPostAccounts{"w": white, "b": black} {
+		rec := doKey(PutSessions, "PUT", "/sessions", bot.Key, ReqPutSessions{
+			BoardID:  session.BoardID,
+			Password: session.Password,
+			Color:    color,
+		})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("fail in PutSessions: %s", rec.Body)
+		}
+	}
+	rec = doKey(PutGame, "PUT", "/game", white.Key, ReqPutGame{BoardID: session.BoardID, Color: "w", Forfeit: true})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("fail in PutGame: %s", rec.Body)
+	}
+
+	rec = do(GetRating, "GET", fmt.Sprintf("/accounts/rating?accountid=%d", black.Account.AccountID), nil)
+	var ratings RespGetRating
+	json.NewDecoder(rec.Body).Decode(&ratings)
+	blitz, rated := ratings.Ratings["blitz"]
+	if !rated || blitz.Games != 1 || blitz.Elo != 1516 || len(ratings.Ratings) != 1 {
+		t.Errorf("expected black to gain 16 points in blitz, got %+v", ratings.Ratings)
+	}
+
+	rec = do(GetRatingHistory, "GET", fmt.Sprintf("/accounts/rating/history?accountid=%d&category=blitz", white.Account.AccountID), nil)
+	var history RespGetRatingHistory
+	json.NewDecoder(rec.Body).Decode(&history)
+	if len(history.Points) != 1 || history.Points[0].Opponent != "black" || history.Points[0].Score != 0 || history.Points[0].Rating.Elo != 1484 {
+		t.Errorf("expected the loss against black in the history, got %+v", history.Points)
+	}
+
+	target := fmt.Sprintf("/accounts/rating/predict?white=%d&black=%d&category=blitz", white.Account.AccountID, black.Account.AccountID)
+	rec = do(GetPrediction, "GET", target, nil)
+	var prediction RespGetPrediction
+	json.NewDecoder(rec.Body).Decode(&prediction)
+	if prediction.EloScore >= 0.5 || prediction.GlickoScore >= 0.5 {
+		t.Errorf("expected black to be favoured, got %+v", prediction)
+	}
+	if rec = do(GetPrediction, "GET", target[:len(target)-len("blitz")]+"hyper", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected an invalid category to be rejected, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Every game is owned by one goroutine, its actor. Handlers and timers
+send the actor commands (join, move, forfeit, draw offers, clock
+ticks) and wait for the reply. The actor applies them one after the
+other and publishes the resulting events, so all changes of a game
+happen in a well-defined order.
+
+The actor takes game.Mu while applying a command, readers only need
+the read lock. Actors are started on the first command and stop
+after a while without commands or once the game is deleted.
+*/
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+)
+
+// Time without commands after which an actor stops.
+const actorIdleTimeout = 2 * time.Minute
+
+// Command applied by the actor of a game. Returns the HTTP status
+// and an error if the command can't be carried out.
+type command struct {
+	fn    func(game *db.Game) (int, error)
+	reply chan commandResult
+}
+
+type commandResult struct {
+	status int
+	err    error
+}
+
+type actor struct {
+	game     *db.Game
+	commands chan command
+	done     chan struct{} // Closed once the actor stopped.
+}
+
+var actors = struct {
+	sync.Mutex
+	m map[*db.Game]*actor
+}{m: make(map[*db.Game]*actor)}
+
+// Returns the running actor of a game, starting it if necessary.
+func actorOf(game *db.Game) *actor {
+	actors.Lock()
+	defer actors.Unlock()
+	a, exists := actors.m[game]
+	if !exists {
+		a = &actor{
+			game:     game,
+			commands: make(chan command),
+			done:     make(chan struct{}),
+		}
+		actors.m[game] = a
+		go a.run()
+	}
+	return a
+}
+
+func (a *actor) run() {
+	idle := time.NewTimer(actorIdleTimeout)
+	defer idle.Stop()
+	for {
+		select {
+		case cmd := <-a.commands:
+			status, err := a.apply(cmd)
+			cmd.reply <- commandResult{status, err}
+			if a.deleted() {
+				a.stop()
+				return
+			}
+			idle.Reset(actorIdleTimeout)
+		case <-idle.C:
+			a.stop()
+			return
+		}
+	}
+}
+
+func (a *actor) apply(cmd command) (int, error) {
+	a.game.Mu.Lock()
+	defer a.game.Mu.Unlock()
+	if a.game.Deleted {
+		return http.StatusNotFound, errors.New("Board not found")
+	}
+	status, err := cmd.fn(a.game)
+	// Failed commands can change the game too, e.g. by a fallen flag.
+	if err := db.Games.Save(a.game); err != nil {
+		log.Printf("Failed to store game %d: %v", a.game.ID, err)
+	}
+	return status, err
+}
+
+func (a *actor) deleted() bool {
+	a.game.Mu.RLock()
+	defer a.game.Mu.RUnlock()
+	return a.game.Deleted
+}
+
+// Unregisters the actor. Senders still holding it notice by done
+// and start a new one.
+func (a *actor) stop() {
+	actors.Lock()
+	if actors.m[a.game] == a {
+		delete(actors.m, a.game)
+	}
+	actors.Unlock()
+	close(a.done)
+}
+
+// Sends a command to the actor of a game and waits for the reply.
+// Commands for deleted games fail with 404.
+func sendCommand(game *db.Game, fn func(game *db.Game) (int, error)) (int, error) {
+	cmd := command{fn: fn, reply: make(chan commandResult, 1)}
+	for {
+		a := actorOf(game)
+		select {
+		case a.commands <- cmd:
+			result := <-cmd.reply
+			return result.status, result.err
+		case <-a.done:
+			// Stopped in the meantime, try again with a new one.
+		}
+	}
+}
@@ -0,0 +1,100 @@
+/*
+Tests of the game actors and draw offers.
+*/
+package api
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+)
+
+func TestCommandsAreSerialised(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+	game, _ := initializeNewGame("actor")
+	db.Games.Create(game)
+
+	const n = 200
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sendCommand(game, func(game *db.Game) (int, error) {
+				game.Moves = append(game.Moves, db.MoveRecord{})
+				return http.StatusOK, nil
+			})
+		}()
+	}
+	wg.Wait()
+
+	game.Mu.RLock()
+	defer game.Mu.RUnlock()
+	if len(game.Moves) != n {
+		t.Errorf("expected %d commands to be applied, got %d", n, len(game.Moves))
+	}
+}
+
+func TestCommandsForDeletedGame(t *testing.T) {
+	game, _ := initializeNewGame("deleted")
+	game.Deleted = true
+	status, err := sendCommand(game, func(game *db.Game) (int, error) {
+		t.Errorf("command ran on a deleted game")
+		return http.StatusOK, nil
+	})
+	if status != http.StatusNotFound || err == nil {
+		t.Errorf("expected 404, got %d (%v)", status, err)
+	}
+}
+
+func TestDrawOffer(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+	session := createGame(t)
+	tokens := map[string]string{}
+	for _, color := range []string{"w", "b"} {
+		tokens[color], _ = joinColor(session, color)
+	}
+	put := func(color string, move string, draw string) int {
+		return do(PutGame, "PUT", "/game", ReqPutGame{
+			BoardID:  session.BoardID,
+			Password: session.Password,
+			Color:    color,
+			Token:    tokens[color],
+			Move:     move,
+			Draw:     draw,
+		}).Code
+	}
+	game, _ := db.Games.Get(session.BoardID)
+
+	// Offer with a move, declined by moving.
+	if code := put("w", "e2 e4", DrawOffer); code != http.StatusOK {
+		t.Fatalf("offer with move failed with %d", code)
+	}
+	if code := put("w", "", DrawOffer); code != http.StatusBadRequest {
+		t.Errorf("expected a repeated offer to fail, got %d", code)
+	}
+	put("b", "e7 e5", "")
+	if game.DrawOffer != "" {
+		t.Errorf("expected the offer to be declined by the move")
+	}
+
+	// Explicit decline.
+	put("w", "", DrawOffer)
+	if code := put("b", "", DrawDecline); code != http.StatusOK {
+		t.Errorf("decline failed with %d", code)
+	}
+	if code := put("b", "", DrawAccept); code != http.StatusBadRequest {
+		t.Errorf("expected accepting a declined offer to fail, got %d", code)
+	}
+
+	// Accept.
+	put("b", "", DrawOffer)
+	if code := put("w", "", DrawAccept); code != http.StatusOK {
+		t.Fatalf("accept failed with %d", code)
+	}
+	if game.Winner != "r" || game.Termination != "agreement" {
+		t.Errorf("expected a draw by agreement, got %q %q", game.Winner, game.Termination)
+	}
+}
@@ -0,0 +1,91 @@
+/*
+Tests of credentials sent as Authorization headers.
+*/
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+)
+
+// Calls a handler with an Authorization header.
+func doBearer(handler http.HandlerFunc, target string, secret string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", target, nil)
+	req.Header.Set("Authorization", "Bearer "+secret)
+	handler(rec, req)
+	return rec
+}
+
+func TestBearerCredentials(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+	session := createGame(t)
+	white, _ := joinColor(session, "w")
+	black, _ := joinColor(session, "b")
+
+	target := fmt.Sprintf("/game/history?boardid=%d&color=w", session.BoardID)
+	if rec := doBearer(GetHistory, target, white); rec.Code != http.StatusOK {
+		t.Errorf("expected the token of white to be accepted, got %d %s", rec.Code, rec.Body)
+	}
+	for name, secret := range map[string]string{
+		"token of the opponent": black,
+		"password":              session.Password,
+		"unknown token":         "guess",
+		"no token":              "",
+	} {
+		if rec := doBearer(GetHistory, target, secret); rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected 401, got %d", name, rec.Code)
+		}
+	}
+
+	// The password grants access to the game, e.g. for spectators.
+	game, _ := db.Games.Get(session.BoardID)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/game/events", nil)
+	req.Header.Set("Authorization", "Bearer "+session.Password)
+	if _, ok := verifyGameAccess(rec, req, session.BoardID, ""); !ok {
+		t.Errorf("expected the password to be accepted, got %d", rec.Code)
+	}
+	if game.PasswordHash == session.Password || !game.CheckPassword(session.Password) {
+		t.Errorf("expected only the hash of the password to be kept")
+	}
+}
+
+func TestPlayersCantChangeSessions(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+	db.Accounts = db.NewAccountRepository()
+	user := createAccount(t, "carol", db.AccountUser, "")
+	session := createGame(t)
+	white, _ := joinColor(session, "w")
+	rec := doKey(PutSessions, "PUT", "/sessions", user.Key, ReqPutSessions{
+		BoardID:  session.BoardID,
+		Password: session.Password,
+		Color:    "b",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("fail in PutSessions: %s", rec.Body)
+	}
+
+	for name, secret := range map[string]string{
+		"token of white":   white,
+		"API key of black": user.Key,
+		"unknown token":    "guess",
+	} {
+		rec := doKey(DeleteSessions, "DELETE", "/sessions", secret, ReqDeleteSessions{BoardID: session.BoardID})
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected 401 from DeleteSessions, got %d", name, rec.Code)
+		}
+	}
+	if _, err := db.Games.Get(session.BoardID); err != nil {
+		t.Fatalf("expected the game to survive, got %v", err)
+	}
+
+	rec = doKey(DeleteSessions, "DELETE", "/sessions", session.Password, ReqDeleteSessions{BoardID: session.BoardID})
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected the password to delete the game, got %d %s", rec.Code, rec.Body)
+	}
+}
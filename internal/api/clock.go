@@ -0,0 +1,180 @@
+/*
+Chess clocks. The time left of both players is kept on the game
+and charged whenever the player to move makes a move.
+*/
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/game_logic"
+)
+
+// Builds a time control from the values of a session request.
+// Returns nil if the game is played without clocks.
+func newTimeControl(req ReqPostSessions) (*db.TimeControl, error) {
+	if req.BaseMs == 0 && req.IncrementMs == 0 && req.DelayMs == 0 {
+		return nil, nil
+	}
+	if req.BaseMs <= 0 || req.IncrementMs < 0 || req.DelayMs < 0 {
+		return nil, errors.New("'basems' has to be positive, 'incrementms' and 'delayms' can't be negative")
+	}
+	tc := &db.TimeControl{
+		Base:      time.Duration(req.BaseMs) * time.Millisecond,
+		Increment: time.Duration(req.IncrementMs) * time.Millisecond,
+		Delay:     time.Duration(req.DelayMs) * time.Millisecond,
+	}
+	switch req.DelayMode {
+	case "":
+		if tc.Delay > 0 {
+			return nil, errors.New("'delaymode' is required with 'delayms'. Enter 'simple' or 'bronstein'")
+		}
+	case "simple", "bronstein":
+		tc.DelayMode = req.DelayMode
+	default:
+		return nil, errors.New("invalid 'delaymode'. Enter 'simple' or 'bronstein'")
+	}
+	return tc, nil
+}
+
+// Returns the color of the player to move, 'n' once the game is over.
+func currentTurn(game *db.Game) string {
+	return game.Position().TurnColor
+}
+
+// Returns the time a player has left at the given moment.
+func timeLeft(game *db.Game, color string, now time.Time) time.Duration {
+	left := game.W_timeLeft
+	if color == "b" {
+		left = game.B_timeLeft
+	}
+	if game.State == db.StateActive && currentTurn(game) == color {
+		elapsed := now.Sub(game.TurnStart)
+		if game.TimeControl.DelayMode == "simple" {
+			// The clock only starts running after the delay.
+			elapsed -= game.TimeControl.Delay
+			if elapsed < 0 {
+				elapsed = 0
+			}
+		}
+		left -= elapsed
+	}
+	if left < 0 {
+		return 0
+	}
+	return left
+}
+
+// Starts the clocks once both players have joined.
+func startClock(game *db.Game) {
+	if game.TimeControl == nil {
+		return
+	}
+	game.W_timeLeft = game.TimeControl.Base
+	game.B_timeLeft = game.TimeControl.Base
+	scheduleFlag(game)
+}
+
+// Charges the thinking time of the player who moved.
+// Returns false without changes if the flag has fallen.
+func punchClock(game *db.Game, color string, now time.Time) bool {
+	if game.TimeControl == nil {
+		return true
+	}
+	left := timeLeft(game, color, now)
+	if left <= 0 {
+		return false
+	}
+	if game.TimeControl.DelayMode == "bronstein" {
+		// Returns the used time, but at most the delay.
+		used := now.Sub(game.TurnStart)
+		if used > game.TimeControl.Delay {
+			used = game.TimeControl.Delay
+		}
+		left += used
+	}
+	left += game.TimeControl.Increment
+
+	if color == "w" {
+		game.W_timeLeft = left
+	} else {
+		game.B_timeLeft = left
+	}
+	return true
+}
+
+// Charges the running time of the player to move, used when the
+// game ends.
+func stopClock(game *db.Game, now time.Time) {
+	if game.TimeControl == nil || game.State != db.StateActive {
+		return
+	}
+	if currentTurn(game) == "w" {
+		game.W_timeLeft = timeLeft(game, "w", now)
+	} else {
+		game.B_timeLeft = timeLeft(game, "b", now)
+	}
+}
+
+// Ends the game if the player to move has run out of time.
+// The game is drawn if the opponent can't checkmate anymore.
+func checkFlag(game *db.Game, now time.Time) bool {
+	if game.TimeControl == nil || game.State != db.StateActive {
+		return false
+	}
+	color := currentTurn(game)
+	if timeLeft(game, color, now) > 0 {
+		return false
+	}
+	winner := opponent(color)
+	if game_logic.InsufficientMaterial(rune(winner[0]), game.Position()) {
+		winner = "r"
+	}
+	endGame(game, winner, "timeout")
+	return true
+}
+
+// Sets a timer that ends the game when the flag of the player to
+// move falls. Replaces the timer of the previous turn.
+func scheduleFlag(game *db.Game) {
+	if game.ClockTimer != nil {
+		game.ClockTimer.Stop()
+		game.ClockTimer = nil
+	}
+	if game.TimeControl == nil || game.State != db.StateActive {
+		return
+	}
+	color := currentTurn(game)
+	wait := timeLeft(game, color, time.Now())
+	if game.TimeControl.DelayMode == "simple" {
+		wait += game.TimeControl.Delay
+	}
+	ply := game.Plies()
+	game.ClockTimer = time.AfterFunc(wait, func() {
+		sendCommand(game, func(game *db.Game) (int, error) {
+			if game.Plies() == ply && !checkFlag(game, time.Now()) {
+				// Woke up too early.
+				scheduleFlag(game)
+			}
+			return http.StatusOK, nil
+		})
+	})
+}
+
+// Returns the clock shown in state responses, nil without time control.
+func clockState(game *db.Game, now time.Time) *RespClock {
+	if game.TimeControl == nil {
+		return nil
+	}
+	return &RespClock{
+		WhiteMs:     timeLeft(game, "w", now).Milliseconds(),
+		BlackMs:     timeLeft(game, "b", now).Milliseconds(),
+		IncrementMs: game.TimeControl.Increment.Milliseconds(),
+		DelayMs:     game.TimeControl.Delay.Milliseconds(),
+		DelayMode:   game.TimeControl.DelayMode,
+		Running:     game.State == db.StateActive,
+	}
+}
@@ -0,0 +1,158 @@
+/*
+Tests of the chess clocks, driven with fixed times.
+*/
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/game_logic"
+)
+
+// Starts a game with the time limits of req. Both colors are joined,
+// white is to move.
+func timedGame(t *testing.T, req ReqPostSessions) *db.Game {
+	t.Helper()
+	rec := do(PostSessions, "POST", "/sessions", req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("fail in PostSessions: %s", rec.Body)
+	}
+	var session RespPostSessions
+	json.NewDecoder(rec.Body).Decode(&session)
+	joinColor(session, "w")
+	joinColor(session, "b")
+	game, err := db.Games.Get(session.BoardID)
+	if err != nil {
+		t.Fatalf("fail in Get: %v", err)
+	}
+	t.Cleanup(func() {
+		game.Mu.Lock()
+		stopTimers(game)
+		game.Mu.Unlock()
+	})
+	return game
+}
+
+func TestClockIncrement(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+	game := timedGame(t, ReqPostSessions{BaseMs: 60000, IncrementMs: 2000})
+	game.Mu.Lock()
+	defer game.Mu.Unlock()
+	start := game.TurnStart
+
+	if left := timeLeft(game, "w", start.Add(5*time.Second)); left != 55*time.Second {
+		t.Errorf("expected 55s running for white, got %s", left)
+	}
+	if left := timeLeft(game, "b", start.Add(5*time.Second)); left != time.Minute {
+		t.Errorf("expected the clock of black to stand, got %s", left)
+	}
+	if !punchClock(game, "w", start.Add(5*time.Second)) {
+		t.Fatalf("expected the flag to stand")
+	}
+	if game.W_timeLeft != 57*time.Second {
+		t.Errorf("expected 55s plus the increment, got %s", game.W_timeLeft)
+	}
+}
+
+func TestClockDelay(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+	for _, test := range []struct {
+		mode     string
+		used     time.Duration
+		expected time.Duration
+	}{
+		// Simple delay: the clock starts after the delay.
+		{"simple", 2 * time.Second, time.Minute},
+		{"simple", 5 * time.Second, 58 * time.Second},
+		// Bronstein delay: the used time is returned, up to the delay.
+		{"bronstein", 2 * time.Second, time.Minute},
+		{"bronstein", 5 * time.Second, 58 * time.Second},
+	} {
+		game := timedGame(t, ReqPostSessions{BaseMs: 60000, DelayMs: 3000, DelayMode: test.mode})
+		game.Mu.Lock()
+		now := game.TurnStart.Add(test.used)
+		if test.mode == "simple" {
+			if left := timeLeft(game, "w", now); left != test.expected {
+				t.Errorf("simple delay after %s: expected %s shown, got %s", test.used, test.expected, left)
+			}
+		}
+		if !punchClock(game, "w", now) {
+			t.Errorf("%s delay after %s: expected the flag to stand", test.mode, test.used)
+		}
+		if game.W_timeLeft != test.expected {
+			t.Errorf("%s delay after %s: expected %s left, got %s", test.mode, test.used, test.expected, game.W_timeLeft)
+		}
+		game.Mu.Unlock()
+	}
+}
+
+func TestFlagFall(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+	game := timedGame(t, ReqPostSessions{BaseMs: 60000})
+	game.Mu.Lock()
+	defer game.Mu.Unlock()
+	start := game.TurnStart
+
+	if checkFlag(game, start.Add(30*time.Second)) {
+		t.Fatalf("expected the flag to stand after 30s")
+	}
+	if punchClock(game, "w", start.Add(61*time.Second)) {
+		t.Errorf("expected no move to be charged after the flag fell")
+	}
+	if game.W_timeLeft != time.Minute {
+		t.Errorf("expected the clock to be unchanged, got %s", game.W_timeLeft)
+	}
+	if !checkFlag(game, start.Add(61*time.Second)) {
+		t.Fatalf("expected the flag to fall after 61s")
+	}
+	if game.State != db.StateFinished || game.Winner != "b" || game.Termination != "timeout" {
+		t.Errorf("expected black to win on time, got %q %q %q", game.State, game.Winner, game.Termination)
+	}
+}
+
+func TestFlagFallInsufficientMaterial(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+	game := timedGame(t, ReqPostSessions{BaseMs: 60000})
+	game.Mu.Lock()
+	defer game.Mu.Unlock()
+
+	// Black has only king and knight left and can't checkmate.
+	position := game.Position()
+	for row := range position.Board {
+		for col := range position.Board[row] {
+			position.Board[row][col] = game_logic.Empty
+		}
+	}
+	position.Board[0][4] = 'X'
+	position.Board[0][6] = 'K'
+	position.Board[7][4] = 'x'
+	position.BlackKingPos = [2]int{0, 4}
+	position.WhiteKingPos = [2]int{7, 4}
+
+	if !checkFlag(game, game.TurnStart.Add(61*time.Second)) {
+		t.Fatalf("expected the flag to fall")
+	}
+	if game.Winner != "r" || game.Termination != "timeout" {
+		t.Errorf("expected a draw on time, got %q %q", game.Winner, game.Termination)
+	}
+}
+
+func TestFlagFallNoticedByOpponent(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+	game := timedGame(t, ReqPostSessions{BaseMs: 60000})
+	game.Mu.Lock()
+	defer game.Mu.Unlock()
+	game.TurnStart = time.Now().Add(-61 * time.Second)
+
+	code, err := playTurn(game, "b", "e7 e5", false)
+	if code != http.StatusBadRequest || err == nil || err.Error() != "Can't apply move. Your opponent ran out of time." {
+		t.Errorf("expected black to be told about the flag of white, got %d %v", code, err)
+	}
+	if game.State != db.StateFinished || game.Winner != "b" || game.Termination != "timeout" {
+		t.Errorf("expected black to win on time, got %q %q %q", game.State, game.Winner, game.Termination)
+	}
+}
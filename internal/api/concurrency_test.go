@@ -0,0 +1,180 @@
+/*
+Concurrency tests of the handlers. Run with -race.
+*/
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/game_logic"
+)
+
+// Calls a handler with a JSON body.
+func do(handler http.HandlerFunc, method string, target string, body interface{}) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(method, target, &buf))
+	return rec
+}
+
+func createGame(t *testing.T) RespPostSessions {
+	rec := do(PostSessions, "POST", "/sessions", ReqPostSessions{Name: "race"})
+	if rec.Code != http.StatusOK {
+		t.Errorf("fail in PostSessions: %s", rec.Body)
+	}
+	var resp RespPostSessions
+	json.NewDecoder(rec.Body).Decode(&resp)
+	return resp
+}
+
+func joinColor(session RespPostSessions, color string) (string, int) {
+	rec := do(PutSessions, "PUT", "/sessions", ReqPutSessions{
+		BoardID:  session.BoardID,
+		Password: session.Password,
+		Color:    color,
+	})
+	var resp RespPutSessions
+	json.NewDecoder(rec.Body).Decode(&resp)
+	return resp.Token, rec.Code
+}
+
+func TestConcurrentJoins(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+	session := createGame(t)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	joined := map[string]int{}
+	for i := 0; i < 40; i++ {
+		color := []string{"w", "b"}[i%2]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, code := joinColor(session, color)
+			if code == http.StatusOK {
+				mu.Lock()
+				joined[color]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if joined["w"] != 1 || joined["b"] != 1 {
+		t.Errorf("expected one player per color, got %v", joined)
+	}
+	game, _ := db.Games.Get(session.BoardID)
+	game.Mu.RLock()
+	defer game.Mu.RUnlock()
+	if !game.Started {
+		t.Errorf("expected the game to be started")
+	}
+}
+
+// Plays one color with random legal moves until the game ends or
+// plies moves have been made.
+func playRandom(t *testing.T, session RespPostSessions, color string, token string, plies int) {
+	query := fmt.Sprintf("/game?boardid=%d&password=%s&color=%s&token=%s",
+		session.BoardID, session.Password, color, token)
+	for {
+		rec := do(GetGame, "GET", query+"&turnreq=true&timeoutms=5000", nil)
+		if rec.Code != http.StatusOK {
+			t.Errorf("fail in turn request: %d %s", rec.Code, rec.Body)
+			return
+		}
+		rec = do(GetGame, "GET", query+"&statereq=true&moveidx=-1", nil)
+		var state RespGetGame
+		json.NewDecoder(rec.Body).Decode(&state)
+		if state.Winner != "n" || state.TurnColor != color {
+			return
+		}
+		moves := game_logic.LegalMoves(&state.BoardState)
+		if len(moves) == 0 || len(state.Board) == 0 {
+			return
+		}
+		rec = do(PutGame, "PUT", "/game", ReqPutGame{
+			BoardID:  session.BoardID,
+			Password: session.Password,
+			Color:    color,
+			Token:    token,
+			Move:     moves[len(moves)/2].String(),
+		})
+		if rec.Code != http.StatusOK {
+			// The opponent may have forfeited in the meantime.
+			if rec.Body.String() != "Can't apply move. Game has ended.\n" {
+				t.Errorf("fail in PutGame: %s", rec.Body)
+			}
+			return
+		}
+		plies--
+		if plies <= 0 {
+			do(PutGame, "PUT", "/game", ReqPutGame{
+				BoardID:  session.BoardID,
+				Password: session.Password,
+				Color:    color,
+				Token:    token,
+				Forfeit:  true,
+			})
+			return
+		}
+	}
+}
+
+func TestConcurrentGames(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		session := createGame(t)
+		var tokens [2]string
+		for j, color := range []string{"w", "b"} {
+			tokens[j], _ = joinColor(session, color)
+		}
+		for j, color := range []string{"w", "b"} {
+			wg.Add(1)
+			go func(color string, token string) {
+				defer wg.Done()
+				playRandom(t, session, color, token, 10)
+			}(color, tokens[j])
+		}
+	}
+
+	// Sessions come and go while the games are running.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			session := createGame(t)
+			joinColor(session, "w")
+			do(GetSessions, "GET", "/sessions", nil)
+			do(DeleteSessions, "DELETE", "/sessions", ReqDeleteSessions{
+				BoardID:  session.BoardID,
+				Password: session.Password,
+			})
+		}
+	}()
+	wg.Wait()
+
+	for _, game := range db.Games.List() {
+		game.Mu.RLock()
+		if game.Started && len(game.Moves) == 0 {
+			t.Errorf("game %d has no moves", game.ID)
+		}
+		for idx := 0; idx < game.Plies(); idx++ {
+			if _, err := game.PositionAt(idx); err != nil {
+				t.Errorf("game %d: fail in PositionAt(%d): %s", game.ID, idx, err)
+			}
+		}
+		game.Mu.RUnlock()
+	}
+}
@@ -0,0 +1,70 @@
+/*
+Draw offers. An offer stays open until the opponent accepts or
+declines it, or makes a move instead.
+*/
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+)
+
+const (
+	DrawOffer   = "offer"
+	DrawAccept  = "accept"
+	DrawDecline = "decline"
+)
+
+// Applies a draw action of a verified player, optionally together
+// with a move when offering. Called with game.Mu held.
+func playDraw(game *db.Game, color string, action string, moveStr string) (int, error) {
+	if game.Over() {
+		return http.StatusBadRequest, errors.New("Can't handle draw. Game has ended.")
+	}
+	if !game.Started {
+		return http.StatusBadRequest, errors.New("Can't handle draw. Game has not started.")
+	}
+
+	switch action {
+	case DrawOffer:
+		if game.DrawOffer == opponent(color) {
+			// Both want a draw.
+			endGame(game, "r", "agreement")
+			return http.StatusOK, nil
+		}
+		if game.DrawOffer == color {
+			return http.StatusBadRequest, errors.New("Draw was already offered.")
+		}
+		if moveStr != "" {
+			status, err := playTurn(game, color, moveStr, false)
+			if err != nil || game.Over() {
+				return status, err
+			}
+		}
+		game.DrawOffer = color
+		journal(game, db.JournalEntry{Type: db.JournalDrawOffer, Color: color})
+		publishDraw(game, EventDrawOffer, color)
+		return http.StatusOK, nil
+
+	case DrawAccept:
+		if game.DrawOffer != opponent(color) {
+			return http.StatusBadRequest, errors.New("There is no draw offer to accept.")
+		}
+		endGame(game, "r", "agreement")
+		return http.StatusOK, nil
+
+	case DrawDecline:
+		if game.DrawOffer != opponent(color) {
+			return http.StatusBadRequest, errors.New("There is no draw offer to decline.")
+		}
+		game.DrawOffer = ""
+		journal(game, db.JournalEntry{Type: db.JournalDrawDecline, Color: color})
+		publishDraw(game, EventDrawDeclined, color)
+		return http.StatusOK, nil
+
+	default:
+		return http.StatusBadRequest, errors.New("Invalid draw action. Enter 'offer', 'accept' or 'decline'.")
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Events published to the subscribers of a game, e.g. the
+WebSocket stream. All functions are called by the actor of the
+game, with game.Mu held.
+*/
+package api
+
+import (
+	"time"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+)
+
+const (
+	EventState        = "state" // Sent once when a stream is opened.
+	EventJoined       = "joined"
+	EventMove         = "move"
+	EventClock        = "clock"
+	EventGameOver     = "gameover"
+	EventDrawOffer    = "drawoffer"
+	EventDrawDeclined = "drawdeclined"
+	EventDeleted      = "deleted"
+	EventError        = "error" // Answers a message that couldn't be applied.
+
+	// Lifecycle, see janitor.go.
+	EventStarted  = "started"
+	EventAborted  = "aborted"
+	EventExpired  = "expired"  // Deleted after waiting too long for players.
+	EventArchived = "archived" // Removed some time after it ended.
+)
+
+// Delivers an event to the subscribers and wakes the long polls.
+func publish(game *db.Game, event RespEvent) {
+	game.Publish(db.Event{Type: event.Type, Payload: event})
+	game.NotifyChanged()
+}
+
+// Returns the event describing the current state of a game.
+func stateEvent(game *db.Game, eventType string) RespEvent {
+	idx := game.Plies()
+	state := stateResponse(game, idx, time.Now())
+	return RespEvent{
+		Type:    eventType,
+		Moveidx: idx,
+		State:   &state,
+	}
+}
+
+func publishJoined(game *db.Game, color string) {
+	event := stateEvent(game, EventJoined)
+	event.Color = color
+	publish(game, event)
+}
+
+// Returns the event of the move that led to the position after idx
+// moves. Also used to replay missed moves.
+func moveEvent(game *db.Game, idx int) RespEvent {
+	record := game.Moves[idx-1]
+	state := stateResponse(game, idx, time.Now())
+	return RespEvent{
+		Type:    EventMove,
+		Color:   record.Color,
+		Move:    record.Move,
+		Moveidx: idx,
+		State:   &state,
+	}
+}
+
+func publishMove(game *db.Game) {
+	publish(game, moveEvent(game, game.Plies()))
+}
+
+func publishClock(game *db.Game) {
+	clock := clockState(game, time.Now())
+	if clock == nil {
+		return
+	}
+	publish(game, RespEvent{
+		Type:    EventClock,
+		Moveidx: game.Plies(),
+		Clock:   clock,
+	})
+}
+
+// Publishes a draw offer or its decline by the given color.
+func publishDraw(game *db.Game, eventType string, color string) {
+	event := stateEvent(game, eventType)
+	event.Color = color
+	publish(game, event)
+}
+
+func publishGameOver(game *db.Game) {
+	publish(game, stateEvent(game, EventGameOver))
+}
+
+// Publishes a change of the lifecycle state of the game.
+func publishLifecycle(game *db.Game, eventType string) {
+	publish(game, stateEvent(game, eventType))
+}
+
+// Returns the event announcing the end of a game.
+func endEvent(game *db.Game) RespEvent {
+	if game.State == db.StateAborted {
+		return stateEvent(game, EventAborted)
+	}
+	return stateEvent(game, EventGameOver)
+}
+
+// Notifies the subscribers that the game was removed, by deleting,
+// expiring or archiving it, and closes their channels.
+func publishRemoved(game *db.Game, eventType string) {
+	publish(game, RespEvent{Type: eventType, Moveidx: game.Plies()})
+	game.CloseSubscribers()
+}
@@ -0,0 +1,352 @@
+/*
+Helper functions for running games.
+*/
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/game_logic"
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/rating"
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/tablebase"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+)
+
+// Endgame tables used to adjudicate games, nil if none are loaded.
+var Tablebases *tablebase.Set
+
+// Parameters used to rate games between accounts.
+var RatingConfig = rating.DefaultConfig
+
+// Marks the game as started once both players have joined.
+func startGame(game *db.Game) {
+	now := time.Now()
+	game.Started = true
+	game.State = db.StateActive
+	game.StartedAt = now
+	game.LastMoveAt = now
+	game.TurnStart = now
+	startClock(game)
+	journal(game, db.JournalEntry{Type: db.JournalStarted, Time: now})
+	scheduleMoveDeadline(game)
+	publishLifecycle(game, EventStarted)
+	publishClock(game)
+	notifyWebhook(game)
+}
+
+// Restarts the clocks, move timers and callbacks of running games
+// after they were loaded from storage. The time the server was down
+// isn't charged to the player to move, nor counted as inactivity.
+func ResumeGames() {
+	for _, game := range db.Games.List() {
+		sendCommand(game, func(game *db.Game) (int, error) {
+			if game.State == db.StateActive {
+				now := time.Now()
+				game.TurnStart = now
+				game.LastMoveAt = now
+				scheduleFlag(game)
+				scheduleMoveDeadline(game)
+				notifyWebhook(game)
+			}
+			return http.StatusOK, nil
+		})
+	}
+}
+
+// Applies a move or a forfeit of a verified player. Returns the HTTP
+// status and an error if the request can't be carried out. Called
+// with game.Mu held.
+func playTurn(game *db.Game, color string, moveStr string, forfeit bool) (int, error) {
+	now := time.Now()
+
+	if game.Over() {
+		return http.StatusBadRequest, errors.New("Can't apply move. Game has ended.")
+	}
+
+	if forfeit {
+		endGame(game, opponent(color), "forfeit")
+		return http.StatusOK, nil
+	}
+
+	if !game.Started {
+		return http.StatusBadRequest, errors.New("Can't apply move. Game has not started.")
+	}
+
+	if currentTurn(game) != color {
+		// The time of the opponent can be up before its timer fired.
+		if checkFlag(game, now) {
+			return http.StatusBadRequest, errors.New("Can't apply move. Your opponent ran out of time.")
+		}
+		if checkMoveDeadline(game, now) && game.Over() {
+			return http.StatusBadRequest, errors.New("Can't apply move. Your opponent exceeded the move time.")
+		}
+	}
+	if currentTurn(game) != color {
+		if plies := game.Plies(); plies > 0 && game.Moves[plies-1].Color == color && game.Moves[plies-1].Fallback {
+			return http.StatusBadRequest, errors.New("Can't apply move. Move time exceeded, a random move was played for you.")
+		}
+		return http.StatusBadRequest, errors.New("Can't apply move. It's not your turn.")
+	}
+
+	if checkFlag(game, now) {
+		return http.StatusBadRequest, errors.New("Can't apply move. Your time is up.")
+	}
+	if checkMoveDeadline(game, now) {
+		if game.Over() {
+			return http.StatusBadRequest, errors.New("Can't apply move. Move time exceeded.")
+		}
+		return http.StatusBadRequest, errors.New("Can't apply move. Move time exceeded, a random move was played for you.")
+	}
+
+	move, err := game_logic.StringToMoveStruct(moveStr, rune(color[0]))
+	if err != nil {
+		return http.StatusBadRequest, errors.New("Move format is invalid.")
+	}
+
+	// Check validity of move
+	err = game_logic.ValidateMove(&move, game.Position())
+	if err != nil {
+		return http.StatusBadRequest, fmt.Errorf("Move is invalid with error: %v", err)
+	}
+
+	applyMove(game, move, now, movePlayed)
+	return http.StatusOK, nil
+}
+
+// Who chose a move.
+type moveOrigin int
+
+const (
+	movePlayed   moveOrigin = iota // By the player.
+	moveFallback                   // By the server after the move time ran out.
+	moveOpening                    // By the server from the opening of an SPRT run.
+)
+
+// Applies a validated move of the player to move and hands the
+// turn over.
+func applyMove(game *db.Game, move game_logic.Move, now time.Time, origin moveOrigin) {
+	color := currentTurn(game)
+	thinkTime := now.Sub(game.TurnStart)
+	punchClock(game, color, now)
+
+	record := db.MoveRecord{
+		Color:     color,
+		Move:      move.String(),
+		ThinkTime: thinkTime,
+		Fallback:  origin == moveFallback,
+		Opening:   origin == moveOpening,
+	}
+	game.PushMove(move, record)
+	game.TurnStart = now
+	game.LastMoveAt = now
+	if game.DrawOffer == opponent(color) {
+		// Moving instead of answering declines the offer.
+		game.DrawOffer = ""
+	}
+	journal(game, db.JournalEntry{
+		Type:      db.JournalMove,
+		Time:      now,
+		Color:     color,
+		Move:      record.Move,
+		ThinkTime: thinkTime,
+		Fallback:  record.Fallback,
+		Opening:   record.Opening,
+	})
+	publishMove(game)
+	publishClock(game)
+
+	concludeIfDecided(game)
+	scheduleFlag(game)
+	scheduleMoveDeadline(game)
+	notifyWebhook(game)
+}
+
+// Ends a game with the given winner ('w', 'b' or 'r') and reason.
+func endGame(game *db.Game, winner string, reason string) {
+	now := time.Now()
+	stopClock(game, now)
+	stopTimers(game)
+	journalEnd(game, winner, reason, now)
+	game.Finish(winner, reason, now)
+	rateGame(game, now)
+	if result, finished := db.ResultOf(game); finished {
+		db.Results.Add(result)
+	}
+	tournamentGameOver(game)
+	sprtGameOver(game)
+	publishGameOver(game)
+}
+
+// Updates the ratings of the accounts that played a finished game.
+// Games of anonymous players and against oneself aren't rated.
+func rateGame(game *db.Game, now time.Time) {
+	if game.W_account == 0 || game.B_account == 0 || game.W_account == game.B_account {
+		return
+	}
+	score := 0.5
+	switch game.Winner {
+	case "w":
+		score = 1
+	case "b":
+		score = 0
+	}
+	err := db.Accounts.RateGame(db.RatedGame{
+		GameID:   game.ID,
+		Category: game.Category(),
+		White:    game.W_account,
+		Black:    game.B_account,
+		Score:    score,
+		Time:     now,
+	}, RatingConfig)
+	if err != nil {
+		log.Printf("Failed to rate game %d: %v", game.ID, err)
+	}
+}
+
+// Ends a game without a result.
+func abortGame(game *db.Game, reason string, now time.Time) {
+	stopTimers(game)
+	journal(game, db.JournalEntry{Type: db.JournalAborted, Time: now, Reason: reason})
+	game.Abort(reason, now)
+	publishLifecycle(game, EventAborted)
+	tournamentGameOver(game)
+	sprtGameOver(game)
+}
+
+// Appends an entry to the journal of the game, together with the
+// clocks if the game has a time control.
+func journal(game *db.Game, entry db.JournalEntry) {
+	if game.TimeControl != nil {
+		entry.W_timeLeft = game.W_timeLeft
+		entry.B_timeLeft = game.B_timeLeft
+	}
+	game.Log(entry)
+}
+
+// Journals the end of a game. Checkmate and stalemate are left out,
+// replaying the moves finds them.
+func journalEnd(game *db.Game, winner string, reason string, now time.Time) {
+	entry := db.JournalEntry{Time: now, Winner: winner, Reason: reason}
+	switch reason {
+	case "forfeit":
+		entry.Type = db.JournalForfeit
+		entry.Color = opponent(winner)
+	case "timeout", "movetime":
+		entry.Type = db.JournalTimeout
+		entry.Color = currentTurn(game)
+	case "agreement":
+		entry.Type = db.JournalDrawAccept
+		entry.Color = opponent(game.DrawOffer)
+	case "tablebase":
+		entry.Type = db.JournalAdjudicated
+	default:
+		return
+	}
+	journal(game, entry)
+}
+
+// Stops the timers enforcing clocks and move times.
+func stopTimers(game *db.Game) {
+	if game.ClockTimer != nil {
+		game.ClockTimer.Stop()
+		game.ClockTimer = nil
+	}
+	if game.DeadlineTimer != nil {
+		game.DeadlineTimer.Stop()
+		game.DeadlineTimer = nil
+	}
+}
+
+// Ends the game if the current position is decided, either by
+// checkmate, stalemate or by the tablebases.
+func concludeIfDecided(game *db.Game) {
+	bstate := game.Position()
+
+	winner, reason := game_logic.GameEnd(bstate)
+	if winner != "n" {
+		endGame(game, winner, reason)
+		return
+	}
+
+	if Tablebases == nil {
+		return
+	}
+	entry, ok := Tablebases.Probe(bstate)
+	if !ok {
+		return
+	}
+	switch entry.WDL {
+	case tablebase.Draw:
+		endGame(game, "r", "tablebase")
+	case tablebase.Win:
+		endGame(game, bstate.TurnColor, "tablebase")
+	case tablebase.Loss:
+		endGame(game, opponent(bstate.TurnColor), "tablebase")
+	}
+}
+
+// Returns the opposing color.
+func opponent(color string) string {
+	if color == "w" {
+		return "b"
+	}
+	return "w"
+}
+
+func moveRecordResponse(record db.MoveRecord) RespMoveRecord {
+	return RespMoveRecord{
+		Color:    record.Color,
+		Move:     record.Move,
+		ThinkMs:  record.ThinkTime.Milliseconds(),
+		Fallback: record.Fallback,
+		Opening:  record.Opening,
+	}
+}
+
+// Converts a journal entry for responses, leaving out the password
+// and the tokens.
+func journalEntryResponse(game *db.Game, entry db.JournalEntry) RespJournalEntry {
+	resp := RespJournalEntry{
+		Seq:      entry.Seq,
+		Time:     entry.Time,
+		Type:     entry.Type,
+		Color:    entry.Color,
+		Move:     entry.Move,
+		ThinkMs:  entry.ThinkTime.Milliseconds(),
+		Fallback: entry.Fallback,
+		Opening:  entry.Opening,
+		Account:  accountName(entry.Account),
+		Winner:   entry.Winner,
+		Reason:   entry.Reason,
+	}
+	if game.TimeControl != nil && entry.Type != db.JournalCreated && entry.Type != db.JournalJoined {
+		whiteMs := entry.W_timeLeft.Milliseconds()
+		blackMs := entry.B_timeLeft.Milliseconds()
+		resp.WhiteMs = &whiteMs
+		resp.BlackMs = &blackMs
+	}
+	return resp
+}
+
+// Builds the state response for the position after idx moves,
+// which has to be in range.
+func stateResponse(game *db.Game, idx int, now time.Time) RespGetGame {
+	bstate, _ := game.PositionAt(idx)
+	resp := RespGetGame{
+		BoardState:  bstate,
+		State:       game.State,
+		Termination: game.Termination,
+		DrawOffer:   game.DrawOffer,
+		Clock:       clockState(game, now),
+	}
+	if idx > 0 {
+		record := moveRecordResponse(game.Moves[idx-1])
+		resp.LastMove = &record
+	}
+	return resp
+}
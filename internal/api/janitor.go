@@ -0,0 +1,107 @@
+/*
+The janitor cleans up games nobody plays anymore. Games waiting too
+long for their players expire and are deleted, active games without
+a move for too long are aborted and ended games are archived after a
+while. Each of these changes is published as a lifecycle event.
+*/
+package api
+
+import (
+	"net/http"
+	"time"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+)
+
+// Limits enforced by the janitor. Zero disables a limit.
+type Expiry struct {
+	Waiting  time.Duration // Games still waiting for players are deleted after this long.
+	Inactive time.Duration // Active games are aborted after this long without a move.
+	Archive  time.Duration // Finished and aborted games are archived after this long.
+}
+
+// Time between two sweeps of the janitor.
+const janitorInterval = time.Minute
+
+// What the janitor does with a game.
+const (
+	janitorKeep = iota
+	janitorExpire
+	janitorAbort
+	janitorArchive
+)
+
+// Starts the janitor in the background. Returns a function stopping
+// it.
+func StartJanitor(expiry Expiry) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(janitorInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				sweep(expiry, now)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// Enforces the limits on all games once.
+func sweep(expiry Expiry, now time.Time) {
+	for _, game := range db.Games.List() {
+		game.Mu.RLock()
+		action := janitorAction(game, expiry, now)
+		game.Mu.RUnlock()
+		if action == janitorKeep {
+			// Leave the actors of untouched games asleep.
+			continue
+		}
+
+		_, err := sendCommand(game, func(game *db.Game) (int, error) {
+			// The game may have changed in the meantime.
+			action = janitorAction(game, expiry, now)
+			switch action {
+			case janitorExpire:
+				closeGame(game, EventExpired)
+			case janitorAbort:
+				abortGame(game, "inactivity", now)
+			case janitorArchive:
+				closeGame(game, EventArchived)
+			}
+			return http.StatusOK, nil
+		})
+		if err != nil {
+			continue
+		}
+		switch action {
+		case janitorExpire:
+			db.Games.Delete(game.ID)
+		case janitorArchive:
+			db.Games.Archive(game.ID)
+		}
+	}
+}
+
+// Returns what the janitor has to do with a game. Called with
+// game.Mu held.
+func janitorAction(game *db.Game, expiry Expiry, now time.Time) int {
+	switch game.State {
+	case db.StateWaiting:
+		if expiry.Waiting > 0 && now.Sub(game.CreatedAt) >= expiry.Waiting {
+			return janitorExpire
+		}
+	case db.StateActive:
+		if expiry.Inactive > 0 && now.Sub(game.LastMoveAt) >= expiry.Inactive {
+			return janitorAbort
+		}
+	case db.StateFinished, db.StateAborted:
+		if expiry.Archive > 0 && now.Sub(game.EndedAt) >= expiry.Archive {
+			return janitorArchive
+		}
+	}
+	return janitorKeep
+}
@@ -0,0 +1,122 @@
+/*
+Tests of the janitor.
+*/
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+)
+
+func TestJanitor(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+	expiry := Expiry{Waiting: time.Minute, Inactive: 10 * time.Minute, Archive: time.Hour}
+
+	waiting := createGame(t)
+	halfFull := createGame(t)
+	joinColor(halfFull, "w")
+	active := createGame(t)
+	finished := createGame(t)
+	white := map[int32]string{}
+	for _, session := range []RespPostSessions{active, finished} {
+		white[session.BoardID], _ = joinColor(session, "w")
+		joinColor(session, "b")
+	}
+	rec := do(PutGame, "PUT", "/game", ReqPutGame{
+		BoardID:  finished.BoardID,
+		Password: finished.Password,
+		Color:    "w",
+		Token:    white[finished.BoardID],
+		Forfeit:  true,
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("fail in PutGame: %s", rec.Body)
+	}
+
+	activeGame, _ := db.Games.Get(active.BoardID)
+	events, cancel := activeGame.Subscribe()
+	defer cancel()
+
+	// Nothing is due yet.
+	sweep(expiry, time.Now())
+	if games := db.Games.List(); len(games) != 4 {
+		t.Fatalf("expected 4 games, got %d", len(games))
+	}
+
+	sweep(expiry, time.Now().Add(30*time.Minute))
+	for _, session := range []RespPostSessions{waiting, halfFull} {
+		if _, err := db.Games.Get(session.BoardID); err != db.ErrNotFound {
+			t.Errorf("expected waiting game %d to expire", session.BoardID)
+		}
+	}
+	activeGame.Mu.RLock()
+	state, termination := activeGame.State, activeGame.Termination
+	activeGame.Mu.RUnlock()
+	if state != db.StateAborted || termination != "inactivity" {
+		t.Errorf("expected the inactive game to be aborted, got %q %q", state, termination)
+	}
+	if event := <-events; event.Type != EventAborted {
+		t.Errorf("expected an %q event, got %q", EventAborted, event.Type)
+	}
+	if _, err := db.Games.Get(finished.BoardID); err != nil {
+		t.Errorf("expected the finished game to be kept")
+	}
+
+	rec = do(PutGame, "PUT", "/game", ReqPutGame{
+		BoardID:  active.BoardID,
+		Password: active.Password,
+		Color:    "w",
+		Token:    white[active.BoardID],
+		Move:     "e2 e4",
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected moves in aborted games to fail, got %d", rec.Code)
+	}
+
+	sweep(expiry, time.Now().Add(2*time.Hour))
+	for _, session := range []RespPostSessions{active, finished} {
+		if _, err := db.Games.Get(session.BoardID); err != db.ErrNotFound {
+			t.Errorf("expected game %d to be archived", session.BoardID)
+		}
+	}
+	if event, ok := <-events; !ok || event.Type != EventArchived {
+		t.Errorf("expected an %q event", EventArchived)
+	}
+	if _, ok := <-events; ok {
+		t.Errorf("expected the subscription to end with the archiving")
+	}
+}
+
+func TestResumedGamesAreNotInactive(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+	expiry := Expiry{Inactive: time.Hour}
+	session := createGame(t)
+	joinColor(session, "w")
+	joinColor(session, "b")
+
+	// Replayed from a journal after the server was down for a day.
+	game, _ := db.Games.Get(session.BoardID)
+	game.Mu.Lock()
+	game.LastMoveAt = time.Now().Add(-24 * time.Hour)
+	game.Mu.Unlock()
+
+	ResumeGames()
+	sweep(expiry, time.Now())
+	game.Mu.RLock()
+	state := game.State
+	game.Mu.RUnlock()
+	if state != db.StateActive {
+		t.Errorf("expected the resumed game to stay active, got %q", state)
+	}
+
+	sweep(expiry, time.Now().Add(2*time.Hour))
+	game.Mu.RLock()
+	state = game.State
+	game.Mu.RUnlock()
+	if state != db.StateAborted {
+		t.Errorf("expected the game to be aborted once inactive after the restart, got %q", state)
+	}
+}
@@ -0,0 +1,150 @@
+/*
+Matchmaking queue. Accounts wait in the queue with the settings of the
+game they want to play. A new request is paired with the longest
+waiting compatible one, the game is created and both accounts are
+seated.
+*/
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+)
+
+// Variants accepted by matchmaking. Only standard chess is played.
+const VariantStandard = "standard"
+
+var errAlreadyQueued = errors.New("Account is already waiting for a game.")
+
+// Settings two accounts have to agree on to be paired.
+type matchSettings struct {
+	TimeControl db.TimeControl // Zero for games without clocks.
+	MoveTime    time.Duration
+	MoveTimeout string
+	Variant     string
+}
+
+// Seat assigned to a queued account.
+type matchResult struct {
+	BoardID  int32
+	Color    string
+	Token    string
+	Opponent int32
+}
+
+type ticket struct {
+	account  int32
+	settings matchSettings
+	color    string // Preferred color, "" for any.
+	match    chan matchResult
+}
+
+// Returns whether two tickets can be paired.
+func (t *ticket) compatible(other *ticket) bool {
+	return t.account != other.account &&
+		t.settings == other.settings &&
+		(t.color == "" || t.color != other.color)
+}
+
+type matchQueue struct {
+	mu      sync.Mutex
+	tickets []*ticket // Longest waiting first.
+}
+
+var matchmaking = &matchQueue{}
+
+// Pairs t with a waiting ticket or queues it. Returns the seat of t
+// if it was paired right away, otherwise nil and t receives its seat
+// on t.match.
+func (queue *matchQueue) enter(t *ticket) (*matchResult, error) {
+	queue.mu.Lock()
+	for _, waiting := range queue.tickets {
+		if waiting.account == t.account {
+			queue.mu.Unlock()
+			return nil, errAlreadyQueued
+		}
+	}
+	for i, waiting := range queue.tickets {
+		if waiting.compatible(t) {
+			queue.tickets = append(queue.tickets[:i], queue.tickets[i+1:]...)
+			queue.mu.Unlock()
+			own, other := pair(t, waiting)
+			waiting.match <- other
+			return &own, nil
+		}
+	}
+	queue.tickets = append(queue.tickets, t)
+	queue.mu.Unlock()
+	return nil, nil
+}
+
+// Removes t from the queue. Returns false if it was paired already.
+func (queue *matchQueue) leave(t *ticket) bool {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	for i, waiting := range queue.tickets {
+		if waiting == t {
+			queue.tickets = append(queue.tickets[:i], queue.tickets[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Creates the game of two paired tickets and seats both accounts.
+// The waiting ticket gets its preferred color first.
+func pair(t *ticket, waiting *ticket) (matchResult, matchResult) {
+	waitingColor := waiting.color
+	if waitingColor == "" && t.color != "" {
+		waitingColor = opponent(t.color)
+	}
+	if waitingColor == "" {
+		waitingColor = []string{"w", "b"}[rand.Intn(2)]
+	}
+	white, black := waiting, t
+	if waitingColor == "b" {
+		white, black = t, waiting
+	}
+
+	var timeControl *db.TimeControl
+	if t.settings.TimeControl != (db.TimeControl{}) {
+		tc := t.settings.TimeControl
+		timeControl = &tc
+	}
+	name := fmt.Sprintf("%s vs %s", accountName(white.account), accountName(black.account))
+	game, _ := createSession(name, timeControl, t.settings.MoveTime, t.settings.MoveTimeout)
+
+	whiteToken, blackToken := generateToken(), generateToken()
+	sendCommand(game, func(game *db.Game) (int, error) {
+		joinGame(game, "w", whiteToken, white.account, "")
+		return joinGame(game, "b", blackToken, black.account, "")
+	})
+
+	results := map[*ticket]matchResult{
+		white: {BoardID: game.ID, Color: "w", Token: whiteToken, Opponent: black.account},
+		black: {BoardID: game.ID, Color: "b", Token: blackToken, Opponent: white.account},
+	}
+	return results[t], results[waiting]
+}
+
+// Aborts the game of a pairing whose client left before getting its
+// seat.
+func abandonMatch(result matchResult) {
+	game, err := db.Games.Get(result.BoardID)
+	if err != nil {
+		return
+	}
+	sendCommand(game, func(game *db.Game) (int, error) {
+		if game.Over() {
+			return http.StatusOK, nil
+		}
+		abortGame(game, "abandoned", time.Now())
+		return http.StatusOK, nil
+	})
+}
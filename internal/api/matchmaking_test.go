@@ -0,0 +1,127 @@
+/*
+Tests of the matchmaking queue.
+*/
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+)
+
+// Asks for a game in the background.
+func queue(key string, req ReqPostMatchmaking) chan *httptest.ResponseRecorder {
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		done <- doKey(PostMatchmaking, "POST", "/matchmaking", key, req)
+	}()
+	return done
+}
+
+// Waits until n accounts are queued.
+func waitQueued(t *testing.T, n int) {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		matchmaking.mu.Lock()
+		queued := len(matchmaking.tickets)
+		matchmaking.mu.Unlock()
+		if queued == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("expected %d accounts in the queue", n)
+}
+
+func TestMatchmaking(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+	db.Accounts = db.NewAccountRepository()
+	matchmaking = &matchQueue{}
+	user := createAccount(t, "alice", db.AccountUser, "")
+	first := createAccount(t, "first", db.AccountBot, user.Key)
+	second := createAccount(t, "second", db.AccountBot, user.Key)
+	third := createAccount(t, "third", db.AccountBot, user.Key)
+
+	if rec := doKey(PostMatchmaking, "POST", "/matchmaking", "", ReqPostMatchmaking{}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected matchmaking without key to be rejected, got %d", rec.Code)
+	}
+	if rec := doKey(PostMatchmaking, "POST", "/matchmaking", first.Key, ReqPostMatchmaking{Variant: "chess960"}); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected an unknown variant to be rejected, got %d", rec.Code)
+	}
+
+	blitz := ReqPostMatchmaking{BaseMs: 3 * 60 * 1000, IncrementMs: 2000, Color: "b"}
+	waiting := queue(first.Key, blitz)
+	waitQueued(t, 1)
+	if rec := doKey(PostMatchmaking, "POST", "/matchmaking", first.Key, blitz); rec.Code != http.StatusConflict {
+		t.Errorf("expected a second request of the account to be rejected, got %d", rec.Code)
+	}
+	// Neither a different time control nor the same color matches.
+	rapid := ReqPostMatchmaking{BaseMs: 15 * 60 * 1000, TimeoutMs: 20}
+	if rec := doKey(PostMatchmaking, "POST", "/matchmaking", third.Key, rapid); rec.Code != http.StatusRequestTimeout {
+		t.Errorf("expected no opponent for another time control, got %d", rec.Code)
+	}
+	blitz.TimeoutMs = 20
+	if rec := doKey(PostMatchmaking, "POST", "/matchmaking", third.Key, blitz); rec.Code != http.StatusRequestTimeout {
+		t.Errorf("expected no opponent for the same color, got %d", rec.Code)
+	}
+
+	blitz.Color = ""
+	rec := doKey(PostMatchmaking, "POST", "/matchmaking", second.Key, blitz)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("fail in PostMatchmaking: %s", rec.Body)
+	}
+	var own, other RespPostMatchmaking
+	json.NewDecoder(rec.Body).Decode(&own)
+	rec = <-waiting
+	if rec.Code != http.StatusOK {
+		t.Fatalf("fail in PostMatchmaking: %s", rec.Body)
+	}
+	json.NewDecoder(rec.Body).Decode(&other)
+
+	if own.BoardID != other.BoardID || own.Color != "w" || other.Color != "b" {
+		t.Fatalf("expected first to play black in the same game, got %+v and %+v", own, other)
+	}
+	if own.Opponent != "first" || other.Opponent != "second" {
+		t.Errorf("expected the opponents to be named, got %q and %q", own.Opponent, other.Opponent)
+	}
+	game, _ := db.Games.Get(own.BoardID)
+	game.Mu.RLock()
+	state, tc := game.State, game.TimeControl
+	game.Mu.RUnlock()
+	if state != db.StateActive || tc == nil || tc.Increment != 2*time.Second {
+		t.Errorf("expected an active blitz game, got %s %+v", state, tc)
+	}
+	rec = doKey(PutGame, "PUT", "/game", own.Token, ReqPutGame{BoardID: own.BoardID, Color: "w", Move: "e2 e4"})
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected the seat token to be accepted, got %d %s", rec.Code, rec.Body)
+	}
+	waitQueued(t, 0)
+}
+
+func TestMatchmakingAbandoned(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+	db.Accounts = db.NewAccountRepository()
+	user := createAccount(t, "alice", db.AccountUser, "")
+	first := createAccount(t, "first", db.AccountBot, user.Key)
+	second := createAccount(t, "second", db.AccountBot, user.Key)
+
+	// The client of the waiting ticket left while it was paired.
+	waiting := &ticket{account: first.Account.AccountID, match: make(chan matchResult, 1)}
+	t2 := &ticket{account: second.Account.AccountID, match: make(chan matchResult, 1)}
+	own, other := pair(t2, waiting)
+	abandonMatch(other)
+
+	game, err := db.Games.Get(own.BoardID)
+	if err != nil {
+		t.Fatalf("fail in Get: %v", err)
+	}
+	game.Mu.RLock()
+	defer game.Mu.RUnlock()
+	if game.State != db.StateAborted || game.Termination != "abandoned" {
+		t.Errorf("expected the game to be abandoned, got %q %q", game.State, game.Termination)
+	}
+}
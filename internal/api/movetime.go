@@ -0,0 +1,84 @@
+/*
+Fixed time per move. Each player has to move within the move
+time after the turn switched, otherwise the game is forfeited
+or the server plays a random move instead.
+*/
+package api
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"time"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/game_logic"
+)
+
+// Validates the move time settings of a session request.
+func newMoveTime(req ReqPostSessions) (time.Duration, string, error) {
+	if req.MoveTimeMs == 0 {
+		if req.OnMoveTimeout != "" {
+			return 0, "", errors.New("'onmovetimeout' requires 'movetimems'")
+		}
+		return 0, "", nil
+	}
+	if req.MoveTimeMs < 0 {
+		return 0, "", errors.New("'movetimems' can't be negative")
+	}
+	if req.BaseMs != 0 {
+		return 0, "", errors.New("a game can either have clocks or a move time")
+	}
+	switch req.OnMoveTimeout {
+	case "":
+		return time.Duration(req.MoveTimeMs) * time.Millisecond, "forfeit", nil
+	case "forfeit", "random":
+		return time.Duration(req.MoveTimeMs) * time.Millisecond, req.OnMoveTimeout, nil
+	}
+	return 0, "", errors.New("invalid 'onmovetimeout'. Enter 'forfeit' or 'random'")
+}
+
+// Handles an exceeded move time of the player to move.
+// Returns false if the player is still within the move time.
+func checkMoveDeadline(game *db.Game, now time.Time) bool {
+	if game.MoveTime == 0 || game.State != db.StateActive {
+		return false
+	}
+	if now.Sub(game.TurnStart) < game.MoveTime {
+		return false
+	}
+	color := currentTurn(game)
+	if game.MoveTimeout == "random" {
+		moves := game_logic.LegalMoves(game.Position())
+		if len(moves) > 0 {
+			// The move is booked at the deadline, not when it was noticed.
+			applyMove(game, moves[rand.Intn(len(moves))], game.TurnStart.Add(game.MoveTime), moveFallback)
+			return true
+		}
+	}
+	endGame(game, opponent(color), "movetime")
+	return true
+}
+
+// Sets a timer that enforces the move time of the player to move.
+// Replaces the timer of the previous turn.
+func scheduleMoveDeadline(game *db.Game) {
+	if game.DeadlineTimer != nil {
+		game.DeadlineTimer.Stop()
+		game.DeadlineTimer = nil
+	}
+	if game.MoveTime == 0 || game.State != db.StateActive {
+		return
+	}
+	ply := game.Plies()
+	wait := time.Until(game.TurnStart.Add(game.MoveTime))
+	game.DeadlineTimer = time.AfterFunc(wait, func() {
+		sendCommand(game, func(game *db.Game) (int, error) {
+			if game.Plies() == ply && !checkMoveDeadline(game, time.Now()) {
+				// Woke up too early.
+				scheduleMoveDeadline(game)
+			}
+			return http.StatusOK, nil
+		})
+	})
+}
@@ -0,0 +1,102 @@
+/*
+Tests of the move time, driven with fixed times.
+*/
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+)
+
+func TestMoveTimeForfeit(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+	game := timedGame(t, ReqPostSessions{MoveTimeMs: 60000})
+	game.Mu.Lock()
+	defer game.Mu.Unlock()
+	start := game.TurnStart
+
+	if checkMoveDeadline(game, start.Add(30*time.Second)) {
+		t.Fatalf("expected white to be within the move time")
+	}
+	if !checkMoveDeadline(game, start.Add(time.Minute)) {
+		t.Fatalf("expected the move time to be exceeded")
+	}
+	if game.State != db.StateFinished || game.Winner != "b" || game.Termination != "movetime" {
+		t.Errorf("expected black to win, got %q %q %q", game.State, game.Winner, game.Termination)
+	}
+	if game.Plies() != 0 {
+		t.Errorf("expected no move to be played, got %d", game.Plies())
+	}
+}
+
+func TestMoveTimeRandom(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+	game := timedGame(t, ReqPostSessions{MoveTimeMs: 60000, OnMoveTimeout: "random"})
+	game.Mu.Lock()
+	defer game.Mu.Unlock()
+	start := game.TurnStart
+
+	// Noticed late, but booked at the deadline.
+	if !checkMoveDeadline(game, start.Add(90*time.Second)) {
+		t.Fatalf("expected the move time to be exceeded")
+	}
+	if game.State != db.StateActive || currentTurn(game) != "b" || game.Plies() != 1 {
+		t.Fatalf("expected a move for white and black to move, got %q, %q to move after %d plies",
+			game.State, currentTurn(game), game.Plies())
+	}
+	record := game.Moves[0]
+	if record.Color != "w" || !record.Fallback || record.ThinkTime != time.Minute {
+		t.Errorf("expected a fallback move of white after the move time, got %+v", record)
+	}
+	if !game.TurnStart.Equal(start.Add(time.Minute)) {
+		t.Errorf("expected the turn of black to start at the deadline, got %s", game.TurnStart.Sub(start))
+	}
+	entry := game.Journal[len(game.Journal)-1]
+	if entry.Type != db.JournalMove || !entry.Fallback {
+		t.Errorf("expected the fallback move to be journaled, got %+v", entry)
+	}
+	if resp := moveRecordResponse(record); !resp.Fallback || resp.ThinkMs != 60000 {
+		t.Errorf("expected the history to show the fallback, got %+v", resp)
+	}
+}
+
+func TestMoveTimeLateMove(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+	game := timedGame(t, ReqPostSessions{MoveTimeMs: 60000, OnMoveTimeout: "random"})
+	game.Mu.Lock()
+	defer game.Mu.Unlock()
+	game.TurnStart = time.Now().Add(-61 * time.Second)
+
+	// Late before and after the fallback move was played.
+	for range 2 {
+		code, err := playTurn(game, "w", "e2 e4", false)
+		if code != http.StatusBadRequest || err == nil || err.Error() != "Can't apply move. Move time exceeded, a random move was played for you." {
+			t.Errorf("expected white to be told about the fallback move, got %d %v", code, err)
+		}
+	}
+	if game.Plies() != 1 || !game.Moves[0].Fallback {
+		t.Fatalf("expected one fallback move, got %+v", game.Moves)
+	}
+	if code, err := playTurn(game, "b", "g8 f6", false); code != http.StatusOK {
+		t.Errorf("expected black to move, got %d %v", code, err)
+	}
+}
+
+func TestMoveTimeNoticedByOpponent(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+	game := timedGame(t, ReqPostSessions{MoveTimeMs: 60000})
+	game.Mu.Lock()
+	defer game.Mu.Unlock()
+	game.TurnStart = time.Now().Add(-61 * time.Second)
+
+	code, err := playTurn(game, "b", "e7 e5", false)
+	if code != http.StatusBadRequest || err == nil || err.Error() != "Can't apply move. Your opponent exceeded the move time." {
+		t.Errorf("expected black to be told about the move time of white, got %d %v", code, err)
+	}
+	if game.Winner != "b" || game.Termination != "movetime" {
+		t.Errorf("expected black to win, got %q %q", game.Winner, game.Termination)
+	}
+}
@@ -0,0 +1,273 @@
+/*
+API for user and bot accounts and their API keys.
+*/
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/schema"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+)
+
+// Creates an account
+func PostAccounts(w http.ResponseWriter, r *http.Request) {
+	/*
+		Input:
+			Name and kind of the account. Bots are created by
+			their owner, who sends the API key of a user
+			account as 'Authorization: Bearer <key>'.
+
+			ReqPostAccounts
+			Name string `json:"name"`
+			Kind string `json:"kind"`
+		Return:
+			The account and its first API key. The key is only
+			shown once.
+
+			RespPostAccounts
+			Account RespAccount `json:"account"`
+			KeyID   string      `json:"keyid"`
+			Key     string      `json:"key"`
+		Actions:
+			Creates the account.
+	*/
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	var req ReqPostAccounts
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request payload: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var owner string
+	switch req.Kind {
+	case db.AccountUser:
+	case db.AccountBot:
+		user, err := db.Accounts.Authenticate(bearerToken(r))
+		if err != nil {
+			http.Error(w, "Bots are created with the API key of their owner.", http.StatusUnauthorized)
+			return
+		}
+		if user.Kind != db.AccountUser {
+			http.Error(w, "Bots can't own bots.", http.StatusForbidden)
+			return
+		}
+		owner = user.Name
+	default:
+		http.Error(w, "Invalid kind. Enter 'user' or 'bot'", http.StatusBadRequest)
+		return
+	}
+
+	account, key, apiKey, err := db.Accounts.CreateWithKey(req.Name, owner, req.Kind)
+	if errors.Is(err, db.ErrNameTaken) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp := RespPostAccounts{Account: accountResponse(account), KeyID: apiKey.ID, Key: key}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Displays all accounts
+func GetAccounts(w http.ResponseWriter, r *http.Request) {
+	/*
+		Input:
+			---
+		Return:
+			All accounts without their keys.
+
+			RespGetAccounts
+			Accounts []RespAccount `json:"accounts"`
+		Actions:
+			---
+	*/
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	resp := RespGetAccounts{Accounts: []RespAccount{}}
+	for _, account := range db.Accounts.List() {
+		resp.Accounts = append(resp.Accounts, accountResponse(account))
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Displays the API keys of an account
+func GetAccountKeys(w http.ResponseWriter, r *http.Request) {
+	/*
+		Input:
+			Account ID as query parameter and an API key of the
+			account or its owner as 'Authorization: Bearer <key>'.
+
+			ReqGetAccountKeys
+			AccountID int32 `schema:"accountid"`
+		Return:
+			All keys of the account, without their secrets.
+
+			RespGetAccountKeys
+			Keys []RespAPIKey `json:"keys"`
+		Actions:
+			---
+	*/
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	var req ReqGetAccountKeys
+	decoder := schema.NewDecoder()
+	decoder.IgnoreUnknownKeys(true)
+
+	err := decoder.Decode(&req, r.URL.Query())
+	if err != nil {
+		http.Error(w, "Failed to parse query params: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	account, success := verifyAccountAccess(w, r, req.AccountID)
+	if !success {
+		return
+	}
+
+	resp := RespGetAccountKeys{Keys: []RespAPIKey{}}
+	for _, key := range account.Keys {
+		respKey := RespAPIKey{KeyID: key.ID, Created: key.CreatedAt}
+		if !key.Active() {
+			revoked := key.RevokedAt
+			respKey.Revoked = &revoked
+		}
+		resp.Keys = append(resp.Keys, respKey)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Adds an API key to an account
+func PostAccountKeys(w http.ResponseWriter, r *http.Request) {
+	/*
+		Input:
+			Account ID and an API key of the account or its
+			owner as 'Authorization: Bearer <key>'.
+
+			ReqPostAccountKeys
+			AccountID int32 `json:"accountid"`
+		Return:
+			The new key, which is only shown once.
+
+			RespPostAccountKeys
+			KeyID string `json:"keyid"`
+			Key   string `json:"key"`
+		Actions:
+			Adds the key to the account.
+	*/
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	var req ReqPostAccountKeys
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request payload: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	account, success := verifyAccountAccess(w, r, req.AccountID)
+	if !success {
+		return
+	}
+	key, apiKey, err := db.Accounts.AddKey(account.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := RespPostAccountKeys{KeyID: apiKey.ID, Key: key}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Revokes an API key of an account
+func DeleteAccountKeys(w http.ResponseWriter, r *http.Request) {
+	/*
+		Input:
+			Account ID, ID of the key to revoke and an API key
+			of the account or its owner as
+			'Authorization: Bearer <key>'.
+
+			ReqDeleteAccountKeys
+			AccountID int32  `json:"accountid"`
+			KeyID     string `json:"keyid"`
+		Return:
+			---
+		Actions:
+			Revokes the key. Games joined with it keep the
+			account, but the key can't be used anymore.
+	*/
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	var req ReqDeleteAccountKeys
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request payload: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	account, success := verifyAccountAccess(w, r, req.AccountID)
+	if !success {
+		return
+	}
+	err = db.Accounts.RevokeKey(account.ID, req.KeyID)
+	if errors.Is(err, db.ErrKeyNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("{}")) // Empty response
+}
+
+// Verifies whether the API key of the request belongs to the account
+// or to its owner.
+func verifyAccountAccess(w http.ResponseWriter, r *http.Request, id int32) (db.Account, bool) {
+	caller, err := db.Accounts.Authenticate(bearerToken(r))
+	if err != nil {
+		http.Error(w, "Invalid API key", http.StatusUnauthorized)
+		return db.Account{}, false
+	}
+	account, err := db.Accounts.Get(id)
+	if err != nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return db.Account{}, false
+	}
+	if caller.ID != account.ID && caller.Name != account.Owner {
+		http.Error(w, "Key belongs neither to the account nor to its owner.", http.StatusForbidden)
+		return db.Account{}, false
+	}
+	return account, true
+}
+
+// Returns the name of an account, "" for anonymous players.
+func accountName(id int32) string {
+	if id == 0 {
+		return ""
+	}
+	account, err := db.Accounts.Get(id)
+	if err != nil {
+		return ""
+	}
+	return account.Name
+}
+
+func accountResponse(account db.Account) RespAccount {
+	return RespAccount{
+		AccountID: account.ID,
+		Name:      account.Name,
+		Owner:     account.Owner,
+		Kind:      account.Kind,
+		Created:   account.CreatedAt,
+	}
+}
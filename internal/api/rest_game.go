@@ -10,17 +10,25 @@ import (
 	"time"
 
 	"github.com/gorilla/schema"
-	"github.com/matetirpak/chess-server-and-api-for-developers/internal/game_logic"
 
 	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
 )
 
+// Time a turn request waits by default and at most.
+const (
+	defaultTurnTimeout = 60 * time.Second
+	maxTurnTimeout     = 10 * time.Minute
+)
+
 // Get the state of a game
 func GetGame(w http.ResponseWriter, r *http.Request) {
 	/*
 		Input:
 			Board ID, password, color and associated token
-			are required.
+			are required. Instead of the password and the
+			token, players can send
+			'Authorization: Bearer <token>', which keeps
+			them out of URLs and logs.
 			Either 'Statereq' or 'Turnreq' have to be true.
 
 			ReqGetGame
@@ -31,18 +39,23 @@ func GetGame(w http.ResponseWriter, r *http.Request) {
 			Token    string `json:"token"`
 			Statereq int32  `json:"statereq"`
 			Turnreq  int32  `json:"turnreq"`
+			TimeoutMs int32 `json:"timeoutms,omitempty"`
 		Return:
 			Either board information or a notification when
 			it's the player's turn.
 
 			If Statereq:
-				game.BoardData of type game_logic.BoardState
+				RespGetGame, the position after Moveidx moves of type
+				game_logic.BoardState, extended by the lifecycle
+				state, the reason the game ended and the clocks.
 			If Turnreq:
 				map[string]string{"message": "It's your turn!"}
+				or {"message": "Game has ended."}
 		Actions:
 			If Turnreq:
-				Holds the call till it's the player's turn, then
-				sends a response as a notification.
+				Holds the call till it's the player's turn or the
+				game ends, then sends a response as a notification.
+				Gives up after TimeoutMs, one minute by default.
 	*/
 
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
@@ -61,54 +74,197 @@ func GetGame(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	success1 := verifyGameAccess(w, req.BoardID, req.Password)
+	game, success1 := verifyGameAccess(w, r, req.BoardID, req.Password)
 	if !success1 {
 		return
 	}
-	var game *db.Game = db.GamesMap[req.BoardID]
 
-	success2 := verifyBoardAccess(w, game, req.Color, req.Token)
+	success2 := verifyBoardAccess(w, r, game, req.Color, req.Token)
 	if !success2 {
 		return
 	}
 
 	if req.Statereq {
+		game.Mu.RLock()
+		defer game.Mu.RUnlock()
 		var idx int = int(req.Moveidx)
 		if idx == -1 {
-			idx = len(game.BoardData) - 1
+			idx = game.Plies()
 		}
-		resp := game.BoardData[idx]
+		if idx < 0 || idx > game.Plies() {
+			http.Error(w, fmt.Sprintf("'moveidx' has to be between 0 and %d, or -1 for the current position.", game.Plies()), http.StatusBadRequest)
+			return
+		}
+		resp := stateResponse(game, idx, time.Now())
 		json.NewEncoder(w).Encode(resp)
 		return
 	}
 	if req.Turnreq {
+		timeout := defaultTurnTimeout
+		if req.TimeoutMs != 0 {
+			timeout = time.Duration(req.TimeoutMs) * time.Millisecond
+		}
+		if timeout <= 0 || timeout > maxTurnTimeout {
+			http.Error(w, fmt.Sprintf("'timeoutms' has to be between 1 and %d.", maxTurnTimeout.Milliseconds()), http.StatusBadRequest)
+			return
+		}
 		w.Header().Set("Connection", "keep-alive")
 
-		timeout := time.After(60 * time.Second)
-		ticker := time.NewTicker(100 * time.Millisecond)
-		defer ticker.Stop()
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
 
 		for {
+			// Read the state and the channel signalling its next
+			// change together, so no change is missed.
+			game.Mu.RLock()
+			currentTurn := game.Position().TurnColor
+			ended := game.Over()
+			deleted := game.Deleted
+			changed := game.Changed()
+			game.Mu.RUnlock()
+
+			if currentTurn == req.Color {
+				response := map[string]string{"message": "It's your turn!"}
+				json.NewEncoder(w).Encode(response)
+				return
+			}
+			if deleted {
+				http.Error(w, `{"message":"Game was deleted"}`, http.StatusGone)
+				return
+			}
+			if ended {
+				response := map[string]string{"message": "Game has ended."}
+				json.NewEncoder(w).Encode(response)
+				return
+			}
+
 			select {
-			case <-timeout:
+			case <-changed:
+			case <-r.Context().Done():
+				// Client is gone, nobody to notify.
+				return
+			case <-timer.C:
 				http.Error(w, `{"message":"Timeout waiting for your turn"}`, http.StatusRequestTimeout)
 				return
-			case <-ticker.C:
-				// Check the current player's turn
-				game.Mu.RLock()
-				currentTurn := game.BoardData[len(game.BoardData)-1].TurnColor
-				game.Mu.RUnlock()
-
-				if currentTurn == req.Color {
-					response := map[string]string{"message": "It's your turn!"}
-					json.NewEncoder(w).Encode(response)
-					return
-				}
 			}
 		}
 	}
 }
 
+// Get the moves played so far
+func GetHistory(w http.ResponseWriter, r *http.Request) {
+	/*
+		Input:
+			Board ID, password, color and associated token.
+			The token can be sent as Bearer token instead of
+			password and token, like with GetGame.
+
+			ReqGetHistory
+			BoardID  int32  `schema:"boardid"`
+			Password string `schema:"password"`
+			Color    string `schema:"color"`
+			Token    string `schema:"token"`
+		Return:
+			All moves with the time the player thought about them.
+			Moves the server played are marked with Fallback
+			after the move time ran out, or with Opening from the
+			opening of an SPRT run.
+
+			RespGetHistory
+			Moves []RespMoveRecord `json:"moves"`
+		Actions:
+			---
+	*/
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	var req ReqGetHistory
+	decoder := schema.NewDecoder()
+	decoder.IgnoreUnknownKeys(true)
+
+	err := decoder.Decode(&req, r.URL.Query())
+	if err != nil {
+		http.Error(w, "Failed to parse query params: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	game, success1 := verifyGameAccess(w, r, req.BoardID, req.Password)
+	if !success1 {
+		return
+	}
+
+	success2 := verifyBoardAccess(w, r, game, req.Color, req.Token)
+	if !success2 {
+		return
+	}
+
+	game.Mu.RLock()
+	defer game.Mu.RUnlock()
+	resp := RespGetHistory{Moves: []RespMoveRecord{}}
+	for _, record := range game.Moves {
+		resp.Moves = append(resp.Moves, moveRecordResponse(record))
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Get the journal of a game
+func GetJournal(w http.ResponseWriter, r *http.Request) {
+	/*
+		Input:
+			Board ID, password, color and associated token.
+			The token can be sent as Bearer token instead of
+			password and token, like with GetGame.
+
+			ReqGetJournal
+			BoardID  int32  `schema:"boardid"`
+			Password string `schema:"password"`
+			Color    string `schema:"color"`
+			Token    string `schema:"token"`
+		Return:
+			Everything that happened in the game, in order:
+			"created", "joined", "started", "move", "drawoffer",
+			"drawdecline", "drawaccept", "forfeit", "timeout",
+			"adjudicated" and "aborted" entries. Checkmate and stalemate
+			follow from the moves.
+
+			RespGetJournal
+			Entries []RespJournalEntry `json:"entries"`
+		Actions:
+			---
+	*/
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	var req ReqGetJournal
+	decoder := schema.NewDecoder()
+	decoder.IgnoreUnknownKeys(true)
+
+	err := decoder.Decode(&req, r.URL.Query())
+	if err != nil {
+		http.Error(w, "Failed to parse query params: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	game, success1 := verifyGameAccess(w, r, req.BoardID, req.Password)
+	if !success1 {
+		return
+	}
+
+	success2 := verifyBoardAccess(w, r, game, req.Color, req.Token)
+	if !success2 {
+		return
+	}
+
+	game.Mu.RLock()
+	defer game.Mu.RUnlock()
+	journal, err := db.Games.Journal(game)
+	if err != nil {
+		http.Error(w, "Failed to read the journal: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := RespGetJournal{Entries: []RespJournalEntry{}}
+	for _, entry := range journal {
+		resp.Entries = append(resp.Entries, journalEntryResponse(game, entry))
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
 // Endpoint to update the player turn for testing purposes
 func UpdateTurn(w http.ResponseWriter, r *http.Request) {
 	type Update struct {
@@ -121,15 +277,21 @@ func UpdateTurn(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf(`{"error":"Invalid request: %v"}`, err), http.StatusBadRequest)
 		return
 	}
-	game, exists := db.GamesMap[req.BoardID]
-	if !exists {
+	game, err := db.Games.Get(req.BoardID)
+	if err != nil {
 		http.Error(w, "Board does not exist.", http.StatusBadRequest)
 		return
 	}
 	// Update the player turn
-	game.Mu.Lock()
-	game.BoardData[len(game.BoardData)-1].TurnColor = req.Turn
-	game.Mu.Unlock()
+	_, err = sendCommand(game, func(game *db.Game) (int, error) {
+		game.Position().TurnColor = req.Turn
+		game.NotifyChanged()
+		return http.StatusOK, nil
+	})
+	if err != nil {
+		http.Error(w, "Board does not exist.", http.StatusBadRequest)
+		return
+	}
 
 	resp := fmt.Sprintf(`{"message":"Turn updated to: %s"}`, req.Turn)
 	json.NewEncoder(w).Encode(resp)
@@ -142,7 +304,9 @@ func PutGame(w http.ResponseWriter, r *http.Request) {
 	/*
 		Input:
 			Board ID, password, color and associated token,
-			and move to be made.
+			and move to be made. Password and token can be
+			replaced by an 'Authorization: Bearer <token>'
+			header.
 
 			ReqPutGame
 			BoardID  int32  `json:"board-id"`
@@ -151,6 +315,10 @@ func PutGame(w http.ResponseWriter, r *http.Request) {
 			Token    string `json:"token"`
 			Move     string `json:"move,omitempty"`
 			Forfeit  bool   `json:"forfeit,omitempty"`
+			Draw     string `json:"draw,omitempty"`
+
+			Draw is "offer", "accept" or "decline". An offer
+			can be made together with a move.
 		Return:
 			---
 		Actions:
@@ -166,55 +334,26 @@ func PutGame(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	success1 := verifyGameAccess(w, req.BoardID, req.Password)
+	game, success1 := verifyGameAccess(w, r, req.BoardID, req.Password)
 	if !success1 {
 		return
 	}
-	var game *db.Game = db.GamesMap[req.BoardID]
 
-	success2 := verifyBoardAccess(w, game, req.Color, req.Token)
+	success2 := verifyBoardAccess(w, r, game, req.Color, req.Token)
 	if !success2 {
 		return
 	}
 
-	if req.Forfeit {
-		game.BoardData[len(game.BoardData)-1].TurnColor = "n"
-		if req.Color == "w" {
-			game.Winner = "b"
-			game.BoardData[len(game.BoardData)-1].Winner = "b"
-		} else {
-			game.Winner = "w"
-			game.BoardData[len(game.BoardData)-1].Winner = "w"
+	status, err := sendCommand(game, func(game *db.Game) (int, error) {
+		if req.Draw != "" && !req.Forfeit {
+			return playDraw(game, req.Color, req.Draw, req.Move)
 		}
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
-	if !game.Started {
-		http.Error(w, "Can't apply move. Game has not started.", http.StatusBadRequest)
-		return
-	}
-
-	if game.Winner != "n" {
-		http.Error(w, "Can't apply move. Game has ended.", http.StatusBadRequest)
-		return
-	}
-
-	move, err := game_logic.StringToMoveStruct(req.Move, rune(req.Color[0]))
-	if err != nil {
-		http.Error(w, "Move format is invalid.", http.StatusBadRequest)
-		return
-	}
-
-	// Check validity of move
-	err = game_logic.ValidateMove(&move, &game.BoardData[len(game.BoardData)-1])
+		return playTurn(game, req.Color, req.Move, req.Forfeit)
+	})
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Move is invalid with error: %v", err), http.StatusBadRequest)
+		http.Error(w, err.Error(), status)
 		return
 	}
 
-	newBstate := game_logic.MakeMove(&move, game.BoardData[len(game.BoardData)-1])
-	game.BoardData = append(game.BoardData, newBstate)
-
 	w.WriteHeader(http.StatusOK)
 }
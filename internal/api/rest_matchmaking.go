@@ -0,0 +1,167 @@
+/*
+API for matchmaking. Accounts ask for a game instead of creating and
+sharing sessions themselves.
+*/
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+)
+
+// Waits for a game against another account
+func PostMatchmaking(w http.ResponseWriter, r *http.Request) {
+	/*
+		Input:
+			API key, in the body or as Bearer token, the time
+			limits of the game like with PostSessions, the
+			variant and optionally the preferred color.
+
+			ReqPostMatchmaking
+			BaseMs        int64  `json:"basems,omitempty"`
+			IncrementMs   int64  `json:"incrementms,omitempty"`
+			DelayMs       int64  `json:"delayms,omitempty"`
+			DelayMode     string `json:"delaymode,omitempty"`
+			MoveTimeMs    int64  `json:"movetimems,omitempty"`
+			OnMoveTimeout string `json:"onmovetimeout,omitempty"`
+			Variant       string `json:"variant,omitempty"`
+			Color         string `json:"color,omitempty"`
+			APIKey        string `json:"apikey,omitempty"`
+			TimeoutMs     int32  `json:"timeoutms,omitempty"`
+
+			Only "standard" chess is played, it is the default
+			variant.
+		Return:
+			The game, the color and the token of the seat, and
+			the name of the opponent. Gives up with 408 after
+			TimeoutMs, one minute by default.
+
+			RespPostMatchmaking
+			BoardID  int32  `json:"boardid"`
+			Color    string `json:"color"`
+			Token    string `json:"token"`
+			Opponent string `json:"opponent"`
+		Actions:
+			Pairs the account with the longest waiting account
+			asking for the same time limits and variant and a
+			different color. The game is created and started
+			with both accounts seated. The password of the
+			game isn't handed out, players send their token or
+			API key as Bearer token. Without a partner, the
+			account waits in the queue until one arrives.
+	*/
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	var req ReqPostMatchmaking
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request payload: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	key := req.APIKey
+	if bearer := bearerToken(r); key == "" && strings.HasPrefix(bearer, db.APIKeyPrefix) {
+		key = bearer
+	}
+	account := keyAccount(key)
+	if account == 0 {
+		http.Error(w, "Matchmaking needs a valid API key", http.StatusUnauthorized)
+		return
+	}
+
+	if req.Variant == "" {
+		req.Variant = VariantStandard
+	}
+	if req.Variant != VariantStandard {
+		http.Error(w, "Invalid variant. Only 'standard' is played.", http.StatusBadRequest)
+		return
+	}
+	if req.Color != "" && req.Color != "w" && req.Color != "b" {
+		http.Error(w, "Invalid color. Enter 'w', 'b' or leave it empty", http.StatusBadRequest)
+		return
+	}
+	timeout := defaultTurnTimeout
+	if req.TimeoutMs != 0 {
+		timeout = time.Duration(req.TimeoutMs) * time.Millisecond
+	}
+	if timeout <= 0 || timeout > maxTurnTimeout {
+		http.Error(w, fmt.Sprintf("'timeoutms' has to be between 1 and %d.", maxTurnTimeout.Milliseconds()), http.StatusBadRequest)
+		return
+	}
+
+	limits := ReqPostSessions{
+		BaseMs:        req.BaseMs,
+		IncrementMs:   req.IncrementMs,
+		DelayMs:       req.DelayMs,
+		DelayMode:     req.DelayMode,
+		MoveTimeMs:    req.MoveTimeMs,
+		OnMoveTimeout: req.OnMoveTimeout,
+	}
+	timeControl, err := newTimeControl(limits)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	moveTime, moveTimeout, err := newMoveTime(limits)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	t := &ticket{
+		account: account,
+		settings: matchSettings{
+			MoveTime:    moveTime,
+			MoveTimeout: moveTimeout,
+			Variant:     req.Variant,
+		},
+		color: req.Color,
+		match: make(chan matchResult, 1),
+	}
+	if timeControl != nil {
+		t.settings.TimeControl = *timeControl
+	}
+
+	result, err := matchmaking.enter(t)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if result == nil {
+		w.Header().Set("Connection", "keep-alive")
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		select {
+		case paired := <-t.match:
+			result = &paired
+		case <-r.Context().Done():
+			// Client is gone. If it was paired meanwhile, nobody
+			// will play its seat.
+			if !matchmaking.leave(t) {
+				abandonMatch(<-t.match)
+			}
+			return
+		case <-timer.C:
+			if matchmaking.leave(t) {
+				http.Error(w, `{"message":"Timeout waiting for an opponent"}`, http.StatusRequestTimeout)
+				return
+			}
+			// Paired while timing out.
+			paired := <-t.match
+			result = &paired
+		}
+	}
+
+	resp := RespPostMatchmaking{
+		BoardID:  result.BoardID,
+		Color:    result.Color,
+		Token:    result.Token,
+		Opponent: accountName(result.Opponent),
+	}
+	json.NewEncoder(w).Encode(resp)
+}
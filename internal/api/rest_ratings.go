@@ -0,0 +1,186 @@
+/*
+API for the ratings of accounts. Ratings are public, no credentials
+are needed.
+*/
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/schema"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/rating"
+)
+
+// Displays the current ratings of an account
+func GetRating(w http.ResponseWriter, r *http.Request) {
+	/*
+		Input:
+			Account ID as query parameter.
+
+			ReqGetRating
+			AccountID int32 `schema:"accountid"`
+		Return:
+			Elo and Glicko-2 ratings of the account in every
+			category it played rated games in: "bullet",
+			"blitz", "rapid", "classical" or "unlimited".
+
+			RespGetRating
+			Account RespAccount           `json:"account"`
+			Ratings map[string]RespRating `json:"ratings"`
+		Actions:
+			---
+	*/
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	var req ReqGetRating
+	if !decodeQuery(w, r, &req) {
+		return
+	}
+
+	account, err := db.Accounts.Get(req.AccountID)
+	if err != nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	resp := RespGetRating{Account: accountResponse(account), Ratings: map[string]RespRating{}}
+	for category, r := range account.Ratings {
+		resp.Ratings[category] = ratingResponse(r)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Displays the rating history of an account
+func GetRatingHistory(w http.ResponseWriter, r *http.Request) {
+	/*
+		Input:
+			Account ID and optionally a category as query
+			parameters.
+
+			ReqGetRatingHistory
+			AccountID int32  `schema:"accountid"`
+			Category  string `schema:"category"`
+		Return:
+			The rating after every rated game, oldest first,
+			e.g. to draw a graph over time.
+
+			RespGetRatingHistory
+			Points []RespRatingPoint `json:"points"`
+		Actions:
+			---
+	*/
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	var req ReqGetRatingHistory
+	if !decodeQuery(w, r, &req) {
+		return
+	}
+	if req.Category != "" && !rating.ValidCategory(req.Category) {
+		http.Error(w, "Invalid category.", http.StatusBadRequest)
+		return
+	}
+
+	account, err := db.Accounts.Get(req.AccountID)
+	if err != nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	resp := RespGetRatingHistory{Points: []RespRatingPoint{}}
+	for _, record := range account.History {
+		if req.Category != "" && record.Category != req.Category {
+			continue
+		}
+		resp.Points = append(resp.Points, RespRatingPoint{
+			Time:     record.Time,
+			BoardID:  record.GameID,
+			Category: record.Category,
+			Opponent: accountName(record.Opponent),
+			Score:    record.Score,
+			Rating:   ratingResponse(record.Rating),
+		})
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Predicts the result of a game between two accounts
+func GetPrediction(w http.ResponseWriter, r *http.Request) {
+	/*
+		Input:
+			Accounts playing white and black and the category
+			of the game as query parameters.
+
+			ReqGetPrediction
+			White    int32  `schema:"white"`
+			Black    int32  `schema:"black"`
+			Category string `schema:"category"`
+		Return:
+			The ratings of both accounts and the expected score
+			of white by Elo and by Glicko-2. The Glicko-2
+			prediction is closer to 0.5 the less certain the
+			ratings are.
+
+			RespGetPrediction
+			Category    string     `json:"category"`
+			White       RespRating `json:"white"`
+			Black       RespRating `json:"black"`
+			EloScore    float64    `json:"eloscore"`
+			GlickoScore float64    `json:"glickoscore"`
+		Actions:
+			---
+	*/
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	var req ReqGetPrediction
+	if !decodeQuery(w, r, &req) {
+		return
+	}
+	if !rating.ValidCategory(req.Category) {
+		http.Error(w, "Invalid category.", http.StatusBadRequest)
+		return
+	}
+
+	white, err := db.Accounts.Get(req.White)
+	if err != nil {
+		http.Error(w, "White account not found", http.StatusNotFound)
+		return
+	}
+	black, err := db.Accounts.Get(req.Black)
+	if err != nil {
+		http.Error(w, "Black account not found", http.StatusNotFound)
+		return
+	}
+
+	whiteRating := white.Rating(req.Category)
+	blackRating := black.Rating(req.Category)
+	resp := RespGetPrediction{
+		Category:    req.Category,
+		White:       ratingResponse(whiteRating),
+		Black:       ratingResponse(blackRating),
+		EloScore:    rating.EloExpected(whiteRating.Elo, blackRating.Elo),
+		GlickoScore: whiteRating.Glicko.Expected(blackRating.Glicko),
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Decodes the query parameters into req. Answers with 400 and
+// returns false if they are invalid.
+func decodeQuery(w http.ResponseWriter, r *http.Request, req interface{}) bool {
+	decoder := schema.NewDecoder()
+	decoder.IgnoreUnknownKeys(true)
+	if err := decoder.Decode(req, r.URL.Query()); err != nil {
+		http.Error(w, "Failed to parse query params: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func ratingResponse(r rating.Rating) RespRating {
+	return RespRating{
+		Elo:        r.Elo,
+		Glicko:     r.Glicko.Rating,
+		RD:         r.Glicko.RD,
+		Volatility: r.Glicko.Volatility,
+		Games:      r.Games,
+	}
+}
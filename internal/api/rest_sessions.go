@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 
 	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
 )
@@ -15,7 +16,8 @@ import (
 func DeleteSessions(w http.ResponseWriter, r *http.Request) {
 	/*
 		Input:
-			Board ID and password.
+			Board ID and password, the password can also be
+			sent as 'Authorization: Bearer <password>'.
 
 			ReqDeleteSessions
 			BoardID  int32  `json:"board-id,omitempty"`
@@ -35,12 +37,22 @@ func DeleteSessions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	success := verifyGameAccess(w, req.BoardID, req.Password)
+	game, success := verifySessionPassword(w, r, req.BoardID, req.Password)
 	if !success {
 		return
 	}
 
-	delete(db.GamesMap, req.BoardID)
+	// Close the game first so its actor stops taking commands.
+	status, err := sendCommand(game, func(game *db.Game) (int, error) {
+		closeGame(game, EventDeleted)
+		return http.StatusOK, nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	db.Games.Delete(req.BoardID)
+
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("{}")) // Empty response
 }
@@ -51,7 +63,9 @@ func GetSessions(w http.ResponseWriter, r *http.Request) {
 		Input:
 			---
 		Return:
-			List of all existing {name, game ID} pairs.
+			List of all existing games with their name, ID,
+			lifecycle state and its timestamps, and the
+			accounts playing them.
 
 		Actions:
 			---
@@ -60,12 +74,26 @@ func GetSessions(w http.ResponseWriter, r *http.Request) {
 
 	var resp RespGetSessions
 
-	// Iterate through the GamesMap and populate the response
-	for _, game := range db.GamesMap {
+	// Iterate through the games and populate the response
+	for _, game := range db.Games.List() {
+		game.Mu.RLock()
 		extracted_game := GameNameAndID{
 			Name:    game.Name,
 			BoardID: game.ID,
+			State:   game.State,
+			Created: game.CreatedAt,
+			White:   accountName(game.W_account),
+			Black:   accountName(game.B_account),
 		}
+		if !game.StartedAt.IsZero() {
+			started := game.StartedAt
+			extracted_game.Started = &started
+		}
+		if !game.EndedAt.IsZero() {
+			ended := game.EndedAt
+			extracted_game.Ended = &ended
+		}
+		game.Mu.RUnlock()
 		// Append the response to the slice
 		resp.Games = append(resp.Games, extracted_game)
 	}
@@ -76,12 +104,26 @@ func GetSessions(w http.ResponseWriter, r *http.Request) {
 func PostSessions(w http.ResponseWriter, r *http.Request) {
 	/*
 		Input:
-			Name of the game to be created.
+			Name of the game to be created and optionally
+			a time control. Without 'basems' the game has
+			no clocks.
 
 			ReqPostSessions
-			Name string `json:"name,omitempty"`
+			Name        string `json:"name"`
+			BaseMs      int64  `json:"basems,omitempty"`
+			IncrementMs int64  `json:"incrementms,omitempty"`
+			DelayMs     int64  `json:"delayms,omitempty"`
+			DelayMode   string `json:"delaymode,omitempty"`
+			MoveTimeMs    int64  `json:"movetimems,omitempty"`
+			OnMoveTimeout string `json:"onmovetimeout,omitempty"`
+
+			A move time limits every move instead of clocks.
+			When exceeded, the player forfeits or the server
+			plays a random move ('onmovetimeout': "random").
 		Return:
-			Unique ID and password to access the game.
+			Unique ID and password to access the game. The
+			server only keeps a hash of the password, it can't
+			be recovered.
 
 			RespPostSessions
 			BoardID  int32  `json:"board-id,omitempty"`
@@ -99,11 +141,21 @@ func PostSessions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	NewGame := initializeNewGame(req.Name)
+	timeControl, err := newTimeControl(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	moveTime, moveTimeout, err := newMoveTime(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	db.GamesMap[NewGame.ID] = NewGame
+	NewGame, password := createSession(req.Name, timeControl, moveTime, moveTimeout)
 
-	resp := RespPostSessions{BoardID: NewGame.ID, Password: NewGame.Password}
+	resp := RespPostSessions{BoardID: NewGame.ID, Password: password}
 	json.NewEncoder(w).Encode(resp)
 }
 
@@ -112,12 +164,21 @@ func PutSessions(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	/*
 		Input:
-			Game ID, password and desired color.
+			Game ID, password and desired color. The password
+			can also be sent as 'Authorization: Bearer <password>'.
+			Accounts add their API key, in the body or as
+			Bearer token next to the password in the body.
 
 			ReqPutSessions
 			BoardID  int32  `json:"board-id,omitempty"`
 			Password string `json:"password,omitempty"`
 			Color    string `json:"color,omitempty"``
+			APIKey   string `json:"apikey,omitempty"`
+			Callback string `json:"callback,omitempty"`
+
+			Stateless bots set Callback to an http(s) URL. The
+			server POSTs ReqWebhook to it on every turn of the
+			color and plays the move of the RespWebhook answer.
 		Return:
 			Token to access the game as the player of
 			the specified color.
@@ -126,7 +187,11 @@ func PutSessions(w http.ResponseWriter, r *http.Request) {
 			Token string `json:"token,omitempty"`
 		Actions:
 			The color in the specified game is reserved for
-			the caller and protected by his token.
+			the caller and protected by his token. With an
+			API key, the game records the account playing the
+			color and the key can be used instead of the token.
+			If the callback fails to answer with a valid move
+			WebhookAttempts times, the color forfeits.
 	*/
 	var req ReqPutSessions
 	err := json.NewDecoder(r.Body).Decode(&req)
@@ -134,48 +199,37 @@ func PutSessions(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("Invalid request payload: %v", err), http.StatusBadRequest)
 		return
 	}
-	success := verifyGameAccess(w, req.BoardID, req.Password)
+	game, success := verifySessionPassword(w, r, req.BoardID, req.Password)
 	if !success {
 		return
 	}
 
-	var game *db.Game = db.GamesMap[req.BoardID]
-
-	if game.HasWPlayer && game.HasBPlayer {
-		http.Error(w, "Game is already full.", http.StatusForbidden)
-		return
+	var account int32
+	key := req.APIKey
+	if bearer := bearerToken(r); key == "" && strings.HasPrefix(bearer, db.APIKeyPrefix) {
+		key = bearer
 	}
-
-	token := generateToken()
-	resp := RespPutSessions{Token: token}
-
-	switch req.Color {
-	case "w":
-		if game.HasWPlayer {
-			http.Error(w, "White is already taken.", http.StatusForbidden)
+	if key != "" {
+		if account = keyAccount(key); account == 0 {
+			http.Error(w, "Invalid API key", http.StatusUnauthorized)
 			return
 		}
-		game.HasWPlayer = true
-		game.W_playerToken = token
-		if game.HasBPlayer {
-			game.Started = true
-		}
-		json.NewEncoder(w).Encode(resp)
+	}
 
-	case "b":
-		if game.HasBPlayer {
-			http.Error(w, "Black is already taken.", http.StatusForbidden)
-			return
-		}
-		game.HasBPlayer = true
-		game.B_playerToken = token
-		if game.HasWPlayer {
-			game.Started = true
-		}
-		json.NewEncoder(w).Encode(resp)
+	if req.Callback != "" && !validCallback(req.Callback) {
+		http.Error(w, "Invalid callback. Enter an http or https URL.", http.StatusBadRequest)
+		return
+	}
 
-	default:
-		http.Error(w, "Invalid color. Enter 'w' or 'b'", http.StatusBadRequest)
+	token := generateToken()
+	status, err := sendCommand(game, func(game *db.Game) (int, error) {
+		return joinGame(game, req.Color, token, account, req.Callback)
+	})
+	if err != nil {
+		http.Error(w, err.Error(), status)
 		return
 	}
+
+	resp := RespPutSessions{Token: token}
+	json.NewEncoder(w).Encode(resp)
 }
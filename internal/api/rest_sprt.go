@@ -0,0 +1,275 @@
+/*
+API for SPRT runs, which decide whether a new version of a bot is
+stronger than the old one.
+*/
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/sprt"
+)
+
+// Starts an SPRT run between two accounts
+func PostSPRT(w http.ResponseWriter, r *http.Request) {
+	/*
+		Input:
+			API key of the owner of both accounts as
+			'Authorization: Bearer <key>', the accounts, the
+			bounds of the test, the opening suite and the time
+			limits of the games like with PostSessions.
+
+			ReqPostSPRT
+			AccountA      int32    `json:"accounta"`
+			AccountB      int32    `json:"accountb"`
+			Openings      []string `json:"openings,omitempty"`
+			Elo0          float64  `json:"elo0,omitempty"`
+			Elo1          float64  `json:"elo1,omitempty"`
+			Alpha         float64  `json:"alpha,omitempty"`
+			Beta          float64  `json:"beta,omitempty"`
+			MaxPairs      int      `json:"maxpairs,omitempty"`
+			Concurrency   int      `json:"concurrency,omitempty"`
+			BaseMs        int64    `json:"basems,omitempty"`
+			IncrementMs   int64    `json:"incrementms,omitempty"`
+			DelayMs       int64    `json:"delayms,omitempty"`
+			DelayMode     string   `json:"delaymode,omitempty"`
+			MoveTimeMs    int64    `json:"movetimems,omitempty"`
+			OnMoveTimeout string   `json:"onmovetimeout,omitempty"`
+
+			The bounds default to elo0 0, elo1 5, alpha and beta
+			0.05.
+		Return:
+			The run.
+
+			RespSPRT
+		Actions:
+			Plays pairs of games from the openings in turn, A
+			has white in the first game of a pair and black in
+			the second. The games are started with both accounts
+			seated, they find them with GetSPRT and play them
+			with their API keys. The run ends once the test
+			accepts a hypothesis or MaxPairs were played.
+			Aborted pairs are played again.
+	*/
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	var req ReqPostSPRT
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request payload: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	a, success := verifyAccountAccess(w, r, req.AccountA)
+	if !success {
+		return
+	}
+	b, success := verifyAccountAccess(w, r, req.AccountB)
+	if !success {
+		return
+	}
+	if a.ID == b.ID {
+		http.Error(w, "An account can't be tested against itself.", http.StatusBadRequest)
+		return
+	}
+	owner, _ := db.Accounts.Authenticate(bearerToken(r))
+
+	config := sprt.DefaultConfig
+	if req.Elo0 != 0 || req.Elo1 != 0 {
+		config.Elo0, config.Elo1 = req.Elo0, req.Elo1
+	}
+	if req.Alpha != 0 {
+		config.Alpha = req.Alpha
+	}
+	if req.Beta != 0 {
+		config.Beta = req.Beta
+	}
+	if err := config.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.MaxPairs < 0 || req.Concurrency < 0 {
+		http.Error(w, "'maxpairs' and 'concurrency' can't be negative.", http.StatusBadRequest)
+		return
+	}
+	if req.Concurrency == 0 {
+		req.Concurrency = 1
+	}
+
+	lines := req.Openings
+	if len(lines) == 0 {
+		lines = DefaultOpenings
+	}
+	var openings [][]string
+	for _, line := range lines {
+		opening, err := parseOpening(line)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		openings = append(openings, opening)
+	}
+
+	limits := ReqPostSessions{
+		BaseMs:        req.BaseMs,
+		IncrementMs:   req.IncrementMs,
+		DelayMs:       req.DelayMs,
+		DelayMode:     req.DelayMode,
+		MoveTimeMs:    req.MoveTimeMs,
+		OnMoveTimeout: req.OnMoveTimeout,
+	}
+	timeControl, err := newTimeControl(limits)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	moveTime, moveTimeout, err := newMoveTime(limits)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	run := &sprtRun{
+		owner:       owner.ID,
+		a:           a.ID,
+		b:           b.ID,
+		config:      config,
+		openings:    openings,
+		maxPairs:    req.MaxPairs,
+		concurrency: req.Concurrency,
+		timeControl: timeControl,
+		moveTime:    moveTime,
+		moveTimeout: moveTimeout,
+	}
+	startSPRT(run)
+	json.NewEncoder(w).Encode(sprtResponse(run))
+}
+
+// Displays SPRT runs
+func GetSPRT(w http.ResponseWriter, r *http.Request) {
+	/*
+		Input:
+			Optionally a run as query parameter.
+
+			ReqGetSPRT
+			RunID int32 `schema:"runid"`
+		Return:
+			The run, or all runs without a RunID, with the
+			results so far and the boards being played.
+
+			RespSPRT, or RespGetSPRT
+			Runs []RespSPRT `json:"runs"`
+		Actions:
+			---
+	*/
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	var req ReqGetSPRT
+	if !decodeQuery(w, r, &req) {
+		return
+	}
+	if req.RunID != 0 {
+		run, exists := sprtRuns.get(req.RunID)
+		if !exists {
+			http.Error(w, "Run not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(sprtResponse(run))
+		return
+	}
+	resp := RespGetSPRT{Runs: []RespSPRT{}}
+	for _, run := range sprtRuns.list() {
+		resp.Runs = append(resp.Runs, sprtResponse(run))
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Stops an SPRT run
+func DeleteSPRT(w http.ResponseWriter, r *http.Request) {
+	/*
+		Input:
+			API key of the account that started the run as
+			'Authorization: Bearer <key>' and the run.
+
+			ReqDeleteSPRT
+			RunID int32 `json:"runid"`
+		Return:
+			The run.
+
+			RespSPRT
+		Actions:
+			Ends the run without a decision and aborts its
+			games.
+	*/
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	var req ReqDeleteSPRT
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request payload: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	caller, err := db.Accounts.Authenticate(bearerToken(r))
+	if err != nil {
+		http.Error(w, "Invalid API key", http.StatusUnauthorized)
+		return
+	}
+	run, exists := sprtRuns.get(req.RunID)
+	if !exists {
+		http.Error(w, "Run not found", http.StatusNotFound)
+		return
+	}
+	if run.owner != caller.ID {
+		http.Error(w, "Only the account that started the run can stop it.", http.StatusForbidden)
+		return
+	}
+	if err := stopSPRT(run); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	json.NewEncoder(w).Encode(sprtResponse(run))
+}
+
+func sprtResponse(run *sprtRun) RespSPRT {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	lower, upper := run.config.Bounds()
+	elo, margin := run.stats.Elo()
+	resp := RespSPRT{
+		RunID:       run.id,
+		State:       run.state,
+		Result:      run.result,
+		AccountA:    accountName(run.a),
+		AccountB:    accountName(run.b),
+		Elo0:        run.config.Elo0,
+		Elo1:        run.config.Elo1,
+		Alpha:       run.config.Alpha,
+		Beta:        run.config.Beta,
+		LLR:         run.stats.LLR(run.config),
+		LowerBound:  lower,
+		UpperBound:  upper,
+		Pairs:       run.stats.Pairs(),
+		Wins:        run.stats.Wins,
+		Draws:       run.stats.Draws,
+		Losses:      run.stats.Losses,
+		Pentanomial: run.stats.Pentanomial,
+		Score:       run.stats.Score(),
+		Elo:         elo,
+		EloMargin:   margin,
+		Playing:     []int32{},
+		Created:     run.createdAt,
+	}
+	for _, pair := range run.pairs {
+		for i, id := range pair.games {
+			if !pair.done[i] {
+				resp.Playing = append(resp.Playing, id)
+			}
+		}
+	}
+	if !run.endedAt.IsZero() {
+		ended := run.endedAt
+		resp.Ended = &ended
+	}
+	return resp
+}
@@ -0,0 +1,336 @@
+/*
+API for statistics of accounts, computed from the results of finished
+games. Like ratings, statistics are public.
+*/
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/rating"
+)
+
+// Ranks accounts by score or rating
+func GetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	/*
+		Input:
+			Optionally the order, a category and the minimum
+			number of games as query parameters. Ordering by
+			"elo" or "glicko" needs a category.
+
+			ReqGetLeaderboard
+			Sort     string `schema:"sort"`
+			Category string `schema:"category"`
+			MinGames int    `schema:"mingames"`
+		Return:
+			Accounts with at least one finished game (or
+			MinGames), best first. The score is the number of
+			points per game.
+
+			RespGetLeaderboard
+			Sort     string          `json:"sort"`
+			Category string          `json:"category"`
+			Entries  []RespRankEntry `json:"entries"`
+		Actions:
+			---
+	*/
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	var req ReqGetLeaderboard
+	if !decodeQuery(w, r, &req) {
+		return
+	}
+	if req.Sort == "" {
+		req.Sort = "score"
+	}
+	if req.Sort != "score" && req.Sort != "elo" && req.Sort != "glicko" {
+		http.Error(w, "'sort' has to be 'score', 'elo' or 'glicko'.", http.StatusBadRequest)
+		return
+	}
+	if req.Category != "" && !rating.ValidCategory(req.Category) {
+		http.Error(w, "Invalid category.", http.StatusBadRequest)
+		return
+	}
+	if req.Category == "" && req.Sort != "score" {
+		http.Error(w, "Ordering by rating needs a category.", http.StatusBadRequest)
+		return
+	}
+	if req.MinGames < 1 {
+		req.MinGames = 1
+	}
+
+	stats := collectStats(req.Category, func(db.GameResult) bool { return true })
+	resp := RespGetLeaderboard{Sort: req.Sort, Category: req.Category, Entries: []RespRankEntry{}}
+	for _, account := range db.Accounts.List() {
+		s, played := stats[account.ID]
+		if !played || s.games() < req.MinGames {
+			continue
+		}
+		entry := RespRankEntry{
+			Account: accountResponse(account),
+			Games:   s.games(),
+			Wins:    s.White.Wins + s.Black.Wins,
+			Draws:   s.White.Draws + s.Black.Draws,
+			Losses:  s.White.Losses + s.Black.Losses,
+			Score:   s.score(),
+		}
+		if req.Category != "" {
+			r := ratingResponse(account.Rating(req.Category))
+			entry.Rating = &r
+		}
+		resp.Entries = append(resp.Entries, entry)
+	}
+
+	key := func(entry RespRankEntry) float64 {
+		switch req.Sort {
+		case "elo":
+			return entry.Rating.Elo
+		case "glicko":
+			return entry.Rating.Glicko
+		}
+		return entry.Score
+	}
+	sort.SliceStable(resp.Entries, func(i, j int) bool {
+		a, b := resp.Entries[i], resp.Entries[j]
+		if key(a) != key(b) {
+			return key(a) > key(b)
+		}
+		return a.Games > b.Games
+	})
+	for i := range resp.Entries {
+		resp.Entries[i].Rank = i + 1
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Displays the statistics of an account
+func GetStats(w http.ResponseWriter, r *http.Request) {
+	/*
+		Input:
+			Account ID and optionally a category as query
+			parameters.
+
+			ReqGetStats
+			AccountID int32  `schema:"accountid"`
+			Category  string `schema:"category"`
+		Return:
+			Results by colour, the average length of games in
+			plies and time, the average time per own move and
+			how the games ended.
+
+			RespGetStats
+			Account      RespAccount    `json:"account"`
+			Games        int            `json:"games"`
+			White        RespColorStats `json:"white"`
+			Black        RespColorStats `json:"black"`
+			AvgPlies     float64        `json:"avgplies"`
+			AvgGameMs    int64          `json:"avggamems"`
+			AvgMoveMs    int64          `json:"avgmovems"`
+			Terminations map[string]int `json:"terminations"`
+		Actions:
+			---
+	*/
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	var req ReqGetStats
+	if !decodeQuery(w, r, &req) {
+		return
+	}
+	if req.Category != "" && !rating.ValidCategory(req.Category) {
+		http.Error(w, "Invalid category.", http.StatusBadRequest)
+		return
+	}
+
+	account, err := db.Accounts.Get(req.AccountID)
+	if err != nil {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	s, played := collectStats(req.Category, func(result db.GameResult) bool {
+		return result.White == account.ID || result.Black == account.ID
+	})[account.ID]
+	if !played {
+		s = &accountStats{Terminations: map[string]int{}}
+	}
+	resp := RespGetStats{
+		Account:      accountResponse(account),
+		Games:        s.games(),
+		White:        s.White.response(),
+		Black:        s.Black.response(),
+		Terminations: s.Terminations,
+	}
+	if games := s.games(); games > 0 {
+		resp.AvgPlies = float64(s.plies) / float64(games)
+		resp.AvgGameMs = (s.duration / time.Duration(games)).Milliseconds()
+	}
+	if s.moves > 0 {
+		resp.AvgMoveMs = (s.think / time.Duration(s.moves)).Milliseconds()
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Displays the results between two accounts
+func GetHeadToHead(w http.ResponseWriter, r *http.Request) {
+	/*
+		Input:
+			Both accounts and optionally a category as query
+			parameters.
+
+			ReqGetHeadToHead
+			A        int32  `schema:"a"`
+			B        int32  `schema:"b"`
+			Category string `schema:"category"`
+		Return:
+			Results of all finished games between A and B, from
+			the view of A, and the IDs of the games.
+
+			RespGetHeadToHead
+			A        RespAccount    `json:"a"`
+			B        RespAccount    `json:"b"`
+			Games    int            `json:"games"`
+			AWins    int            `json:"awins"`
+			Draws    int            `json:"draws"`
+			BWins    int            `json:"bwins"`
+			AWhite   RespColorStats `json:"awhite"`
+			ABlack   RespColorStats `json:"ablack"`
+			BoardIDs []int32        `json:"boardids"`
+		Actions:
+			---
+	*/
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	var req ReqGetHeadToHead
+	if !decodeQuery(w, r, &req) {
+		return
+	}
+	if req.Category != "" && !rating.ValidCategory(req.Category) {
+		http.Error(w, "Invalid category.", http.StatusBadRequest)
+		return
+	}
+	if req.A == req.B {
+		http.Error(w, "'a' and 'b' have to be different accounts.", http.StatusBadRequest)
+		return
+	}
+
+	a, err := db.Accounts.Get(req.A)
+	if err != nil {
+		http.Error(w, "Account 'a' not found", http.StatusNotFound)
+		return
+	}
+	b, err := db.Accounts.Get(req.B)
+	if err != nil {
+		http.Error(w, "Account 'b' not found", http.StatusNotFound)
+		return
+	}
+
+	resp := RespGetHeadToHead{A: accountResponse(a), B: accountResponse(b), BoardIDs: []int32{}}
+	between := func(result db.GameResult) bool {
+		return result.White == a.ID && result.Black == b.ID ||
+			result.White == b.ID && result.Black == a.ID
+	}
+	for _, result := range db.Results.List() {
+		if !between(result) || (req.Category != "" && result.Category != req.Category) {
+			continue
+		}
+		resp.BoardIDs = append(resp.BoardIDs, result.GameID)
+	}
+	if s, played := collectStats(req.Category, between)[a.ID]; played {
+		resp.Games = s.games()
+		resp.AWins = s.White.Wins + s.Black.Wins
+		resp.Draws = s.White.Draws + s.Black.Draws
+		resp.BWins = s.White.Losses + s.Black.Losses
+		resp.AWhite = s.White.response()
+		resp.ABlack = s.Black.response()
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+type colorStats struct {
+	Wins   int
+	Draws  int
+	Losses int
+}
+
+func (s colorStats) games() int {
+	return s.Wins + s.Draws + s.Losses
+}
+
+func (s colorStats) response() RespColorStats {
+	resp := RespColorStats{Games: s.games(), Wins: s.Wins, Draws: s.Draws, Losses: s.Losses}
+	if resp.Games > 0 {
+		resp.Score = (float64(s.Wins) + 0.5*float64(s.Draws)) / float64(resp.Games)
+	}
+	return resp
+}
+
+// Statistics of an account over a set of results.
+type accountStats struct {
+	White        colorStats
+	Black        colorStats
+	Terminations map[string]int
+	plies        int
+	duration     time.Duration
+	moves        int
+	think        time.Duration
+}
+
+func (s *accountStats) games() int {
+	return s.White.games() + s.Black.games()
+}
+
+func (s *accountStats) score() float64 {
+	games := s.games()
+	if games == 0 {
+		return 0
+	}
+	wins := s.White.Wins + s.Black.Wins
+	draws := s.White.Draws + s.Black.Draws
+	return (float64(wins) + 0.5*float64(draws)) / float64(games)
+}
+
+func (s *accountStats) add(result db.GameResult, color string) {
+	c, moves, think := &s.White, result.WhiteMoves, result.WhiteThink
+	if color == "b" {
+		c, moves, think = &s.Black, result.BlackMoves, result.BlackThink
+	}
+	switch result.Winner {
+	case "r":
+		c.Draws++
+	case color:
+		c.Wins++
+	default:
+		c.Losses++
+	}
+	s.Terminations[result.Termination]++
+	s.plies += result.Plies
+	s.duration += result.EndedAt.Sub(result.StartedAt)
+	s.moves += moves
+	s.think += think
+}
+
+// Returns the statistics of every account over the results in a
+// category ("" for all) that pass filter.
+func collectStats(category string, filter func(db.GameResult) bool) map[int32]*accountStats {
+	stats := make(map[int32]*accountStats)
+	add := func(account int32, result db.GameResult, color string) {
+		if account == 0 {
+			return
+		}
+		s, exists := stats[account]
+		if !exists {
+			s = &accountStats{Terminations: map[string]int{}}
+			stats[account] = s
+		}
+		s.add(result, color)
+	}
+	for _, result := range db.Results.List() {
+		if (category != "" && result.Category != category) || !filter(result) {
+			continue
+		}
+		add(result.White, result, "w")
+		add(result.Black, result, "b")
+	}
+	return stats
+}
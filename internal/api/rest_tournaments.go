@@ -0,0 +1,550 @@
+/*
+API for tournaments between accounts. Users create tournaments and
+register their bots, the server pairs the rounds and creates their
+games. Standings, crosstables and brackets are public.
+*/
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/tournament"
+)
+
+// Creates a tournament
+func PostTournaments(w http.ResponseWriter, r *http.Request) {
+	/*
+		Input:
+			API key of a user as 'Authorization: Bearer <key>',
+			name and format of the tournament and the time
+			limits of its games like with PostSessions. Swiss
+			tournaments need the number of rounds. Knockout
+			matches have 2 games by default, tied matches go on
+			with the tiebreak games and finally an armageddon
+			game, in which a draw lets black advance.
+
+			ReqPostTournaments
+			Name          string `json:"name"`
+			Format        string `json:"format"`
+			Rounds        int    `json:"rounds,omitempty"`
+			BaseMs        int64  `json:"basems,omitempty"`
+			IncrementMs   int64  `json:"incrementms,omitempty"`
+			DelayMs       int64  `json:"delayms,omitempty"`
+			DelayMode     string `json:"delaymode,omitempty"`
+			MoveTimeMs    int64  `json:"movetimems,omitempty"`
+			OnMoveTimeout string `json:"onmovetimeout,omitempty"`
+			GamesPerMatch       int   `json:"gamespermatch,omitempty"`
+			TiebreakGames       int   `json:"tiebreakgames,omitempty"`
+			TiebreakBaseMs      int64 `json:"tiebreakbasems,omitempty"`
+			TiebreakIncrementMs int64 `json:"tiebreakincrementms,omitempty"`
+		Return:
+			The tournament.
+
+			RespTournament
+		Actions:
+			Creates the tournament, taking registrations until
+			the user starts it.
+	*/
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	var req ReqPostTournaments
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request payload: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	user, err := db.Accounts.Authenticate(bearerToken(r))
+	if err != nil {
+		http.Error(w, "Tournaments are created with the API key of a user.", http.StatusUnauthorized)
+		return
+	}
+	if user.Kind != db.AccountUser {
+		http.Error(w, "Bots can't create tournaments.", http.StatusForbidden)
+		return
+	}
+	if !tournament.ValidFormat(req.Format) {
+		http.Error(w, "Invalid format. Enter 'roundrobin', 'doubleroundrobin', 'swiss' or 'knockout'", http.StatusBadRequest)
+		return
+	}
+	if req.Format == tournament.Swiss && req.Rounds < 1 {
+		http.Error(w, "'rounds' has to be positive for Swiss tournaments.", http.StatusBadRequest)
+		return
+	}
+	if req.Format != tournament.Swiss && req.Rounds != 0 {
+		http.Error(w, "'rounds' follows from the number of players.", http.StatusBadRequest)
+		return
+	}
+	if req.Format != tournament.Knockout && (req.GamesPerMatch != 0 || req.TiebreakGames != 0 ||
+		req.TiebreakBaseMs != 0 || req.TiebreakIncrementMs != 0) {
+		http.Error(w, "Matches and tiebreaks are only played in knockout tournaments.", http.StatusBadRequest)
+		return
+	}
+	if req.Format == tournament.Knockout && req.GamesPerMatch == 0 {
+		req.GamesPerMatch = 2
+	}
+	if req.GamesPerMatch < 0 || req.TiebreakGames < 0 {
+		http.Error(w, "'gamespermatch' and 'tiebreakgames' can't be negative.", http.StatusBadRequest)
+		return
+	}
+
+	limits := ReqPostSessions{
+		BaseMs:        req.BaseMs,
+		IncrementMs:   req.IncrementMs,
+		DelayMs:       req.DelayMs,
+		DelayMode:     req.DelayMode,
+		MoveTimeMs:    req.MoveTimeMs,
+		OnMoveTimeout: req.OnMoveTimeout,
+	}
+	timeControl, err := newTimeControl(limits)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	moveTime, moveTimeout, err := newMoveTime(limits)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tiebreakControl, err := newTimeControl(ReqPostSessions{
+		BaseMs:      req.TiebreakBaseMs,
+		IncrementMs: req.TiebreakIncrementMs,
+	})
+	if err != nil {
+		http.Error(w, "Tiebreak: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	t, err := db.Tournaments.Create(db.Tournament{
+		Name:        req.Name,
+		Format:      req.Format,
+		Rounds:      req.Rounds,
+		Owner:       user.ID,
+		TimeControl: timeControl,
+		MoveTime:    moveTime,
+		MoveTimeout: moveTimeout,
+
+		GamesPerMatch:   req.GamesPerMatch,
+		TiebreakGames:   req.TiebreakGames,
+		TiebreakControl: tiebreakControl,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(tournamentResponse(t))
+}
+
+// Displays all tournaments
+func GetTournaments(w http.ResponseWriter, r *http.Request) {
+	/*
+		Input:
+			---
+		Return:
+			All tournaments with their state and players.
+
+			RespGetTournaments
+			Tournaments []RespTournament `json:"tournaments"`
+		Actions:
+			---
+	*/
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	resp := RespGetTournaments{Tournaments: []RespTournament{}}
+	for _, t := range db.Tournaments.List() {
+		resp.Tournaments = append(resp.Tournaments, tournamentResponse(t))
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Registers an account for a tournament
+func PutTournaments(w http.ResponseWriter, r *http.Request) {
+	/*
+		Input:
+			API key of the account or of its owner as
+			'Authorization: Bearer <key>', the tournament and
+			the account.
+
+			ReqPutTournaments
+			TournamentID int32 `json:"tournamentid"`
+			AccountID    int32 `json:"accountid"`
+		Return:
+			The tournament.
+
+			RespTournament
+		Actions:
+			Adds the account to the players. The order of
+			registration is the seeding.
+	*/
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	var req ReqPutTournaments
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request payload: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	account, success := verifyAccountAccess(w, r, req.AccountID)
+	if !success {
+		return
+	}
+	err = db.Tournaments.Register(req.TournamentID, account.ID)
+	switch {
+	case errors.Is(err, db.ErrTournamentMissing):
+		http.Error(w, "Tournament not found", http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	t, _ := db.Tournaments.Get(req.TournamentID)
+	json.NewEncoder(w).Encode(tournamentResponse(t))
+}
+
+// Starts a tournament
+func PostTournamentStart(w http.ResponseWriter, r *http.Request) {
+	/*
+		Input:
+			API key of the creator as 'Authorization: Bearer
+			<key>' and the tournament.
+
+			ReqPostTournamentStart
+			TournamentID int32 `json:"tournamentid"`
+		Return:
+			The tournament.
+
+			RespTournament
+		Actions:
+			Closes the registration and creates the games of
+			the first round with both players seated. Players
+			find their games with GetTournamentPairings and
+			play them with their API keys. Each round starts
+			once all games of the previous one ended.
+	*/
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	var req ReqPostTournamentStart
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request payload: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	user, err := db.Accounts.Authenticate(bearerToken(r))
+	if err != nil {
+		http.Error(w, "Invalid API key", http.StatusUnauthorized)
+		return
+	}
+	t, err := db.Tournaments.Get(req.TournamentID)
+	if err != nil {
+		http.Error(w, "Tournament not found", http.StatusNotFound)
+		return
+	}
+	if t.Owner != user.ID {
+		http.Error(w, "Only the creator can start the tournament.", http.StatusForbidden)
+		return
+	}
+	t, err = startTournament(t)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	json.NewEncoder(w).Encode(tournamentResponse(t))
+}
+
+// Displays the standings of a tournament
+func GetTournamentStandings(w http.ResponseWriter, r *http.Request) {
+	/*
+		Input:
+			Tournament ID as query parameter.
+
+			ReqGetTournament
+			TournamentID int32 `schema:"tournamentid"`
+		Return:
+			The players ordered by points, ties are broken by
+			Sonneborn-Berger and then Buchholz in round robins,
+			the other way round in Swiss tournaments. A bye is
+			worth a point.
+
+			RespGetStandings
+			Tournament RespTournament `json:"tournament"`
+			TieBreaks  []string       `json:"tiebreaks"`
+			Standings  []RespStanding `json:"standings"`
+		Actions:
+			---
+	*/
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	t, success := tournamentOfQuery(w, r, nil)
+	if !success {
+		return
+	}
+
+	tieBreaks := tournament.TieBreaks(t.Format)
+	resp := RespGetStandings{Tournament: tournamentResponse(t), TieBreaks: tieBreaks, Standings: []RespStanding{}}
+	for _, s := range tournament.Standings(t.Players, t.Results(), tieBreaks) {
+		resp.Standings = append(resp.Standings, RespStanding{
+			Rank:            s.Rank,
+			Account:         accountName(s.Player),
+			AccountID:       s.Player,
+			Points:          s.Points,
+			Games:           s.Games,
+			Wins:            s.Wins,
+			Draws:           s.Draws,
+			Losses:          s.Losses,
+			Byes:            s.Byes,
+			SonnebornBerger: s.SonnebornBerger,
+			Buchholz:        s.Buchholz,
+		})
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Displays the pairings of a tournament
+func GetTournamentPairings(w http.ResponseWriter, r *http.Request) {
+	/*
+		Input:
+			Tournament ID and optionally a round as query
+			parameters.
+
+			ReqGetTournament
+			TournamentID int32 `schema:"tournamentid"`
+			Round        int   `schema:"round"`
+		Return:
+			The games of the round, or of all rounds so far,
+			with their boards and results.
+
+			RespGetPairings
+			Pairings []RespPairing `json:"pairings"`
+		Actions:
+			---
+	*/
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	var req ReqGetTournament
+	t, success := tournamentOfQuery(w, r, &req)
+	if !success {
+		return
+	}
+
+	resp := RespGetPairings{Pairings: []RespPairing{}}
+	for _, game := range t.Games {
+		if req.Round != 0 && game.Round != req.Round {
+			continue
+		}
+		pairing := RespPairing{
+			Round:   game.Round,
+			White:   accountName(game.White),
+			Black:   accountName(game.Black),
+			BoardID: game.GameID,
+			Kind:    game.Kind,
+		}
+		switch {
+		case game.Black == 0:
+			pairing.Result = "bye"
+		case game.Done:
+			pairing.Result = resultString(game.WhiteScore) + "-" + resultString(game.BlackScore)
+		}
+		resp.Pairings = append(resp.Pairings, pairing)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Displays the crosstable of a tournament
+func GetTournamentCrosstable(w http.ResponseWriter, r *http.Request) {
+	/*
+		Input:
+			Tournament ID as query parameter.
+
+			ReqGetTournament
+			TournamentID int32 `schema:"tournamentid"`
+		Return:
+			A row per player in the order of the standings,
+			with the scores against every other player and the
+			results round by round.
+
+			RespGetCrosstable
+			Players []string       `json:"players"`
+			Rows    []RespCrossRow `json:"rows"`
+		Actions:
+			---
+	*/
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	t, success := tournamentOfQuery(w, r, nil)
+	if !success {
+		return
+	}
+
+	standings := tournament.Standings(t.Players, t.Results(), tournament.TieBreaks(t.Format))
+	column := make(map[int32]int, len(standings))
+	resp := RespGetCrosstable{Players: []string{}, Rows: []RespCrossRow{}}
+	for i, s := range standings {
+		column[s.Player] = i
+		resp.Players = append(resp.Players, accountName(s.Player))
+	}
+	for i, s := range standings {
+		row := RespCrossRow{
+			Rank:    s.Rank,
+			Account: accountName(s.Player),
+			Points:  s.Points,
+			Cells:   make([]string, len(standings)),
+			Rounds:  []RespCrossGame{},
+		}
+		row.Cells[i] = "x"
+		for _, result := range t.Results() {
+			var game RespCrossGame
+			switch s.Player {
+			case result.White:
+				game = RespCrossGame{Round: result.Round, Opponent: accountName(result.Black), Color: "w", Score: result.WhiteScore}
+				if result.Black != 0 {
+					row.Cells[column[result.Black]] += crossString(result.WhiteScore)
+				}
+			case result.Black:
+				game = RespCrossGame{Round: result.Round, Opponent: accountName(result.White), Color: "b", Score: result.BlackScore}
+				row.Cells[column[result.White]] += crossString(result.BlackScore)
+			default:
+				continue
+			}
+			if game.Opponent == "" {
+				game.Color = ""
+			}
+			row.Rounds = append(row.Rounds, game)
+		}
+		resp.Rows = append(resp.Rows, row)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Displays the bracket of a knockout tournament
+func GetTournamentBracket(w http.ResponseWriter, r *http.Request) {
+	/*
+		Input:
+			Tournament ID as query parameter.
+
+			ReqGetTournament
+			TournamentID int32 `schema:"tournamentid"`
+		Return:
+			All rounds of the bracket with the scores and games
+			of their matches. Players of matches that aren't
+			decided yet are empty.
+
+			RespGetBracket
+			Tournament RespTournament     `json:"tournament"`
+			Rounds     []RespBracketRound `json:"rounds"`
+		Actions:
+			---
+	*/
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	t, success := tournamentOfQuery(w, r, nil)
+	if !success {
+		return
+	}
+	if t.Format != tournament.Knockout {
+		http.Error(w, "Only knockout tournaments have a bracket.", http.StatusBadRequest)
+		return
+	}
+
+	resp := RespGetBracket{Tournament: tournamentResponse(t), Rounds: []RespBracketRound{}}
+	for i, matches := range knockoutBracket(t) {
+		round := RespBracketRound{Round: i + 1, Matches: []RespBracketMatch{}}
+		for j, match := range matches {
+			entry := RespBracketMatch{
+				Match:  j + 1,
+				A:      accountName(match.A),
+				B:      accountName(match.B),
+				Winner: accountName(match.Winner(t.MatchConfig())),
+				Games:  []RespPairing{},
+			}
+			for _, game := range t.Games {
+				if game.Round != round.Round || game.Match != j || game.Black == 0 {
+					continue
+				}
+				pairing := RespPairing{
+					Round:   game.Round,
+					White:   accountName(game.White),
+					Black:   accountName(game.Black),
+					BoardID: game.GameID,
+					Kind:    game.Kind,
+				}
+				if game.Done {
+					pairing.Result = resultString(game.WhiteScore) + "-" + resultString(game.BlackScore)
+				}
+				entry.Games = append(entry.Games, pairing)
+			}
+			for _, kind := range []string{tournament.GameRegular, tournament.GameTiebreak, tournament.GameArmageddon} {
+				a, b, _ := match.Score(kind)
+				entry.AScore += a
+				entry.BScore += b
+			}
+			round.Matches = append(round.Matches, entry)
+		}
+		resp.Rounds = append(resp.Rounds, round)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Decodes the query parameters into req, or a ReqGetTournament if
+// req is nil, and returns the tournament they name. Answers with an
+// error and returns false if that fails.
+func tournamentOfQuery(w http.ResponseWriter, r *http.Request, req *ReqGetTournament) (db.Tournament, bool) {
+	if req == nil {
+		req = &ReqGetTournament{}
+	}
+	if !decodeQuery(w, r, req) {
+		return db.Tournament{}, false
+	}
+	t, err := db.Tournaments.Get(req.TournamentID)
+	if err != nil {
+		http.Error(w, "Tournament not found", http.StatusNotFound)
+		return db.Tournament{}, false
+	}
+	return t, true
+}
+
+// Writes a score like on score sheets.
+func resultString(score float64) string {
+	switch score {
+	case 1:
+		return "1"
+	case 0.5:
+		return "1/2"
+	}
+	return "0"
+}
+
+// Writes a score like in crosstables, where the scores of several
+// games against the same player are joined.
+func crossString(score float64) string {
+	if score == 0.5 {
+		return "½"
+	}
+	return resultString(score)
+}
+
+func tournamentResponse(t db.Tournament) RespTournament {
+	resp := RespTournament{
+		TournamentID: t.ID,
+		Name:         t.Name,
+		Format:       t.Format,
+		State:        t.State,
+		Rounds:       t.Rounds,
+		Round:        t.Round,
+		Owner:        accountName(t.Owner),
+		Players:      []string{},
+		Created:      t.CreatedAt,
+
+		GamesPerMatch: t.GamesPerMatch,
+		TiebreakGames: t.TiebreakGames,
+	}
+	for _, player := range t.Players {
+		resp.Players = append(resp.Players, accountName(player))
+	}
+	if !t.StartedAt.IsZero() {
+		started := t.StartedAt
+		resp.Started = &started
+	}
+	if !t.EndedAt.IsZero() {
+		ended := t.EndedAt
+		resp.Ended = &ended
+	}
+	return resp
+}
@@ -4,38 +4,67 @@ Helper functions for API communication and game setup.
 package api
 
 import (
+	"crypto/subtle"
+	"errors"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 
 	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
-	"github.com/matetirpak/chess-server-and-api-for-developers/internal/game_logic"
 )
 
-// Verifies whether a user has access to a session.
-func verifyGameAccess(w http.ResponseWriter, id int32, password string) bool {
-	game, exists := db.GamesMap[id]
-	if !exists {
+// Verifies whether a user has read access to a session. Without a
+// password in the request, the Authorization header is used. It
+// holds either the session password, the token of a player or the
+// API key of the account playing a color:
+//
+//	Authorization: Bearer <password, token or API key>
+func verifyGameAccess(w http.ResponseWriter, r *http.Request, id int32, password string) (*db.Game, bool) {
+	return verifySession(w, r, id, password, true)
+}
+
+// Verifies the password of a session, for changing the session
+// itself. Tokens and API keys of the players aren't accepted, so a
+// player can't delete the game or take the other color.
+func verifySessionPassword(w http.ResponseWriter, r *http.Request, id int32, password string) (*db.Game, bool) {
+	return verifySession(w, r, id, password, false)
+}
+
+func verifySession(w http.ResponseWriter, r *http.Request, id int32, password string, players bool) (*db.Game, bool) {
+	if password == "" {
+		password = bearerToken(r)
+	}
+	game, err := db.Games.Get(id)
+	if err != nil {
 		http.Error(w, "Board not found", http.StatusNotFound)
-		return false
+		return nil, false
 	}
-	if password != game.Password {
+	if !game.CheckPassword(password) && !(players && isPlayer(game, password)) {
 		http.Error(w, "Invalid password", http.StatusUnauthorized)
-		return false
+		return nil, false
 	}
-	return true
+	return game, true
 }
 
 // Verifies whether a user is registered as a player and has access to a session.
-func verifyBoardAccess(w http.ResponseWriter, game *db.Game, color string, token string) bool {
-	// Both ip and token have to match with the database, session password is not required.
+// Without a token in the request, the Authorization header is used.
+// The API key of the account playing the color works as well.
+func verifyBoardAccess(w http.ResponseWriter, r *http.Request, game *db.Game, color string, token string) bool {
+	if token == "" {
+		token = bearerToken(r)
+	}
+	account := keyAccount(token)
+	game.Mu.RLock()
+	defer game.Mu.RUnlock()
 	switch color {
 	case "w":
 		if !game.HasWPlayer {
 			http.Error(w, "White player does not exist.", http.StatusNotFound)
 			return false
 		}
-		if game.W_playerToken != token {
+		if !seatMatches(game.W_playerToken, game.W_account, token, account) {
 			http.Error(w, "Token is invalid.", http.StatusUnauthorized)
 			return false
 		}
@@ -46,7 +75,7 @@ func verifyBoardAccess(w http.ResponseWriter, game *db.Game, color string, token
 			http.Error(w, "Black player does not exist.", http.StatusNotFound)
 			return false
 		}
-		if game.B_playerToken != token {
+		if !seatMatches(game.B_playerToken, game.B_account, token, account) {
 			http.Error(w, "Token is invalid.", http.StatusUnauthorized)
 			return false
 		}
@@ -58,19 +87,135 @@ func verifyBoardAccess(w http.ResponseWriter, game *db.Game, color string, token
 	}
 }
 
+// Returns the credential of an "Authorization: Bearer" header, ""
+// if there is none.
+func bearerToken(r *http.Request) string {
+	scheme, credential, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(credential)
+}
+
+// Compares a secret to the expected one in constant time. Empty
+// secrets never match.
+func secretsEqual(expected string, secret string) bool {
+	return expected != "" && subtle.ConstantTimeCompare([]byte(expected), []byte(secret)) == 1
+}
+
+// Returns the ID of the account an API key belongs to, 0 if secret
+// isn't a valid key.
+func keyAccount(secret string) int32 {
+	if !strings.HasPrefix(secret, db.APIKeyPrefix) {
+		return 0
+	}
+	account, err := db.Accounts.Authenticate(secret)
+	if err != nil {
+		return 0
+	}
+	return account.ID
+}
+
+// Reports whether secret is the token of a seat, or account the key
+// of the account playing it.
+func seatMatches(token string, seatAccount int32, secret string, account int32) bool {
+	return secretsEqual(token, secret) || (account != 0 && seatAccount == account)
+}
+
+// Reports whether secret is the token or API key of one of the
+// players.
+func isPlayer(game *db.Game, secret string) bool {
+	account := keyAccount(secret)
+	game.Mu.RLock()
+	defer game.Mu.RUnlock()
+	white := seatMatches(game.W_playerToken, game.W_account, secret, account)
+	black := seatMatches(game.B_playerToken, game.B_account, secret, account)
+	return white || black
+}
+
+// Reserves a color for the holder of token, played by the given
+// account or anonymously if it is 0. With a callback URL, the server
+// asks it for the moves of the color, see webhook.go. Starts the game
+// once both colors are taken. Called with game.Mu held.
+func joinGame(game *db.Game, color string, token string, account int32, callback string) (int, error) {
+	if game.HasWPlayer && game.HasBPlayer {
+		return http.StatusForbidden, errors.New("Game is already full.")
+	}
+
+	switch color {
+	case "w":
+		if game.HasWPlayer {
+			return http.StatusForbidden, errors.New("White is already taken.")
+		}
+		game.HasWPlayer = true
+		game.W_playerToken = token
+		game.W_account = account
+		game.W_callback = callback
+	case "b":
+		if game.HasBPlayer {
+			return http.StatusForbidden, errors.New("Black is already taken.")
+		}
+		game.HasBPlayer = true
+		game.B_playerToken = token
+		game.B_account = account
+		game.B_callback = callback
+	default:
+		return http.StatusBadRequest, errors.New("Invalid color. Enter 'w' or 'b'")
+	}
+
+	journal(game, db.JournalEntry{Type: db.JournalJoined, Color: color, Token: token, Account: account, Callback: callback})
+	publishJoined(game, color)
+	if game.HasWPlayer && game.HasBPlayer {
+		startGame(game)
+	}
+	return http.StatusOK, nil
+}
+
+// Closes a game that is about to be removed from the repository, so
+// its actor stops taking commands. Called with game.Mu held.
+func closeGame(game *db.Game, eventType string) {
+	stopTimers(game)
+	game.Deleted = true
+	publishRemoved(game, eventType)
+}
+
 func generateToken() string {
 	return uuid.New().String()
 }
 
-func initializeNewGame(name string) *db.Game {
+// Creates and stores a new game with the given time limits. Returns
+// the game and its password.
+func createSession(name string, timeControl *db.TimeControl, moveTime time.Duration, moveTimeout string) (*db.Game, string) {
+	game, password := initializeNewGame(name)
+	game.TimeControl = timeControl
+	game.MoveTime = moveTime
+	game.MoveTimeout = moveTimeout
+	game.Log(db.JournalEntry{
+		Type:         db.JournalCreated,
+		Time:         game.CreatedAt,
+		Name:         game.Name,
+		PasswordHash: game.PasswordHash,
+		TimeControl:  timeControl,
+		MoveTime:     moveTime,
+		MoveTimeout:  moveTimeout,
+	})
+
+	db.Games.Create(game)
+	return game, password
+}
+
+// Returns a new game and its password, of which only the hash is
+// kept.
+func initializeNewGame(name string) (*db.Game, string) {
 	var game db.Game
+	password := generateToken()
 	game.Name = name
-	game.ID = db.Ids
-	db.Ids++
-	game.Password = generateToken()
+	game.PasswordHash = db.HashSecret(password)
 	game.HasWPlayer = false
 	game.HasBPlayer = false
 	game.Winner = "n"
-	game_logic.InitializeBoard(&game.BoardData)
-	return &game
+	game.State = db.StateWaiting
+	game.CreatedAt = time.Now()
+	game.ResetBoard()
+	return &game, password
 }
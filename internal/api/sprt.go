@@ -0,0 +1,328 @@
+/*
+SPRT runs between two accounts, usually a new and an old version of a
+bot. The server plays pairs of games from an opening suite with
+swapped colours until the test decides, and the bots play them with
+their API keys. Runs are kept in memory and end with the server.
+*/
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/game_logic"
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/sprt"
+)
+
+// States of SPRT runs.
+const (
+	SPRTRunning  = "running"
+	SPRTFinished = "finished" // Decided, or all pairs were played.
+	SPRTStopped  = "stopped"
+)
+
+// Openings played if a run doesn't bring its own.
+var DefaultOpenings = []string{
+	"e2 e4, e7 e5",
+	"e2 e4, c7 c5",
+	"e2 e4, e7 e6",
+	"e2 e4, c7 c6",
+	"d2 d4, d7 d5",
+	"d2 d4, g8 f6",
+	"c2 c4, e7 e5",
+	"g1 f3, d7 d5",
+}
+
+// Run between the candidate A and the baseline B.
+type sprtRun struct {
+	mu          sync.Mutex
+	id          int32
+	owner       int32
+	a           int32
+	b           int32
+	config      sprt.Config
+	openings    [][]string
+	maxPairs    int // 0 for no limit.
+	concurrency int // Pairs played at once.
+	timeControl *db.TimeControl
+	moveTime    time.Duration
+	moveTimeout string
+
+	state       string
+	result      string // sprt.H0 or sprt.H1 once decided.
+	stats       sprt.Stats
+	started     int   // Pairs started, replays included.
+	nextOpening int   // Index of the next opening in the suite.
+	replays     []int // Openings of aborted pairs, played again.
+	pairs       []*sprtPair
+	createdAt   time.Time
+	endedAt     time.Time
+}
+
+// Pair being played. A has white in the first game.
+type sprtPair struct {
+	opening int
+	games   [2]int32
+	scores  [2]float64 // Of A.
+	done    [2]bool
+	aborted bool
+}
+
+// Created game waiting for its players.
+type sprtGame struct {
+	game    *db.Game
+	white   int32
+	black   int32
+	opening []string
+}
+
+type sprtRegistry struct {
+	mu     sync.Mutex
+	runs   []*sprtRun
+	byGame map[int32]*sprtRun
+}
+
+var sprtRuns = &sprtRegistry{byGame: make(map[int32]*sprtRun)}
+
+func (reg *sprtRegistry) add(run *sprtRun) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	run.id = int32(len(reg.runs) + 1)
+	reg.runs = append(reg.runs, run)
+}
+
+func (reg *sprtRegistry) get(id int32) (*sprtRun, bool) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if id < 1 || int(id) > len(reg.runs) {
+		return nil, false
+	}
+	return reg.runs[id-1], true
+}
+
+func (reg *sprtRegistry) list() []*sprtRun {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	return append([]*sprtRun(nil), reg.runs...)
+}
+
+func (reg *sprtRegistry) track(gameID int32, run *sprtRun) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.byGame[gameID] = run
+}
+
+func (reg *sprtRegistry) ofGame(gameID int32) *sprtRun {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	return reg.byGame[gameID]
+}
+
+// Parses an opening like "e2 e4, e7 e5" and checks that its moves
+// are legal from the initial position.
+func parseOpening(line string) ([]string, error) {
+	var moves []string
+	var game db.Game
+	game.ResetBoard()
+	for _, field := range strings.Split(line, ",") {
+		moveStr := strings.TrimSpace(field)
+		if moveStr == "" {
+			continue
+		}
+		color := game.Position().TurnColor
+		move, err := game_logic.StringToMoveStruct(moveStr, rune(color[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid move '%s' in opening '%s'", moveStr, line)
+		}
+		if err := game_logic.ValidateMove(&move, game.Position()); err != nil {
+			return nil, fmt.Errorf("illegal move '%s' in opening '%s'", moveStr, line)
+		}
+		game.PushMove(move, db.MoveRecord{Color: color, Move: moveStr})
+		moves = append(moves, moveStr)
+	}
+	return moves, nil
+}
+
+// Starts the first pairs of a new run.
+func startSPRT(run *sprtRun) {
+	run.mu.Lock()
+	run.state = SPRTRunning
+	run.createdAt = time.Now()
+	sprtRuns.add(run)
+	games := run.fill()
+	run.mu.Unlock()
+	seatSPRTGames(games)
+}
+
+// Creates the games of the pairs that are due. They are seated by
+// seatSPRTGames once run.mu is released. Called with run.mu held.
+func (run *sprtRun) fill() []sprtGame {
+	var games []sprtGame
+	for run.state == SPRTRunning && len(run.pairs) < run.concurrency &&
+		(run.maxPairs == 0 || run.stats.Pairs()+len(run.pairs) < run.maxPairs) {
+		pair := &sprtPair{}
+		if len(run.replays) > 0 {
+			pair.opening, run.replays = run.replays[0], run.replays[1:]
+		} else {
+			pair.opening = run.nextOpening
+			run.nextOpening = (run.nextOpening + 1) % len(run.openings)
+		}
+		run.started++
+		for i, white := range []int32{run.a, run.b} {
+			black := run.a + run.b - white
+			var timeControl *db.TimeControl
+			if run.timeControl != nil {
+				tc := *run.timeControl
+				timeControl = &tc
+			}
+			name := fmt.Sprintf("SPRT %d, pair %d, game %d", run.id, run.started, i+1)
+			game, _ := createSession(name, timeControl, run.moveTime, run.moveTimeout)
+			pair.games[i] = game.ID
+			sprtRuns.track(game.ID, run)
+			games = append(games, sprtGame{game: game, white: white, black: black, opening: run.openings[pair.opening]})
+		}
+		run.pairs = append(run.pairs, pair)
+	}
+	return games
+}
+
+// Seats the players of created games, which starts them, and plays
+// the opening.
+func seatSPRTGames(games []sprtGame) {
+	for _, entry := range games {
+		sendCommand(entry.game, func(game *db.Game) (int, error) {
+			if game.Over() {
+				// Aborted when the run was stopped.
+				return http.StatusOK, nil
+			}
+			joinGame(game, "w", generateToken(), entry.white, "")
+			joinGame(game, "b", generateToken(), entry.black, "")
+			for _, moveStr := range entry.opening {
+				if game.Over() {
+					break
+				}
+				move, _ := game_logic.StringToMoveStruct(moveStr, rune(currentTurn(game)[0]))
+				if err := game_logic.ValidateMove(&move, game.Position()); err != nil {
+					return http.StatusInternalServerError, err
+				}
+				applyMove(game, move, time.Now(), moveOpening)
+			}
+			return http.StatusOK, nil
+		})
+	}
+}
+
+// Ends a run and returns its unfinished games. Called with run.mu
+// held.
+func (run *sprtRun) finish(state string, result string) []int32 {
+	run.state = state
+	run.result = result
+	run.endedAt = time.Now()
+	var playing []int32
+	for _, pair := range run.pairs {
+		for i, id := range pair.games {
+			if !pair.done[i] {
+				playing = append(playing, id)
+			}
+		}
+	}
+	return playing
+}
+
+// Records the result of a finished or aborted game if it belongs to
+// a run, and starts the next pair or ends the run once a pair is
+// complete. Aborted pairs are played again. Called with game.Mu held.
+func sprtGameOver(game *db.Game) {
+	run := sprtRuns.ofGame(game.ID)
+	if run == nil {
+		return
+	}
+	run.mu.Lock()
+	var playing []int32
+	for p, pair := range run.pairs {
+		index := -1
+		for i, id := range pair.games {
+			if id == game.ID && !pair.done[i] {
+				index = i
+			}
+		}
+		if index < 0 {
+			continue
+		}
+		pair.done[index] = true
+		if game.State == db.StateFinished {
+			white := 0.5
+			switch game.Winner {
+			case "w":
+				white = 1
+			case "b":
+				white = 0
+			}
+			pair.scores[index] = white
+			if index == 1 {
+				pair.scores[index] = 1 - white
+			}
+		} else {
+			pair.aborted = true
+		}
+		if !pair.done[0] || !pair.done[1] {
+			break
+		}
+
+		run.pairs = append(run.pairs[:p], run.pairs[p+1:]...)
+		if run.state != SPRTRunning {
+			break
+		}
+		if pair.aborted {
+			run.replays = append(run.replays, pair.opening)
+			break
+		}
+		run.stats.AddPair(pair.scores[0], pair.scores[1])
+		if decision := run.stats.Decide(run.config); decision != "" {
+			playing = run.finish(SPRTFinished, decision)
+		} else if run.maxPairs != 0 && run.stats.Pairs() >= run.maxPairs {
+			playing = run.finish(SPRTFinished, "")
+		}
+		break
+	}
+	games := run.fill()
+	run.mu.Unlock()
+
+	seatSPRTGames(games)
+	abortSPRTGames(playing)
+}
+
+// Aborts games of a run that ended.
+func abortSPRTGames(ids []int32) {
+	for _, id := range ids {
+		game, err := db.Games.Get(id)
+		if err != nil {
+			continue
+		}
+		sendCommand(game, func(game *db.Game) (int, error) {
+			if game.Over() {
+				return http.StatusOK, nil
+			}
+			abortGame(game, "stopped", time.Now())
+			return http.StatusOK, nil
+		})
+	}
+}
+
+// Stops a running run on behalf of its owner.
+func stopSPRT(run *sprtRun) error {
+	run.mu.Lock()
+	if run.state != SPRTRunning {
+		run.mu.Unlock()
+		return errors.New("The run has already ended.")
+	}
+	playing := run.finish(SPRTStopped, "")
+	run.mu.Unlock()
+	abortSPRTGames(playing)
+	return nil
+}
@@ -0,0 +1,106 @@
+/*
+Tests of SPRT runs between bots.
+*/
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+)
+
+func getRun(t *testing.T, id int32) RespSPRT {
+	rec := do(GetSPRT, "GET", fmt.Sprintf("/sprt?runid=%d", id), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("fail in GetSPRT: %s", rec.Body)
+	}
+	var run RespSPRT
+	json.NewDecoder(rec.Body).Decode(&run)
+	return run
+}
+
+// Lets winner win the games being played by a forfeit of the loser.
+func playRun(t *testing.T, id int32, winner RespPostAccounts, loser RespPostAccounts) {
+	for _, board := range getRun(t, id).Playing {
+		game, _ := db.Games.Get(board)
+		color := "w"
+		if game.B_account == loser.Account.AccountID {
+			color = "b"
+		}
+		rec := doKey(PutGame, "PUT", "/game", loser.Key, ReqPutGame{BoardID: board, Color: color, Forfeit: true})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("fail in PutGame: %s", rec.Body)
+		}
+	}
+}
+
+func TestSPRT(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+	db.Accounts = db.NewAccountRepository()
+	user := createAccount(t, "alice", db.AccountUser, "")
+	a := createAccount(t, "new", db.AccountBot, user.Key)
+	b := createAccount(t, "old", db.AccountBot, user.Key)
+
+	req := ReqPostSPRT{AccountA: a.Account.AccountID, AccountB: b.Account.AccountID, Openings: []string{"e2 e4, e7 e5", "d2 d4"}, MaxPairs: 2}
+	if rec := doKey(PostSPRT, "POST", "/sprt", user.Key, ReqPostSPRT{AccountA: a.Account.AccountID, AccountB: b.Account.AccountID, Openings: []string{"e2 e5"}}); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected an illegal opening to be rejected, got %d", rec.Code)
+	}
+	if rec := doKey(PostSPRT, "POST", "/sprt", user.Key, ReqPostSPRT{AccountA: a.Account.AccountID, AccountB: b.Account.AccountID, Elo0: 5, Elo1: 0}); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected elo1 below elo0 to be rejected, got %d", rec.Code)
+	}
+	rec := doKey(PostSPRT, "POST", "/sprt", user.Key, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("fail in PostSPRT: %s", rec.Body)
+	}
+	var run RespSPRT
+	json.NewDecoder(rec.Body).Decode(&run)
+
+	// A pair is played from the same opening with swapped colours.
+	if len(run.Playing) != 2 {
+		t.Fatalf("expected a pair being played, got %+v", run)
+	}
+	first, _ := db.Games.Get(run.Playing[0])
+	second, _ := db.Games.Get(run.Playing[1])
+	if first.W_account != a.Account.AccountID || second.W_account != b.Account.AccountID {
+		t.Errorf("expected swapped colours, got %d and %d with white", first.W_account, second.W_account)
+	}
+	if len(first.Moves) != 2 || len(second.Moves) != 2 || first.Moves[1].Move != "e7 e5" || !first.Moves[0].Opening || first.Moves[0].Fallback {
+		t.Errorf("expected the opening to be played by the server, got %+v", first.Moves)
+	}
+
+	playRun(t, run.RunID, a, b)
+	run = getRun(t, run.RunID)
+	if run.Pairs != 1 || run.Wins != 2 || run.Pentanomial != [5]int{0, 0, 0, 0, 1} || run.State != SPRTRunning {
+		t.Fatalf("expected one pair won by A, got %+v", run)
+	}
+	second, _ = db.Games.Get(run.Playing[0])
+	if len(second.Moves) != 1 || second.Moves[0].Move != "d2 d4" {
+		t.Errorf("expected the second opening, got %+v", second.Moves)
+	}
+	playRun(t, run.RunID, a, b)
+	run = getRun(t, run.RunID)
+	if run.State != SPRTFinished || run.Result != "" || run.Pairs != 2 || len(run.Playing) != 0 || run.Elo <= 0 {
+		t.Errorf("expected the run to end undecided after 2 pairs, got %+v", run)
+	}
+
+	// Stopping aborts the games being played.
+	req.MaxPairs = 0
+	rec = doKey(PostSPRT, "POST", "/sprt", user.Key, req)
+	json.NewDecoder(rec.Body).Decode(&run)
+	if rec := doKey(DeleteSPRT, "DELETE", "/sprt", a.Key, ReqDeleteSPRT{RunID: run.RunID}); rec.Code != http.StatusForbidden {
+		t.Errorf("expected only the starting account to stop the run, got %d", rec.Code)
+	}
+	if rec := doKey(DeleteSPRT, "DELETE", "/sprt", user.Key, ReqDeleteSPRT{RunID: run.RunID}); rec.Code != http.StatusOK {
+		t.Fatalf("fail in DeleteSPRT: %s", rec.Body)
+	}
+	game, _ := db.Games.Get(run.Playing[0])
+	if game.State != db.StateAborted {
+		t.Errorf("expected the game to be aborted, got %s", game.State)
+	}
+	if stopped := getRun(t, run.RunID); stopped.State != SPRTStopped || len(stopped.Playing) != 0 {
+		t.Errorf("expected a stopped run without games, got %+v", stopped)
+	}
+}
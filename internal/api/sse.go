@@ -0,0 +1,149 @@
+/*
+API for following games with Server-Sent Events.
+*/
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/schema"
+)
+
+// Streams the events of a game as text/event-stream
+func GameEvents(w http.ResponseWriter, r *http.Request) {
+	/*
+		Input:
+			Board ID and password as query parameters, or the
+			password or a player token as
+			'Authorization: Bearer' header. A client
+			resuming a feed sends the id of the last event it
+			received, either as Last-Event-ID header or as
+			query parameter.
+
+			ReqGameEvents
+			BoardID     int32  `schema:"boardid"`
+			Password    string `schema:"password"`
+			LastEventID string `schema:"lasteventid"`
+		Return:
+			Event stream of RespEvent messages. The event name
+			is the event type, the id is the move index.
+			New feeds start with a "state" event, resumed feeds
+			with the "move" events that were missed.
+		Actions:
+			---
+	*/
+	var req ReqGameEvents
+	decoder := schema.NewDecoder()
+	decoder.IgnoreUnknownKeys(true)
+
+	err := decoder.Decode(&req, r.URL.Query())
+	if err != nil {
+		http.Error(w, "Failed to parse query params: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = req.LastEventID
+	}
+	resumeIdx := -1
+	if lastEventID != "" {
+		resumeIdx, err = strconv.Atoi(lastEventID)
+		if err != nil || resumeIdx < 0 {
+			http.Error(w, "Invalid Last-Event-ID.", http.StatusBadRequest)
+			return
+		}
+	}
+
+	game, success := verifyGameAccess(w, r, req.BoardID, req.Password)
+	if !success {
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported.", http.StatusInternalServerError)
+		return
+	}
+
+	// Subscribe before taking the snapshot so no event is missed.
+	events, cancel := game.Subscribe()
+	defer cancel()
+
+	game.Mu.RLock()
+	lastIdx := game.Plies()
+	ended := game.Over()
+	var initial []RespEvent
+	if resumeIdx == -1 || resumeIdx > lastIdx {
+		initial = append(initial, stateEvent(game, EventState))
+	} else {
+		for idx := resumeIdx + 1; idx <= lastIdx; idx++ {
+			initial = append(initial, moveEvent(game, idx))
+		}
+		if ended {
+			initial = append(initial, endEvent(game))
+		}
+	}
+	game.Mu.RUnlock()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	for _, event := range initial {
+		if writeSSE(w, event) != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(streamPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				// Deleted game or a client that fell behind.
+				return
+			}
+			event := ev.Payload.(RespEvent)
+			// Skip what the snapshot already contained.
+			if event.Type == EventMove && event.Moveidx <= lastIdx {
+				continue
+			}
+			if event.Type == EventGameOver || event.Type == EventAborted {
+				if ended {
+					continue
+				}
+				ended = true
+			}
+			if writeSSE(w, event) != nil {
+				return
+			}
+			flusher.Flush()
+		case <-ticker.C:
+			// Comment lines keep proxies from closing idle feeds.
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// Writes one event in the text/event-stream format.
+func writeSSE(w http.ResponseWriter, event RespEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Moveidx, event.Type, data)
+	return err
+}
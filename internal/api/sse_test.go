@@ -0,0 +1,137 @@
+/*
+Tests of the Server-Sent Events feed.
+*/
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+)
+
+type sseEvent struct {
+	id    string
+	name  string
+	event RespEvent
+}
+
+// Opens the feed of a board, resuming after lastEventID unless it is
+// empty.
+func openSSE(t *testing.T, ctx context.Context, url string, session RespPostSessions, lastEventID string) (*http.Response, *bufio.Scanner) {
+	t.Helper()
+	req, _ := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s?boardid=%d", url, session.BoardID), nil)
+	req.Header.Set("Authorization", "Bearer "+session.Password)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("fail in GameEvents: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp, bufio.NewScanner(resp.Body)
+}
+
+// Reads the next event, skipping comments.
+func readSSE(t *testing.T, scanner *bufio.Scanner) sseEvent {
+	t.Helper()
+	var ev sseEvent
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "" && ev.name != "":
+			return ev
+		case strings.HasPrefix(line, "id: "):
+			ev.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			ev.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev.event)
+		}
+	}
+	t.Fatalf("feed ended: %v", scanner.Err())
+	return ev
+}
+
+func playMove(t *testing.T, session RespPostSessions, color string, token string, move string) {
+	t.Helper()
+	rec := do(PutGame, "PUT", "/game", ReqPutGame{
+		BoardID:  session.BoardID,
+		Password: session.Password,
+		Color:    color,
+		Token:    token,
+		Move:     move,
+		Forfeit:  move == "",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("fail in PutGame %q: %s", move, rec.Body)
+	}
+}
+
+func TestGameEventsResume(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+	ts := httptest.NewServer(http.HandlerFunc(GameEvents))
+	defer ts.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	session := createGame(t)
+	white, _ := joinColor(session, "w")
+	black, _ := joinColor(session, "b")
+	playMove(t, session, "w", white, "e2 e4")
+	playMove(t, session, "b", black, "e7 e5")
+
+	// A client that saw the first move gets the second one replayed
+	// instead of the state.
+	resp, feed := openSSE(t, ctx, ts.URL, session, "1")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	ev := readSSE(t, feed)
+	if ev.name != EventMove || ev.id != "2" || ev.event.Move != "e7 e5" || ev.event.State == nil {
+		t.Fatalf("expected the missed move 2, got %+v", ev)
+	}
+
+	// Live moves follow without repeating the replayed one.
+	playMove(t, session, "w", white, "g1 f3")
+	ev = readSSE(t, feed)
+	if ev.name != EventMove || ev.id != "3" || ev.event.Move != "g1 f3" {
+		t.Fatalf("expected the live move 3, got %+v", ev)
+	}
+
+	playMove(t, session, "b", black, "")
+	for ev = readSSE(t, feed); ev.name != EventGameOver; ev = readSSE(t, feed) {
+		if ev.name == EventMove {
+			t.Fatalf("expected no further move, got %+v", ev)
+		}
+	}
+	if ev.event.State == nil || ev.event.State.Winner != "w" {
+		t.Errorf("expected white to win, got %+v", ev.event.State)
+	}
+
+	// Reconnecting after the end replays the end once.
+	_, feed = openSSE(t, ctx, ts.URL, session, "3")
+	ev = readSSE(t, feed)
+	if ev.name != EventGameOver || ev.id != "3" {
+		t.Errorf("expected the end of the game, got %+v", ev)
+	}
+
+	// A client that saw nothing yet starts with the state.
+	_, feed = openSSE(t, ctx, ts.URL, session, "")
+	if ev = readSSE(t, feed); ev.name != EventState || ev.event.State == nil {
+		t.Errorf("expected the state first, got %+v", ev)
+	}
+
+	resp, _ = openSSE(t, ctx, ts.URL, session, "one")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected an invalid Last-Event-ID to be rejected, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,93 @@
+/*
+Tests of the leaderboard and statistics of accounts.
+*/
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+)
+
+// Plays a blitz game between two bots in which white moves once and
+// loser forfeits.
+func playBots(t *testing.T, white RespPostAccounts, black RespPostAccounts, loser string) int32 {
+	rec := do(PostSessions, "POST", "/sessions", ReqPostSessions{Name: "stats", BaseMs: 5 * 60 * 1000})
+	var session RespPostSessions
+	json.NewDecoder(rec.Body).Decode(&session)
+	for color, bot := range map[string]RespPostAccounts{"w": white, "b": black} {
+		rec := doKey(PutSessions, "PUT", "/sessions", bot.Key, ReqPutSessions{
+			BoardID:  session.BoardID,
+			Password: session.Password,
+			Color:    color,
+		})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("fail in PutSessions: %s", rec.Body)
+		}
+	}
+	doKey(PutGame, "PUT", "/game", white.Key, ReqPutGame{BoardID: session.BoardID, Color: "w", Move: "e2 e4"})
+	key := white.Key
+	if loser == "b" {
+		key = black.Key
+	}
+	rec = doKey(PutGame, "PUT", "/game", key, ReqPutGame{BoardID: session.BoardID, Color: loser, Forfeit: true})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("fail in PutGame: %s", rec.Body)
+	}
+	return session.BoardID
+}
+
+func TestStats(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+	db.Accounts = db.NewAccountRepository()
+	db.Results = db.NewResultIndex()
+	user := createAccount(t, "alice", db.AccountUser, "")
+	strong := createAccount(t, "strong", db.AccountBot, user.Key)
+	weak := createAccount(t, "weak", db.AccountBot, user.Key)
+	other := createAccount(t, "other", db.AccountBot, user.Key)
+
+	playBots(t, strong, weak, "b")
+	playBots(t, weak, strong, "w")
+	playBots(t, weak, other, "b")
+	// Archived games keep counting.
+	archived := playBots(t, other, strong, "w")
+	if _, err := db.Games.Archive(archived); err != nil {
+		t.Fatalf("fail in Archive: %s", err)
+	}
+
+	rec := do(GetLeaderboard, "GET", "/leaderboard", nil)
+	var board RespGetLeaderboard
+	json.NewDecoder(rec.Body).Decode(&board)
+	if len(board.Entries) != 3 || board.Entries[0].Account.Name != "strong" || board.Entries[0].Wins != 3 || board.Entries[0].Score != 1 {
+		t.Errorf("expected strong to lead with 3 wins, got %+v", board.Entries)
+	}
+	rec = do(GetLeaderboard, "GET", "/leaderboard?sort=elo&category=blitz&mingames=3", nil)
+	json.NewDecoder(rec.Body).Decode(&board)
+	if len(board.Entries) != 2 || board.Entries[0].Rating == nil || board.Entries[0].Rating.Elo <= board.Entries[1].Rating.Elo {
+		t.Errorf("expected the two accounts with 3 games by Elo, got %+v", board.Entries)
+	}
+	if rec = do(GetLeaderboard, "GET", "/leaderboard?sort=elo", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected ordering by rating without category to be rejected, got %d", rec.Code)
+	}
+
+	rec = do(GetStats, "GET", fmt.Sprintf("/accounts/stats?accountid=%d", weak.Account.AccountID), nil)
+	var stats RespGetStats
+	json.NewDecoder(rec.Body).Decode(&stats)
+	if stats.Games != 3 || stats.White.Games != 2 || stats.White.Wins != 1 || stats.Black.Losses != 1 {
+		t.Errorf("expected weak to win once with white and lose with black, got %+v", stats)
+	}
+	if stats.Terminations["forfeit"] != 3 || stats.AvgPlies != 1 {
+		t.Errorf("expected 3 forfeits after one ply, got %+v", stats)
+	}
+
+	target := fmt.Sprintf("/accounts/headtohead?a=%d&b=%d", strong.Account.AccountID, weak.Account.AccountID)
+	rec = do(GetHeadToHead, "GET", target, nil)
+	var h2h RespGetHeadToHead
+	json.NewDecoder(rec.Body).Decode(&h2h)
+	if h2h.Games != 2 || h2h.AWins != 2 || h2h.BWins != 0 || h2h.AWhite.Wins != 1 || len(h2h.BoardIDs) != 2 {
+		t.Errorf("expected strong to win both games against weak, got %+v", h2h)
+	}
+}
@@ -0,0 +1,142 @@
+/*
+API for streaming games over WebSockets.
+*/
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/schema"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/websocket"
+)
+
+// Interval of the pings that keep idle streams alive.
+const streamPingInterval = 30 * time.Second
+
+// Streams the events of a game
+func GameStream(w http.ResponseWriter, r *http.Request) {
+	/*
+		Input:
+			Board ID and password as query parameters.
+			Players add their color and token to be able to
+			move over the stream, spectators leave them out.
+			Clients able to set headers can send the password,
+			or a player's token, as 'Authorization: Bearer'
+			header instead.
+
+			ReqGameStream
+			BoardID  int32  `schema:"boardid"`
+			Password string `schema:"password"`
+			Color    string `schema:"color"`
+			Token    string `schema:"token"`
+
+			Messages of players:
+			ReqStreamMessage
+			Move    string `json:"move,omitempty"`
+			Forfeit bool   `json:"forfeit,omitempty"`
+			Draw    string `json:"draw,omitempty"`
+		Return:
+			WebSocket connection delivering RespEvent messages:
+			"state" once after connecting, then "joined",
+			"started", "move", "clock", "drawoffer",
+			"drawdeclined", "gameover" and "aborted" as they
+			happen. "deleted", "expired" and "archived" end the
+			stream. Messages that can't be applied are answered
+			with an "error" event.
+		Actions:
+			Moves and forfeits sent by players are validated
+			and applied like with PutGame.
+	*/
+	var req ReqGameStream
+	decoder := schema.NewDecoder()
+	decoder.IgnoreUnknownKeys(true)
+
+	err := decoder.Decode(&req, r.URL.Query())
+	if err != nil {
+		http.Error(w, "Failed to parse query params: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	game, success1 := verifyGameAccess(w, r, req.BoardID, req.Password)
+	if !success1 {
+		return
+	}
+
+	player := req.Color != "" || req.Token != ""
+	if player {
+		success2 := verifyBoardAccess(w, r, game, req.Color, req.Token)
+		if !success2 {
+			return
+		}
+	}
+
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	// Subscribe before taking the snapshot so no event is missed.
+	events, cancel := game.Subscribe()
+	defer cancel()
+	game.Mu.RLock()
+	initial := stateEvent(game, EventState)
+	game.Mu.RUnlock()
+	if err := conn.WriteJSON(initial); err != nil {
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var msg ReqStreamMessage
+			if err := json.Unmarshal(data, &msg); err != nil {
+				conn.WriteJSON(RespEvent{Type: EventError, Error: "Invalid message: " + err.Error()})
+				continue
+			}
+			if !player {
+				conn.WriteJSON(RespEvent{Type: EventError, Error: "Spectators can't move."})
+				continue
+			}
+			_, err = sendCommand(game, func(game *db.Game) (int, error) {
+				if msg.Draw != "" && !msg.Forfeit {
+					return playDraw(game, req.Color, msg.Draw, msg.Move)
+				}
+				return playTurn(game, req.Color, msg.Move, msg.Forfeit)
+			})
+			if err != nil {
+				conn.WriteJSON(RespEvent{Type: EventError, Error: err.Error()})
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(streamPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				// Deleted game or a client that fell behind.
+				return
+			}
+			if err := conn.WriteJSON(event.Payload); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.Ping(); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
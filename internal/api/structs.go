@@ -3,9 +3,21 @@ Structs for API communication are defined here.
 */
 package api
 
+import (
+	"time"
+
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/game_logic"
+)
+
 // Create new game
 type ReqPostSessions struct {
-	Name string `json:"name"`
+	Name          string `json:"name"`
+	BaseMs        int64  `json:"basems,omitempty"`
+	IncrementMs   int64  `json:"incrementms,omitempty"`
+	DelayMs       int64  `json:"delayms,omitempty"`
+	DelayMode     string `json:"delaymode,omitempty"`
+	MoveTimeMs    int64  `json:"movetimems,omitempty"`
+	OnMoveTimeout string `json:"onmovetimeout,omitempty"` // "forfeit" or "random"
 }
 type RespPostSessions struct {
 	BoardID  int32  `json:"boardid"`
@@ -23,6 +35,8 @@ type ReqPutSessions struct {
 	BoardID  int32  `json:"boardid"`
 	Password string `json:"password"`
 	Color    string `json:"color"`
+	APIKey   string `json:"apikey,omitempty"`
+	Callback string `json:"callback,omitempty"` // URL playing the color, see ReqWebhook.
 }
 type RespPutSessions struct {
 	Token string `json:"token"`
@@ -33,8 +47,14 @@ type RespGetSessions struct {
 	Games []GameNameAndID `json:"games"`
 }
 type GameNameAndID struct {
-	Name    string `json:"name"`
-	BoardID int32  `json:"boardid"`
+	Name    string     `json:"name"`
+	BoardID int32      `json:"boardid"`
+	State   string     `json:"state"` // "waiting", "active", "finished" or "aborted"
+	Created time.Time  `json:"created"`
+	Started *time.Time `json:"started,omitempty"`
+	Ended   *time.Time `json:"ended,omitempty"`
+	White   string     `json:"white,omitempty"` // Account names, empty if anonymous.
+	Black   string     `json:"black,omitempty"`
 }
 
 // Get game data
@@ -46,6 +66,102 @@ type ReqGetGame struct {
 	Token    string `schema:"token"`
 	Statereq bool   `schema:"statereq"`
 	Turnreq  bool   `schema:"turnreq"`
+	// Milliseconds to wait for the turn, defaults to a minute.
+	TimeoutMs int32 `schema:"timeoutms"`
+}
+
+type RespGetGame struct {
+	game_logic.BoardState
+	State       string          `json:"state"`
+	Termination string          `json:"termination,omitempty"`
+	DrawOffer   string          `json:"drawoffer,omitempty"`
+	Clock       *RespClock      `json:"clock,omitempty"`
+	LastMove    *RespMoveRecord `json:"lastmove,omitempty"`
+}
+type RespClock struct {
+	WhiteMs     int64  `json:"whitems"`
+	BlackMs     int64  `json:"blackms"`
+	IncrementMs int64  `json:"incrementms"`
+	DelayMs     int64  `json:"delayms"`
+	DelayMode   string `json:"delaymode,omitempty"`
+	Running     bool   `json:"running"`
+}
+
+// Get the move history
+type ReqGetHistory struct {
+	BoardID  int32  `schema:"boardid"`
+	Password string `schema:"password"`
+	Color    string `schema:"color"`
+	Token    string `schema:"token"`
+}
+type RespGetHistory struct {
+	Moves []RespMoveRecord `json:"moves"`
+}
+type RespMoveRecord struct {
+	Color    string `json:"color"`
+	Move     string `json:"move"`
+	ThinkMs  int64  `json:"thinkms"`
+	Fallback bool   `json:"fallback,omitempty"`
+	Opening  bool   `json:"opening,omitempty"`
+}
+
+// Get the journal of a game
+type ReqGetJournal struct {
+	BoardID  int32  `schema:"boardid"`
+	Password string `schema:"password"`
+	Color    string `schema:"color"`
+	Token    string `schema:"token"`
+}
+type RespGetJournal struct {
+	Entries []RespJournalEntry `json:"entries"`
+}
+type RespJournalEntry struct {
+	Seq      int       `json:"seq"`
+	Time     time.Time `json:"time"`
+	Type     string    `json:"type"`
+	Color    string    `json:"color,omitempty"`
+	Move     string    `json:"move,omitempty"`
+	ThinkMs  int64     `json:"thinkms,omitempty"`
+	Fallback bool      `json:"fallback,omitempty"`
+	Opening  bool      `json:"opening,omitempty"`
+	WhiteMs  *int64    `json:"whitems,omitempty"`
+	BlackMs  *int64    `json:"blackms,omitempty"`
+	Account  string    `json:"account,omitempty"` // Joined with an API key.
+	Winner   string    `json:"winner,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+}
+
+// Open the game stream
+type ReqGameStream struct {
+	BoardID  int32  `schema:"boardid"`
+	Password string `schema:"password"`
+	Color    string `schema:"color"`
+	Token    string `schema:"token"`
+}
+
+// Open the event feed
+type ReqGameEvents struct {
+	BoardID     int32  `schema:"boardid"`
+	Password    string `schema:"password"`
+	LastEventID string `schema:"lasteventid"`
+}
+
+// Message of a player over the game stream
+type ReqStreamMessage struct {
+	Move    string `json:"move,omitempty"`
+	Forfeit bool   `json:"forfeit,omitempty"`
+	Draw    string `json:"draw,omitempty"`
+}
+
+// Event pushed over the game stream
+type RespEvent struct {
+	Type    string       `json:"type"`
+	Color   string       `json:"color,omitempty"`
+	Move    string       `json:"move,omitempty"`
+	Moveidx int          `json:"moveidx"`
+	State   *RespGetGame `json:"state,omitempty"`
+	Clock   *RespClock   `json:"clock,omitempty"`
+	Error   string       `json:"error,omitempty"`
 }
 
 // Apply move
@@ -56,4 +172,379 @@ type ReqPutGame struct {
 	Token    string `json:"token"`
 	Move     string `json:"move,omitempty"`
 	Forfeit  bool   `json:"forfeit,omitempty"`
+	Draw     string `json:"draw,omitempty"` // "offer", "accept" or "decline"
+}
+
+// Create an account
+type ReqPostAccounts struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"` // "user" or "bot"
+}
+type RespPostAccounts struct {
+	Account RespAccount `json:"account"`
+	KeyID   string      `json:"keyid"`
+	Key     string      `json:"key"`
+}
+
+// Get all accounts
+type RespGetAccounts struct {
+	Accounts []RespAccount `json:"accounts"`
+}
+type RespAccount struct {
+	AccountID int32     `json:"accountid"`
+	Name      string    `json:"name"`
+	Owner     string    `json:"owner"`
+	Kind      string    `json:"kind"`
+	Created   time.Time `json:"created"`
+}
+
+// Manage the API keys of an account
+type ReqGetAccountKeys struct {
+	AccountID int32 `schema:"accountid"`
+}
+type RespGetAccountKeys struct {
+	Keys []RespAPIKey `json:"keys"`
+}
+type RespAPIKey struct {
+	KeyID   string     `json:"keyid"`
+	Created time.Time  `json:"created"`
+	Revoked *time.Time `json:"revoked,omitempty"`
+}
+type ReqPostAccountKeys struct {
+	AccountID int32 `json:"accountid"`
+}
+type RespPostAccountKeys struct {
+	KeyID string `json:"keyid"`
+	Key   string `json:"key"`
+}
+type ReqDeleteAccountKeys struct {
+	AccountID int32  `json:"accountid"`
+	KeyID     string `json:"keyid"`
+}
+
+// Get the ratings of an account
+type ReqGetRating struct {
+	AccountID int32 `schema:"accountid"`
+}
+type RespGetRating struct {
+	Account RespAccount           `json:"account"`
+	Ratings map[string]RespRating `json:"ratings"` // By category.
+}
+type RespRating struct {
+	Elo        float64 `json:"elo"`
+	Glicko     float64 `json:"glicko"`
+	RD         float64 `json:"rd"`
+	Volatility float64 `json:"volatility"`
+	Games      int     `json:"games"`
+}
+
+// Get the rating history of an account
+type ReqGetRatingHistory struct {
+	AccountID int32  `schema:"accountid"`
+	Category  string `schema:"category"`
+}
+type RespGetRatingHistory struct {
+	Points []RespRatingPoint `json:"points"`
+}
+type RespRatingPoint struct {
+	Time     time.Time  `json:"time"`
+	BoardID  int32      `json:"boardid"`
+	Category string     `json:"category"`
+	Opponent string     `json:"opponent"`
+	Score    float64    `json:"score"`
+	Rating   RespRating `json:"rating"` // After the game.
+}
+
+// Predict the result of a game between two accounts
+type ReqGetPrediction struct {
+	White    int32  `schema:"white"`
+	Black    int32  `schema:"black"`
+	Category string `schema:"category"`
+}
+type RespGetPrediction struct {
+	Category    string     `json:"category"`
+	White       RespRating `json:"white"`
+	Black       RespRating `json:"black"`
+	EloScore    float64    `json:"eloscore"`    // Expected score of white.
+	GlickoScore float64    `json:"glickoscore"` // Expected score of white.
+}
+
+// Rank accounts
+type ReqGetLeaderboard struct {
+	Sort     string `schema:"sort"` // "score" (default), "elo" or "glicko".
+	Category string `schema:"category"`
+	MinGames int    `schema:"mingames"`
+}
+type RespGetLeaderboard struct {
+	Sort     string          `json:"sort"`
+	Category string          `json:"category"`
+	Entries  []RespRankEntry `json:"entries"`
+}
+type RespRankEntry struct {
+	Rank    int         `json:"rank"`
+	Account RespAccount `json:"account"`
+	Games   int         `json:"games"`
+	Wins    int         `json:"wins"`
+	Draws   int         `json:"draws"`
+	Losses  int         `json:"losses"`
+	Score   float64     `json:"score"`            // Points per game.
+	Rating  *RespRating `json:"rating,omitempty"` // With a category only.
+}
+
+// Get the statistics of an account
+type ReqGetStats struct {
+	AccountID int32  `schema:"accountid"`
+	Category  string `schema:"category"`
+}
+type RespGetStats struct {
+	Account      RespAccount    `json:"account"`
+	Games        int            `json:"games"`
+	White        RespColorStats `json:"white"`
+	Black        RespColorStats `json:"black"`
+	AvgPlies     float64        `json:"avgplies"`
+	AvgGameMs    int64          `json:"avggamems"`
+	AvgMoveMs    int64          `json:"avgmovems"`    // Own moves only.
+	Terminations map[string]int `json:"terminations"` // Games by reason.
+}
+type RespColorStats struct {
+	Games  int     `json:"games"`
+	Wins   int     `json:"wins"`
+	Draws  int     `json:"draws"`
+	Losses int     `json:"losses"`
+	Score  float64 `json:"score"` // Points per game.
+}
+
+// Get the results between two accounts
+type ReqGetHeadToHead struct {
+	A        int32  `schema:"a"`
+	B        int32  `schema:"b"`
+	Category string `schema:"category"`
+}
+type RespGetHeadToHead struct {
+	A        RespAccount    `json:"a"`
+	B        RespAccount    `json:"b"`
+	Games    int            `json:"games"`
+	AWins    int            `json:"awins"`
+	Draws    int            `json:"draws"`
+	BWins    int            `json:"bwins"`
+	AWhite   RespColorStats `json:"awhite"` // Results of A with white.
+	ABlack   RespColorStats `json:"ablack"` // Results of A with black.
+	BoardIDs []int32        `json:"boardids"`
+}
+
+// Wait for a game against another account
+type ReqPostMatchmaking struct {
+	BaseMs        int64  `json:"basems,omitempty"`
+	IncrementMs   int64  `json:"incrementms,omitempty"`
+	DelayMs       int64  `json:"delayms,omitempty"`
+	DelayMode     string `json:"delaymode,omitempty"`
+	MoveTimeMs    int64  `json:"movetimems,omitempty"`
+	OnMoveTimeout string `json:"onmovetimeout,omitempty"`
+	Variant       string `json:"variant,omitempty"` // "standard"
+	Color         string `json:"color,omitempty"`   // "w", "b" or empty for any.
+	APIKey        string `json:"apikey,omitempty"`
+	// Milliseconds to wait for an opponent, defaults to a minute.
+	TimeoutMs int32 `json:"timeoutms,omitempty"`
+}
+type RespPostMatchmaking struct {
+	BoardID  int32  `json:"boardid"`
+	Color    string `json:"color"`
+	Token    string `json:"token"`
+	Opponent string `json:"opponent"`
+}
+
+// Create a tournament
+type ReqPostTournaments struct {
+	Name          string `json:"name"`
+	Format        string `json:"format"`           // "roundrobin", "doubleroundrobin", "swiss" or "knockout"
+	Rounds        int    `json:"rounds,omitempty"` // Swiss only.
+	BaseMs        int64  `json:"basems,omitempty"`
+	IncrementMs   int64  `json:"incrementms,omitempty"`
+	DelayMs       int64  `json:"delayms,omitempty"`
+	DelayMode     string `json:"delaymode,omitempty"`
+	MoveTimeMs    int64  `json:"movetimems,omitempty"`
+	OnMoveTimeout string `json:"onmovetimeout,omitempty"`
+	// Knockout only. Tied matches continue with the tiebreak games,
+	// then an armageddon game, both with the tiebreak clock if given.
+	GamesPerMatch       int   `json:"gamespermatch,omitempty"` // Defaults to 2.
+	TiebreakGames       int   `json:"tiebreakgames,omitempty"`
+	TiebreakBaseMs      int64 `json:"tiebreakbasems,omitempty"`
+	TiebreakIncrementMs int64 `json:"tiebreakincrementms,omitempty"`
+}
+type RespTournament struct {
+	TournamentID int32    `json:"tournamentid"`
+	Name         string   `json:"name"`
+	Format       string   `json:"format"`
+	State        string   `json:"state"` // "registering", "running" or "finished"
+	Rounds       int      `json:"rounds"`
+	Round        int      `json:"round"`
+	Owner        string   `json:"owner"`
+	Players      []string `json:"players"`
+	// Knockout only.
+	GamesPerMatch int        `json:"gamespermatch,omitempty"`
+	TiebreakGames int        `json:"tiebreakgames,omitempty"`
+	Created       time.Time  `json:"created"`
+	Started       *time.Time `json:"started,omitempty"`
+	Ended         *time.Time `json:"ended,omitempty"`
+}
+
+// Get all tournaments
+type RespGetTournaments struct {
+	Tournaments []RespTournament `json:"tournaments"`
+}
+
+// Register for a tournament
+type ReqPutTournaments struct {
+	TournamentID int32 `json:"tournamentid"`
+	AccountID    int32 `json:"accountid"`
+}
+
+// Start a tournament
+type ReqPostTournamentStart struct {
+	TournamentID int32 `json:"tournamentid"`
+}
+
+// Get the standings, pairings or crosstable of a tournament
+type ReqGetTournament struct {
+	TournamentID int32 `schema:"tournamentid"`
+	Round        int   `schema:"round"` // Pairings only, 0 for all rounds.
+}
+type RespGetStandings struct {
+	Tournament RespTournament `json:"tournament"`
+	TieBreaks  []string       `json:"tiebreaks"` // In the order they are applied.
+	Standings  []RespStanding `json:"standings"`
+}
+type RespStanding struct {
+	Rank            int     `json:"rank"`
+	Account         string  `json:"account"`
+	AccountID       int32   `json:"accountid"`
+	Points          float64 `json:"points"`
+	Games           int     `json:"games"`
+	Wins            int     `json:"wins"`
+	Draws           int     `json:"draws"`
+	Losses          int     `json:"losses"`
+	Byes            int     `json:"byes"`
+	SonnebornBerger float64 `json:"sonnebornberger"`
+	Buchholz        float64 `json:"buchholz"`
+}
+type RespGetPairings struct {
+	Pairings []RespPairing `json:"pairings"`
+}
+type RespPairing struct {
+	Round   int    `json:"round"`
+	White   string `json:"white"`
+	Black   string `json:"black,omitempty"`   // Empty for a bye.
+	BoardID int32  `json:"boardid,omitempty"` // 0 for a bye.
+	Result  string `json:"result,omitempty"`  // "1-0", "0-1", "1/2-1/2", "0-0" or "bye", empty while playing.
+	Kind    string `json:"kind,omitempty"`    // Knockout only, "tiebreak" or "armageddon" after the regular games.
+}
+type RespGetBracket struct {
+	Tournament RespTournament     `json:"tournament"`
+	Rounds     []RespBracketRound `json:"rounds"`
+}
+type RespBracketRound struct {
+	Round   int                `json:"round"`
+	Matches []RespBracketMatch `json:"matches"`
+}
+type RespBracketMatch struct {
+	Match  int           `json:"match"`  // Starting at 1, top of the bracket first.
+	A      string        `json:"a"`      // Higher seed, empty while unknown.
+	B      string        `json:"b"`      // Empty while unknown or for a bye.
+	AScore float64       `json:"ascore"` // Of all games of the match.
+	BScore float64       `json:"bscore"`
+	Winner string        `json:"winner,omitempty"`
+	Games  []RespPairing `json:"games"`
+}
+type RespGetCrosstable struct {
+	Players []string       `json:"players"` // In the order of the standings.
+	Rows    []RespCrossRow `json:"rows"`
+}
+type RespCrossRow struct {
+	Rank    int     `json:"rank"`
+	Account string  `json:"account"`
+	Points  float64 `json:"points"`
+	// Scores against the players in the order of Players, e.g. "1",
+	// "½" or "0", one per game. "x" against oneself.
+	Cells  []string        `json:"cells"`
+	Rounds []RespCrossGame `json:"rounds"`
+}
+type RespCrossGame struct {
+	Round    int     `json:"round"`
+	Opponent string  `json:"opponent,omitempty"` // Empty for a bye.
+	Color    string  `json:"color,omitempty"`
+	Score    float64 `json:"score"`
+}
+
+// Start an SPRT run
+type ReqPostSPRT struct {
+	AccountA      int32    `json:"accounta"`           // Candidate, usually the new version.
+	AccountB      int32    `json:"accountb"`           // Baseline.
+	Openings      []string `json:"openings,omitempty"` // Like "e2 e4, e7 e5", DefaultOpenings if empty.
+	Elo0          float64  `json:"elo0,omitempty"`
+	Elo1          float64  `json:"elo1,omitempty"`
+	Alpha         float64  `json:"alpha,omitempty"`
+	Beta          float64  `json:"beta,omitempty"`
+	MaxPairs      int      `json:"maxpairs,omitempty"`    // 0 for no limit.
+	Concurrency   int      `json:"concurrency,omitempty"` // Pairs played at once, 1 by default.
+	BaseMs        int64    `json:"basems,omitempty"`
+	IncrementMs   int64    `json:"incrementms,omitempty"`
+	DelayMs       int64    `json:"delayms,omitempty"`
+	DelayMode     string   `json:"delaymode,omitempty"`
+	MoveTimeMs    int64    `json:"movetimems,omitempty"`
+	OnMoveTimeout string   `json:"onmovetimeout,omitempty"`
+}
+type RespSPRT struct {
+	RunID       int32      `json:"runid"`
+	State       string     `json:"state"`            // "running", "finished" or "stopped"
+	Result      string     `json:"result,omitempty"` // "h1" if A is better, "h0" if not, empty while undecided.
+	AccountA    string     `json:"accounta"`
+	AccountB    string     `json:"accountb"`
+	Elo0        float64    `json:"elo0"`
+	Elo1        float64    `json:"elo1"`
+	Alpha       float64    `json:"alpha"`
+	Beta        float64    `json:"beta"`
+	LLR         float64    `json:"llr"`
+	LowerBound  float64    `json:"lowerbound"` // H0 is accepted at this LLR.
+	UpperBound  float64    `json:"upperbound"` // H1 is accepted at this LLR.
+	Pairs       int        `json:"pairs"`
+	Wins        int        `json:"wins"` // Of A.
+	Draws       int        `json:"draws"`
+	Losses      int        `json:"losses"`
+	Pentanomial [5]int     `json:"pentanomial"` // Pairs by the points of A, 0 to 2.
+	Score       float64    `json:"score"`
+	Elo         float64    `json:"elo"`
+	EloMargin   float64    `json:"elomargin"` // 95% confidence.
+	Playing     []int32    `json:"playing"`   // Boards of the games being played.
+	Created     time.Time  `json:"created"`
+	Ended       *time.Time `json:"ended,omitempty"`
+}
+
+// Get SPRT runs
+type ReqGetSPRT struct {
+	RunID int32 `schema:"runid"` // 0 for all runs.
+}
+type RespGetSPRT struct {
+	Runs []RespSPRT `json:"runs"`
+}
+
+// Stop an SPRT run
+type ReqDeleteSPRT struct {
+	RunID int32 `json:"runid"`
+}
+
+// State the server POSTs to the callback of a seat when it is its
+// turn
+type ReqWebhook struct {
+	BoardID    int32      `json:"boardid"`
+	Color      string     `json:"color"`
+	FEN        string     `json:"fen"`
+	Moves      []string   `json:"moves"` // Played so far.
+	LegalMoves []string   `json:"legalmoves"`
+	DrawOffer  string     `json:"drawoffer,omitempty"`
+	Clock      *RespClock `json:"clock,omitempty"`
+	MoveTimeMs int64      `json:"movetimems,omitempty"` // Time limit per move.
+}
+type RespWebhook struct {
+	Move    string `json:"move,omitempty"` // Like "e2 e4".
+	Forfeit bool   `json:"forfeit,omitempty"`
 }
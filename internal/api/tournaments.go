@@ -0,0 +1,277 @@
+/*
+Running tournaments. Starting a tournament creates the games of its
+first round with both accounts seated, they play with their API keys.
+When the last game of a round ends, the next round is paired and
+created, until the last round was played. In knockout tournaments,
+each match continues with its next game as soon as a game of it
+ended.
+*/
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/tournament"
+)
+
+// Serialises the progress of tournaments, so a game isn't paired
+// twice when several games end at once.
+var tournamentMu sync.Mutex
+
+// Closes the registration of a tournament and starts its first round.
+func startTournament(t db.Tournament) (db.Tournament, error) {
+	tournamentMu.Lock()
+	defer tournamentMu.Unlock()
+	rounds := t.Rounds
+	switch t.Format {
+	case tournament.RoundRobin, tournament.DoubleRoundRobin:
+		rounds = tournament.RoundRobinRounds(len(t.Players), t.Format == tournament.DoubleRoundRobin)
+	case tournament.Knockout:
+		rounds = tournament.KnockoutRounds(len(t.Players))
+	}
+	t, err := db.Tournaments.Start(t.ID, rounds)
+	if err != nil {
+		return t, err
+	}
+	return startNextRound(t)
+}
+
+// Starts the round after the current one, or ends the tournament
+// after its last round. Called with tournamentMu held.
+func startNextRound(t db.Tournament) (db.Tournament, error) {
+	if t.Round == t.Rounds {
+		return db.Tournaments.End(t.ID)
+	}
+	round := t.Round + 1
+	var games []db.TournamentGame
+	if t.Format == tournament.Knockout {
+		for i, match := range knockoutBracket(t)[round-1] {
+			if next, ok := match.Next(t.MatchConfig()); ok {
+				games = append(games, db.TournamentGame{Round: round, Match: i, Kind: next.Kind, White: next.White, Black: next.Black})
+			} else {
+				games = append(games, db.TournamentGame{Round: round, Match: i, White: match.A, Done: true, WhiteScore: tournament.ByeScore})
+			}
+		}
+	} else {
+		pairings, err := roundPairings(t, round)
+		if err != nil {
+			return t, err
+		}
+		for _, p := range pairings {
+			game := db.TournamentGame{Round: round, White: p.White, Black: p.Black}
+			if p.Bye() {
+				game.Done = true
+				game.WhiteScore = tournament.ByeScore
+			}
+			games = append(games, game)
+		}
+	}
+
+	created := createRoundGames(t, games)
+	t, err := db.Tournaments.StartRound(t.ID, round, games)
+	if err != nil {
+		deleteRoundGames(created)
+		return t, err
+	}
+	seatRoundGames(games, created)
+	return t, nil
+}
+
+// Returns the pairings of a round of a round robin or a Swiss
+// tournament.
+func roundPairings(t db.Tournament, round int) ([]tournament.Pairing, error) {
+	if t.Format == tournament.Swiss {
+		return tournament.SwissPairings(t.Players, t.Results())
+	}
+	schedule, err := tournament.RoundRobinSchedule(t.Players, t.Format == tournament.DoubleRoundRobin)
+	if err != nil {
+		return nil, err
+	}
+	return schedule[round-1], nil
+}
+
+// Returns the matches of all rounds of a knockout tournament with
+// the games played so far. Players of later rounds are 0 until they
+// are known.
+func knockoutBracket(t db.Tournament) [][]tournament.Match {
+	if len(t.Players) < 2 {
+		return nil
+	}
+	rounds := tournament.KnockoutRounds(len(t.Players))
+	results := t.Results()
+	matches := tournament.FirstRound(t.Players)
+	var bracket [][]tournament.Match
+	for round := 1; round <= rounds; round++ {
+		if round > 1 {
+			matches = tournament.NextRound(matches, t.MatchConfig())
+		}
+		for _, result := range results {
+			if result.Round == round && result.Black != 0 {
+				matches[result.Match].Results = append(matches[result.Match].Results, result)
+			}
+		}
+		bracket = append(bracket, matches)
+	}
+	return bracket
+}
+
+// Creates the games of a round that aren't done already, with the
+// time limits of the tournament, and sets their IDs. The games are
+// only started by seatRoundGames once they belong to the round, so
+// no result can get lost.
+func createRoundGames(t db.Tournament, games []db.TournamentGame) map[int32]*db.Game {
+	created := make(map[int32]*db.Game)
+	for i := range games {
+		if games[i].Done {
+			continue
+		}
+		timeControl, moveTime, moveTimeout := t.TimeControl, t.MoveTime, t.MoveTimeout
+		if games[i].Kind != tournament.GameRegular && t.TiebreakControl != nil {
+			timeControl, moveTime, moveTimeout = t.TiebreakControl, 0, ""
+		}
+		if timeControl != nil {
+			tc := *timeControl
+			timeControl = &tc
+		}
+		name := fmt.Sprintf("%s, round %d", t.Name, games[i].Round)
+		if games[i].Kind != tournament.GameRegular {
+			name += ", " + games[i].Kind
+		}
+		game, _ := createSession(name, timeControl, moveTime, moveTimeout)
+		games[i].GameID = game.ID
+		created[game.ID] = game
+	}
+	return created
+}
+
+// Seats the players of created games, which starts them. They play
+// with their API keys.
+func seatRoundGames(games []db.TournamentGame, created map[int32]*db.Game) {
+	for _, entry := range games {
+		if game, exists := created[entry.GameID]; exists {
+			sendCommand(game, func(game *db.Game) (int, error) {
+				joinGame(game, "w", generateToken(), entry.White, "")
+				return joinGame(game, "b", generateToken(), entry.Black, "")
+			})
+		}
+	}
+}
+
+func deleteRoundGames(created map[int32]*db.Game) {
+	for id := range created {
+		db.Games.Delete(id)
+	}
+}
+
+// Records the result of a finished or aborted game if it belongs to
+// a tournament and starts the games that are due. Aborted games count
+// as lost for both players. Called with game.Mu held.
+func tournamentGameOver(game *db.Game) {
+	if _, member := db.Tournaments.ByGame(game.ID); !member {
+		return
+	}
+	var white, black float64
+	if game.State == db.StateFinished {
+		switch game.Winner {
+		case "w":
+			white = 1
+		case "b":
+			black = 1
+		case "r":
+			white, black = 0.5, 0.5
+		}
+	}
+	t, err := db.Tournaments.RecordResult(game.ID, white, black)
+	if err != nil {
+		log.Printf("Failed to record game %d in its tournament: %v", game.ID, err)
+		return
+	}
+	if err := advanceTournament(t.ID); err != nil {
+		log.Printf("Failed to continue tournament %d: %v", t.ID, err)
+	}
+}
+
+// Starts the games that are due: the next games of undecided
+// knockout matches, and the next round once the current one is over.
+func advanceTournament(id int32) error {
+	tournamentMu.Lock()
+	defer tournamentMu.Unlock()
+	t, err := db.Tournaments.Get(id)
+	if err != nil || t.State != db.TournamentRunning {
+		return err
+	}
+	if t.Format != tournament.Knockout {
+		if t.RoundDone() {
+			_, err = startNextRound(t)
+		}
+		return err
+	}
+
+	playing := make(map[int]bool)
+	for _, game := range t.Games {
+		if game.Round == t.Round && !game.Done {
+			playing[game.Match] = true
+		}
+	}
+	decided := true
+	var games []db.TournamentGame
+	for i, match := range knockoutBracket(t)[t.Round-1] {
+		if playing[i] {
+			decided = false
+			continue
+		}
+		if next, ok := match.Next(t.MatchConfig()); ok {
+			decided = false
+			games = append(games, db.TournamentGame{Round: t.Round, Match: i, Kind: next.Kind, White: next.White, Black: next.Black})
+		}
+	}
+	if decided {
+		_, err = startNextRound(t)
+		return err
+	}
+	if len(games) > 0 {
+		created := createRoundGames(t, games)
+		if _, err := db.Tournaments.AddGames(t.ID, games); err != nil {
+			deleteRoundGames(created)
+			return err
+		}
+		seatRoundGames(games, created)
+	}
+	return nil
+}
+
+// Catches up on tournaments after a restart: records the results of
+// games that ended while the server stopped and starts the rounds
+// that are due.
+func ResumeTournaments() {
+	for _, t := range db.Tournaments.List() {
+		if t.State != db.TournamentRunning {
+			continue
+		}
+		for _, entry := range t.Games {
+			if entry.Done || entry.GameID == 0 {
+				continue
+			}
+			game, err := db.Games.Get(entry.GameID)
+			if err != nil {
+				// Archived or removed before the result was recorded.
+				result, _ := db.Results.Get(entry.GameID)
+				db.Tournaments.RecordResult(entry.GameID, result.Score(entry.White), result.Score(entry.Black))
+				continue
+			}
+			sendCommand(game, func(game *db.Game) (int, error) {
+				if game.Over() {
+					tournamentGameOver(game)
+				}
+				return http.StatusOK, nil
+			})
+		}
+		if err := advanceTournament(t.ID); err != nil {
+			log.Printf("Failed to continue tournament %d: %v", t.ID, err)
+		}
+	}
+}
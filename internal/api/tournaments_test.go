@@ -0,0 +1,246 @@
+/*
+Tests of tournaments played by bots.
+*/
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/tournament"
+)
+
+func getPairings(t *testing.T, id int32, round int) []RespPairing {
+	rec := do(GetTournamentPairings, "GET", fmt.Sprintf("/tournaments/pairings?tournamentid=%d&round=%d", id, round), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("fail in GetTournamentPairings: %s", rec.Body)
+	}
+	var resp RespGetPairings
+	json.NewDecoder(rec.Body).Decode(&resp)
+	return resp.Pairings
+}
+
+// Plays the games of a round, the bot with the lower ID wins.
+func playRound(t *testing.T, id int32, round int, bots map[string]RespPostAccounts) {
+	for _, pairing := range getPairings(t, id, round) {
+		if pairing.Result != "" {
+			continue
+		}
+		white, black := bots[pairing.White], bots[pairing.Black]
+		loser, key := "b", black.Key
+		if white.Account.AccountID > black.Account.AccountID {
+			loser, key = "w", white.Key
+		}
+		rec := doKey(PutGame, "PUT", "/game", key, ReqPutGame{BoardID: pairing.BoardID, Color: loser, Forfeit: true})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("fail in PutGame: %s", rec.Body)
+		}
+	}
+}
+
+func createTournament(t *testing.T, user RespPostAccounts, format string, rounds int, bots []RespPostAccounts) RespTournament {
+	rec := doKey(PostTournaments, "POST", "/tournaments", user.Key, ReqPostTournaments{Name: "cup", Format: format, Rounds: rounds, BaseMs: 60 * 1000})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("fail in PostTournaments: %s", rec.Body)
+	}
+	var created RespTournament
+	json.NewDecoder(rec.Body).Decode(&created)
+	for _, bot := range bots {
+		// Bots are registered by their owner.
+		rec := doKey(PutTournaments, "PUT", "/tournaments", user.Key, ReqPutTournaments{TournamentID: created.TournamentID, AccountID: bot.Account.AccountID})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("fail in PutTournaments: %s", rec.Body)
+		}
+	}
+	return created
+}
+
+func TestRoundRobinTournament(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+	db.Accounts = db.NewAccountRepository()
+	db.Tournaments = db.NewTournamentRepository()
+	user := createAccount(t, "alice", db.AccountUser, "")
+	bots := make(map[string]RespPostAccounts)
+	var seeded []RespPostAccounts
+	for _, name := range []string{"a", "b", "c"} {
+		bot := createAccount(t, name, db.AccountBot, user.Key)
+		bots[name] = bot
+		seeded = append(seeded, bot)
+	}
+	created := createTournament(t, user, tournament.RoundRobin, 0, seeded)
+
+	if rec := doKey(PostTournamentStart, "POST", "/tournaments/start", bots["a"].Key, ReqPostTournamentStart{TournamentID: created.TournamentID}); rec.Code != http.StatusForbidden {
+		t.Errorf("expected only the creator to start, got %d", rec.Code)
+	}
+	rec := doKey(PostTournamentStart, "POST", "/tournaments/start", user.Key, ReqPostTournamentStart{TournamentID: created.TournamentID})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("fail in PostTournamentStart: %s", rec.Body)
+	}
+	var started RespTournament
+	json.NewDecoder(rec.Body).Decode(&started)
+	if started.State != db.TournamentRunning || started.Rounds != 3 || started.Round != 1 {
+		t.Fatalf("expected round 1 of 3, got %+v", started)
+	}
+	other := createAccount(t, "late", db.AccountBot, user.Key)
+	if rec := doKey(PutTournaments, "PUT", "/tournaments", other.Key, ReqPutTournaments{TournamentID: created.TournamentID, AccountID: other.Account.AccountID}); rec.Code != http.StatusConflict {
+		t.Errorf("expected late registrations to be rejected, got %d", rec.Code)
+	}
+
+	for round := 1; round <= 3; round++ {
+		pairings := getPairings(t, created.TournamentID, round)
+		if len(pairings) != 2 || (pairings[0].Result == "bye") == (pairings[1].Result == "bye") {
+			t.Fatalf("round %d: expected a game and a bye, got %+v", round, pairings)
+		}
+		playRound(t, created.TournamentID, round, bots)
+	}
+
+	rec = do(GetTournamentStandings, "GET", fmt.Sprintf("/tournaments/standings?tournamentid=%d", created.TournamentID), nil)
+	var standings RespGetStandings
+	json.NewDecoder(rec.Body).Decode(&standings)
+	if standings.Tournament.State != db.TournamentFinished {
+		t.Errorf("expected the tournament to be finished, got %s", standings.Tournament.State)
+	}
+	var order []string
+	for _, s := range standings.Standings {
+		order = append(order, fmt.Sprintf("%s:%g", s.Account, s.Points))
+	}
+	if fmt.Sprint(order) != "[a:3 b:2 c:1]" {
+		t.Errorf("expected a, b and c with a bye each, got %v", order)
+	}
+
+	rec = do(GetTournamentCrosstable, "GET", fmt.Sprintf("/tournaments/crosstable?tournamentid=%d", created.TournamentID), nil)
+	var crosstable RespGetCrosstable
+	json.NewDecoder(rec.Body).Decode(&crosstable)
+	if fmt.Sprint(crosstable.Rows[0].Cells) != "[x 1 1]" || fmt.Sprint(crosstable.Rows[2].Cells) != "[0 0 x]" || len(crosstable.Rows[1].Rounds) != 3 {
+		t.Errorf("unexpected crosstable %+v", crosstable.Rows)
+	}
+}
+
+func TestSwissTournament(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+	db.Accounts = db.NewAccountRepository()
+	db.Tournaments = db.NewTournamentRepository()
+	user := createAccount(t, "alice", db.AccountUser, "")
+	bots := make(map[string]RespPostAccounts)
+	var seeded []RespPostAccounts
+	for _, name := range []string{"a", "b", "c", "d"} {
+		bot := createAccount(t, name, db.AccountBot, user.Key)
+		bots[name] = bot
+		seeded = append(seeded, bot)
+	}
+	if rec := doKey(PostTournaments, "POST", "/tournaments", user.Key, ReqPostTournaments{Name: "swiss", Format: tournament.Swiss}); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected Swiss tournaments without rounds to be rejected, got %d", rec.Code)
+	}
+	created := createTournament(t, user, tournament.Swiss, 2, seeded)
+	doKey(PostTournamentStart, "POST", "/tournaments/start", user.Key, ReqPostTournamentStart{TournamentID: created.TournamentID})
+
+	playRound(t, created.TournamentID, 1, bots)
+	// The winners of the first round meet in the second.
+	pairings := getPairings(t, created.TournamentID, 2)
+	if len(pairings) != 2 || pairings[0].White+pairings[0].Black != "ca" && pairings[0].White+pairings[0].Black != "ac" {
+		t.Fatalf("expected a and c to meet, got %+v", pairings)
+	}
+	// An aborted game is lost by both.
+	game, _ := db.Games.Get(pairings[1].BoardID)
+	sendCommand(game, func(game *db.Game) (int, error) {
+		abortGame(game, "inactivity", game.CreatedAt)
+		return http.StatusOK, nil
+	})
+	playRound(t, created.TournamentID, 2, bots)
+
+	rec := do(GetTournamentStandings, "GET", fmt.Sprintf("/tournaments/standings?tournamentid=%d", created.TournamentID), nil)
+	var standings RespGetStandings
+	json.NewDecoder(rec.Body).Decode(&standings)
+	if standings.Tournament.State != db.TournamentFinished || standings.TieBreaks[0] != tournament.Buchholz {
+		t.Errorf("expected a finished tournament ranked by Buchholz, got %+v", standings)
+	}
+	if standings.Standings[0].Account != "a" || standings.Standings[0].Points != 2 || standings.Standings[3].Points != 0 {
+		t.Errorf("unexpected standings %+v", standings.Standings)
+	}
+	if pairings := getPairings(t, created.TournamentID, 2); pairings[1].Result != "0-0" {
+		t.Errorf("expected the aborted game to be lost by both, got %+v", pairings[1])
+	}
+}
+
+func TestKnockoutTournament(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+	db.Accounts = db.NewAccountRepository()
+	db.Tournaments = db.NewTournamentRepository()
+	user := createAccount(t, "alice", db.AccountUser, "")
+	bots := make(map[string]RespPostAccounts)
+	var seeded []RespPostAccounts
+	for _, name := range []string{"a", "b", "c"} {
+		bot := createAccount(t, name, db.AccountBot, user.Key)
+		bots[name] = bot
+		seeded = append(seeded, bot)
+	}
+	if rec := doKey(PostTournaments, "POST", "/tournaments", user.Key, ReqPostTournaments{Name: "swiss", Format: tournament.Swiss, Rounds: 2, GamesPerMatch: 2}); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected matches outside of knockouts to be rejected, got %d", rec.Code)
+	}
+	created := createTournament(t, user, tournament.Knockout, 0, seeded)
+	if created.GamesPerMatch != 2 {
+		t.Errorf("expected matches of 2 games by default, got %d", created.GamesPerMatch)
+	}
+	doKey(PostTournamentStart, "POST", "/tournaments/start", user.Key, ReqPostTournamentStart{TournamentID: created.TournamentID})
+
+	// The top seed a has a bye, b and c share the regular games.
+	forfeit := func(color string) {
+		pairings := getPairings(t, created.TournamentID, 1)
+		pairing := pairings[len(pairings)-1]
+		key := bots[pairing.Black].Key
+		if color == "w" {
+			key = bots[pairing.White].Key
+		}
+		rec := doKey(PutGame, "PUT", "/game", key, ReqPutGame{BoardID: pairing.BoardID, Color: color, Forfeit: true})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("fail in PutGame: %s", rec.Body)
+		}
+	}
+	forfeit("b")
+	forfeit("b")
+
+	// The armageddon game goes to black with a draw.
+	pairings := getPairings(t, created.TournamentID, 1)
+	if len(pairings) != 4 || pairings[0].Result != "bye" || pairings[3].Kind != tournament.GameArmageddon {
+		t.Fatalf("expected an armageddon game after a tied match, got %+v", pairings)
+	}
+	armageddon := pairings[3]
+	if armageddon.White != "b" {
+		t.Errorf("expected b to have white after black, got %+v", armageddon)
+	}
+	doKey(PutGame, "PUT", "/game", bots["b"].Key, ReqPutGame{BoardID: armageddon.BoardID, Color: "w", Draw: "offer"})
+	rec := doKey(PutGame, "PUT", "/game", bots["c"].Key, ReqPutGame{BoardID: armageddon.BoardID, Color: "b", Draw: "accept"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("fail in PutGame: %s", rec.Body)
+	}
+
+	// a wins the final in two games.
+	playRound(t, created.TournamentID, 2, bots)
+	playRound(t, created.TournamentID, 2, bots)
+
+	rec = do(GetTournamentBracket, "GET", fmt.Sprintf("/tournaments/bracket?tournamentid=%d", created.TournamentID), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("fail in GetTournamentBracket: %s", rec.Body)
+	}
+	var bracket RespGetBracket
+	json.NewDecoder(rec.Body).Decode(&bracket)
+	if bracket.Tournament.State != db.TournamentFinished || len(bracket.Rounds) != 2 {
+		t.Fatalf("expected a finished bracket of 2 rounds, got %+v", bracket)
+	}
+	semifinal := bracket.Rounds[0].Matches[1]
+	if semifinal.Winner != "c" || semifinal.AScore != 1.5 || semifinal.BScore != 1.5 || len(semifinal.Games) != 3 {
+		t.Errorf("expected c to advance with the armageddon draw, got %+v", semifinal)
+	}
+	final := bracket.Rounds[1].Matches[0]
+	if final.A != "a" || final.B != "c" || final.Winner != "a" || final.AScore != 2 {
+		t.Errorf("unexpected final %+v", final)
+	}
+
+	rec = do(GetTournamentBracket, "GET", "/tournaments/bracket?tournamentid=99", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected an unknown tournament to be not found, got %d", rec.Code)
+	}
+}
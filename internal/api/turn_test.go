@@ -0,0 +1,93 @@
+/*
+Tests of waiting for the turn with GetGame.
+*/
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+)
+
+// Starts a turn request of color in the background. The recorder may
+// only be read after done is closed.
+func waitTurn(ctx context.Context, session RespPostSessions, color string, token string, params string) (*httptest.ResponseRecorder, chan struct{}) {
+	target := fmt.Sprintf("/game?boardid=%d&password=%s&color=%s&token=%s&turnreq=true%s",
+		session.BoardID, session.Password, color, token, params)
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		GetGame(rec, httptest.NewRequest("GET", target, nil).WithContext(ctx))
+	}()
+	return rec, done
+}
+
+func TestTurnRequestWakesOnMove(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+	session := createGame(t)
+	white, _ := joinColor(session, "w")
+	black, _ := joinColor(session, "b")
+
+	rec, done := waitTurn(context.Background(), session, "b", black, "&timeoutms=5000")
+	select {
+	case <-done:
+		t.Fatalf("expected black to wait for white, got %d %s", rec.Code, rec.Body)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	playMove(t, session, "w", white, "e2 e4")
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the move to wake the turn request")
+	}
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestTurnRequestCancelled(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+	session := createGame(t)
+	joinColor(session, "w")
+	black, _ := joinColor(session, "b")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rec, done := waitTurn(ctx, session, "b", black, "")
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the turn request to end with its client")
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("expected no response to a gone client, got %s", rec.Body)
+	}
+}
+
+func TestTurnRequestTimeout(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+	session := createGame(t)
+	joinColor(session, "w")
+	black, _ := joinColor(session, "b")
+
+	for _, timeoutms := range []int64{-1, maxTurnTimeout.Milliseconds() + 1} {
+		rec, done := waitTurn(context.Background(), session, "b", black, fmt.Sprintf("&timeoutms=%d", timeoutms))
+		<-done
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("timeoutms %d: expected 400, got %d", timeoutms, rec.Code)
+		}
+	}
+
+	rec, done := waitTurn(context.Background(), session, "b", black, "&timeoutms=10")
+	<-done
+	if rec.Code != http.StatusRequestTimeout {
+		t.Errorf("expected 408, got %d %s", rec.Code, rec.Body)
+	}
+}
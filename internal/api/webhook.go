@@ -0,0 +1,145 @@
+/*
+Seats played by webhooks. When it is the turn of a color bound to a
+callback URL, the server POSTs the state of the game to it and plays
+the answered move, validated like the moves of PutGame. Failed calls
+and invalid moves are retried, after the last attempt the color
+forfeits.
+*/
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/game_logic"
+)
+
+// Time a callback has to answer.
+var WebhookTimeout = 10 * time.Second
+
+// Calls per turn before the color forfeits.
+var WebhookAttempts = 3
+
+// Pause before the second attempt, doubled for every further one.
+var WebhookBackoff = time.Second
+
+var webhookClient = &http.Client{}
+
+// Largest answer read from a callback.
+const maxWebhookResponse = 64 << 10
+
+var errTurnOver = errors.New("the turn is over")
+
+func validCallback(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// Calls the callback of the player to move in the background, if
+// the color has one. Called with game.Mu held.
+func notifyWebhook(game *db.Game) {
+	if game.State != db.StateActive {
+		return
+	}
+	color := currentTurn(game)
+	callback := game.W_callback
+	if color == "b" {
+		callback = game.B_callback
+	}
+	if callback == "" {
+		return
+	}
+
+	req := ReqWebhook{
+		BoardID:    game.ID,
+		Color:      color,
+		FEN:        game.FEN(),
+		Moves:      []string{},
+		LegalMoves: []string{},
+		DrawOffer:  game.DrawOffer,
+		Clock:      clockState(game, time.Now()),
+		MoveTimeMs: game.MoveTime.Milliseconds(),
+	}
+	for _, record := range game.Moves {
+		req.Moves = append(req.Moves, record.Move)
+	}
+	for _, move := range game_logic.LegalMoves(game.Position()) {
+		req.LegalMoves = append(req.LegalMoves, move.String())
+	}
+	go deliverWebhook(game, callback, req, game.Plies())
+}
+
+// Asks the callback for the move after ply plies and plays it, with
+// retries. Gives up once the turn is over in another way, e.g. by a
+// timeout.
+func deliverWebhook(game *db.Game, callback string, req ReqWebhook, ply int) {
+	onTurn := func(fn func(game *db.Game) (int, error)) error {
+		_, err := sendCommand(game, func(game *db.Game) (int, error) {
+			if game.Over() || game.Plies() != ply {
+				return http.StatusConflict, errTurnOver
+			}
+			return fn(game)
+		})
+		return err
+	}
+	idle := func(game *db.Game) (int, error) { return http.StatusOK, nil }
+
+	var err error
+	for attempt := 0; attempt < WebhookAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(WebhookBackoff << (attempt - 1))
+			if onTurn(idle) != nil {
+				return
+			}
+		}
+		var resp RespWebhook
+		resp, err = callWebhook(callback, req)
+		if err != nil {
+			continue
+		}
+		err = onTurn(func(game *db.Game) (int, error) {
+			return playTurn(game, req.Color, resp.Move, resp.Forfeit)
+		})
+		if err == nil || errors.Is(err, errTurnOver) {
+			return
+		}
+	}
+	log.Printf("Callback of %s in game %d failed: %v", req.Color, req.BoardID, err)
+	onTurn(func(game *db.Game) (int, error) {
+		return playTurn(game, req.Color, "", true)
+	})
+}
+
+func callWebhook(callback string, req ReqWebhook) (RespWebhook, error) {
+	var resp RespWebhook
+	body, err := json.Marshal(req)
+	if err != nil {
+		return resp, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), WebhookTimeout)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, callback, bytes.NewReader(body))
+	if err != nil {
+		return resp, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpResp, err := webhookClient.Do(httpReq)
+	if err != nil {
+		return resp, err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return resp, fmt.Errorf("callback answered %s", httpResp.Status)
+	}
+	err = json.NewDecoder(io.LimitReader(httpResp.Body, maxWebhookResponse)).Decode(&resp)
+	return resp, err
+}
@@ -0,0 +1,115 @@
+/*
+Tests of seats played by webhooks.
+*/
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+)
+
+// Stand-in for a stateless bot. Answers with the move returned by
+// play for each request it receives.
+func webhookBot(t *testing.T, play func(req ReqWebhook) RespWebhook) (*httptest.Server, *[]ReqWebhook) {
+	var mu sync.Mutex
+	var received []ReqWebhook
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ReqWebhook
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid webhook payload: %v", err)
+		}
+		mu.Lock()
+		received = append(received, req)
+		mu.Unlock()
+		json.NewEncoder(w).Encode(play(req))
+	}))
+	t.Cleanup(server.Close)
+	return server, &received
+}
+
+// Waits until the game has the given number of moves or is over.
+func waitPlies(t *testing.T, id int32, plies int) *db.Game {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		game, _ := db.Games.Get(id)
+		game.Mu.RLock()
+		done := game.Plies() >= plies || game.Over()
+		game.Mu.RUnlock()
+		if done {
+			return game
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("game %d didn't reach %d plies", id, plies)
+	return nil
+}
+
+func TestWebhookPlayer(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+	server, received := webhookBot(t, func(req ReqWebhook) RespWebhook {
+		return RespWebhook{Move: req.LegalMoves[0]}
+	})
+	session := createGame(t)
+
+	rec := do(PutSessions, "PUT", "/sessions", ReqPutSessions{BoardID: session.BoardID, Password: session.Password, Color: "w", Callback: "ftp://bot"})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected a callback without http to be rejected, got %d", rec.Code)
+	}
+	rec = do(PutSessions, "PUT", "/sessions", ReqPutSessions{BoardID: session.BoardID, Password: session.Password, Color: "w", Callback: server.URL})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("fail in PutSessions: %s", rec.Body)
+	}
+	token, _ := joinColor(session, "b")
+
+	// White moves as soon as the game starts.
+	waitPlies(t, session.BoardID, 1)
+	rec = do(PutGame, "PUT", "/game", ReqPutGame{BoardID: session.BoardID, Password: session.Password, Color: "b", Token: token, Move: "e7 e5"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("fail in PutGame: %s", rec.Body)
+	}
+	game := waitPlies(t, session.BoardID, 3)
+	if len(*received) != 2 {
+		t.Fatalf("expected 2 calls, got %d", len(*received))
+	}
+	second := (*received)[1]
+	if second.Color != "w" || len(second.Moves) != 2 || second.Moves[1] != "e7 e5" || len(second.LegalMoves) == 0 {
+		t.Errorf("unexpected payload %+v", second)
+	}
+	if !strings.HasSuffix(second.FEN, "/RNBQKBNR w - e6 0 2") {
+		t.Errorf("unexpected FEN %s", second.FEN)
+	}
+	if game.W_callback != server.URL || game.Moves[2].Color != "w" {
+		t.Errorf("expected white to keep playing by its callback")
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+	defer func(backoff time.Duration) { WebhookBackoff = backoff }(WebhookBackoff)
+	WebhookBackoff = time.Millisecond
+
+	// Invalid moves are retried like failed calls, then white forfeits.
+	server, received := webhookBot(t, func(req ReqWebhook) RespWebhook {
+		return RespWebhook{Move: "e2 e5"}
+	})
+	session := createGame(t)
+	do(PutSessions, "PUT", "/sessions", ReqPutSessions{BoardID: session.BoardID, Password: session.Password, Color: "w", Callback: server.URL})
+	joinColor(session, "b")
+
+	game := waitPlies(t, session.BoardID, 1)
+	game.Mu.RLock()
+	defer game.Mu.RUnlock()
+	if game.State != db.StateFinished || game.Winner != "b" || game.Termination != "forfeit" {
+		t.Errorf("expected white to forfeit, got %s %s %s", game.State, game.Winner, game.Termination)
+	}
+	if len(*received) != WebhookAttempts {
+		t.Errorf("expected %d attempts, got %d", WebhookAttempts, len(*received))
+	}
+}
@@ -0,0 +1,426 @@
+/*
+Accounts of users and bots. Every account has a unique name, an owner
+and API keys. A user owns itself, a bot is owned by a user. Keys are
+only shown when they are created, the accounts keep their hashes.
+
+Like games, accounts are kept as a journal of entries that is
+replayed when the server starts.
+*/
+package database
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/rating"
+)
+
+// Kinds of accounts.
+const (
+	AccountUser = "user"
+	AccountBot  = "bot"
+)
+
+// Prefix of API keys, tells them apart from session passwords and
+// seat tokens. A key is APIKeyPrefix + key ID + "_" + secret.
+const APIKeyPrefix = "ak_"
+
+var (
+	ErrNameTaken      = errors.New("account name is already taken")
+	ErrInvalidKey     = errors.New("invalid API key")
+	ErrKeyNotFound    = errors.New("API key not found")
+	ErrAccountMissing = errors.New("account not found")
+)
+
+var accountNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+type Account struct {
+	ID        int32
+	Name      string
+	Owner     string // Name of the owning user, the own name for users.
+	Kind      string // AccountUser or AccountBot.
+	CreatedAt time.Time
+	Keys      []APIKey
+	Ratings   map[string]rating.Rating // By category, see ratings.go.
+	History   []RatingRecord
+}
+
+type APIKey struct {
+	ID        string
+	Hash      string // HashSecret of the secret.
+	CreatedAt time.Time
+	RevokedAt time.Time // Zero while the key is valid.
+}
+
+func (key APIKey) Active() bool {
+	return key.RevokedAt.IsZero()
+}
+
+// Types of account journal entries.
+const (
+	AccountCreated    = "created"
+	AccountKeyAdded   = "keyadded"
+	AccountKeyRevoked = "keyrevoked"
+	AccountRated      = "rated"
+)
+
+type AccountEntry struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Account int32     `json:"account"`
+
+	// Created
+	Name  string `json:"name,omitempty"`
+	Owner string `json:"owner,omitempty"`
+	Kind  string `json:"kind,omitempty"`
+
+	// Key added or revoked, or the first key of a created account
+	KeyID   string `json:"keyid,omitempty"`
+	KeyHash string `json:"keyhash,omitempty"`
+
+	// Rated
+	Record *RatingRecord `json:"record,omitempty"`
+}
+
+// Persistence of the account journal.
+type AccountStore interface {
+	Append(entry AccountEntry) error
+	Load() ([]AccountEntry, error)
+	Close() error
+}
+
+type AccountRepository struct {
+	mu       sync.RWMutex
+	accounts map[int32]*Account
+	byName   map[string]*Account
+	byKey    map[string]*Account // By key ID.
+	nextID   int32
+	store    AccountStore // nil if accounts aren't persisted.
+}
+
+func NewAccountRepository() *AccountRepository {
+	return &AccountRepository{
+		accounts: make(map[int32]*Account),
+		byName:   make(map[string]*Account),
+		byKey:    make(map[string]*Account),
+		nextID:   1,
+	}
+}
+
+// Returns a repository backed by store, filled with the accounts
+// stored in it.
+func OpenAccountRepository(store AccountStore) (*AccountRepository, error) {
+	entries, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	repo := NewAccountRepository()
+	for i, entry := range entries {
+		if err := repo.check(entry); err != nil {
+			return nil, fmt.Errorf("account entry %d (%s): %v", i+1, entry.Type, err)
+		}
+		repo.apply(entry)
+	}
+	repo.store = store
+	return repo, nil
+}
+
+// Creates an account. Bots have to be owned by an existing user.
+func (repo *AccountRepository) Create(name string, owner string, kind string) (Account, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if kind == AccountUser {
+		owner = name
+	}
+	entry := AccountEntry{
+		Time:    time.Now(),
+		Type:    AccountCreated,
+		Account: repo.nextID,
+		Name:    name,
+		Owner:   owner,
+		Kind:    kind,
+	}
+	if err := repo.commit(entry); err != nil {
+		return Account{}, err
+	}
+	return repo.accounts[entry.Account].copy(), nil
+}
+
+// Creates an account together with its first API key, so no account
+// is left without a key. Returns the key like AddKey.
+func (repo *AccountRepository) CreateWithKey(name string, owner string, kind string) (Account, string, APIKey, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if kind == AccountUser {
+		owner = name
+	}
+	keyID, secret, err := repo.newKey()
+	if err != nil {
+		return Account{}, "", APIKey{}, err
+	}
+	entry := AccountEntry{
+		Time:    time.Now(),
+		Type:    AccountCreated,
+		Account: repo.nextID,
+		Name:    name,
+		Owner:   owner,
+		Kind:    kind,
+		KeyID:   keyID,
+		KeyHash: HashSecret(secret),
+	}
+	if err := repo.commit(entry); err != nil {
+		return Account{}, "", APIKey{}, err
+	}
+	account := repo.accounts[entry.Account]
+	return account.copy(), APIKeyPrefix + keyID + "_" + secret, account.Keys[0], nil
+}
+
+func (repo *AccountRepository) Get(id int32) (Account, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	account, exists := repo.accounts[id]
+	if !exists {
+		return Account{}, ErrAccountMissing
+	}
+	return account.copy(), nil
+}
+
+func (repo *AccountRepository) Find(name string) (Account, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	account, exists := repo.byName[name]
+	if !exists {
+		return Account{}, ErrAccountMissing
+	}
+	return account.copy(), nil
+}
+
+// Returns all accounts ordered by ID.
+func (repo *AccountRepository) List() []Account {
+	repo.mu.RLock()
+	accounts := make([]Account, 0, len(repo.accounts))
+	for _, account := range repo.accounts {
+		accounts = append(accounts, account.copy())
+	}
+	repo.mu.RUnlock()
+
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].ID < accounts[j].ID
+	})
+	return accounts
+}
+
+// Adds an API key to an account. Returns the key, which can't be
+// recovered later, and its description.
+func (repo *AccountRepository) AddKey(id int32) (string, APIKey, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	keyID, secret, err := repo.newKey()
+	if err != nil {
+		return "", APIKey{}, err
+	}
+	entry := AccountEntry{
+		Time:    time.Now(),
+		Type:    AccountKeyAdded,
+		Account: id,
+		KeyID:   keyID,
+		KeyHash: HashSecret(secret),
+	}
+	if err := repo.commit(entry); err != nil {
+		return "", APIKey{}, err
+	}
+	account := repo.accounts[id]
+	return APIKeyPrefix + keyID + "_" + secret, account.Keys[len(account.Keys)-1], nil
+}
+
+// Returns the ID and the secret of a new key, with an ID no other key
+// has. Called with repo.mu held.
+func (repo *AccountRepository) newKey() (string, string, error) {
+	for {
+		keyID, err := randomHex(8)
+		if err != nil {
+			return "", "", err
+		}
+		if _, exists := repo.byKey[keyID]; exists {
+			continue
+		}
+		secret, err := randomHex(24)
+		return keyID, secret, err
+	}
+}
+
+// Revokes an API key of an account.
+func (repo *AccountRepository) RevokeKey(id int32, keyID string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	return repo.commit(AccountEntry{
+		Time:    time.Now(),
+		Type:    AccountKeyRevoked,
+		Account: id,
+		KeyID:   keyID,
+	})
+}
+
+// Returns the account an API key belongs to, if the key is valid.
+func (repo *AccountRepository) Authenticate(key string) (Account, error) {
+	keyID, secret, found := strings.Cut(strings.TrimPrefix(key, APIKeyPrefix), "_")
+	if !found || !strings.HasPrefix(key, APIKeyPrefix) {
+		return Account{}, ErrInvalidKey
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	account, exists := repo.byKey[keyID]
+	if !exists {
+		return Account{}, ErrInvalidKey
+	}
+	for _, stored := range account.Keys {
+		if stored.ID == keyID && stored.Active() && SecretMatches(stored.Hash, secret) {
+			return account.copy(), nil
+		}
+	}
+	return Account{}, ErrInvalidKey
+}
+
+// Validates an entry, persists it and applies it. Called with
+// repo.mu held.
+func (repo *AccountRepository) commit(entry AccountEntry) error {
+	if err := repo.check(entry); err != nil {
+		return err
+	}
+	if repo.store != nil {
+		if err := repo.store.Append(entry); err != nil {
+			return err
+		}
+	}
+	repo.apply(entry)
+	return nil
+}
+
+// Returns why an entry can't be applied, nil if it can.
+func (repo *AccountRepository) check(entry AccountEntry) error {
+	switch entry.Type {
+	case AccountCreated:
+		if !accountNamePattern.MatchString(entry.Name) {
+			return errors.New("account names have 1 to 32 letters, digits, '-' or '_'")
+		}
+		if _, exists := repo.byName[entry.Name]; exists {
+			return ErrNameTaken
+		}
+		switch entry.Kind {
+		case AccountUser:
+		case AccountBot:
+			owner, exists := repo.byName[entry.Owner]
+			if !exists || owner.Kind != AccountUser {
+				return errors.New("bots have to be owned by a user")
+			}
+		default:
+			return fmt.Errorf("invalid account kind %q", entry.Kind)
+		}
+		if _, exists := repo.byKey[entry.KeyID]; exists && entry.KeyID != "" {
+			return errors.New("duplicate key ID")
+		}
+
+	case AccountKeyAdded:
+		if _, exists := repo.accounts[entry.Account]; !exists {
+			return ErrAccountMissing
+		}
+		if _, exists := repo.byKey[entry.KeyID]; exists {
+			return errors.New("duplicate key ID")
+		}
+
+	case AccountKeyRevoked:
+		account, exists := repo.accounts[entry.Account]
+		if !exists {
+			return ErrAccountMissing
+		}
+		for _, key := range account.Keys {
+			if key.ID == entry.KeyID && key.Active() {
+				return nil
+			}
+		}
+		return ErrKeyNotFound
+
+	case AccountRated:
+		return repo.checkRated(entry)
+
+	default:
+		return fmt.Errorf("unknown entry type %q", entry.Type)
+	}
+	return nil
+}
+
+// Applies a checked entry. Called with repo.mu held, or while
+// loading.
+func (repo *AccountRepository) apply(entry AccountEntry) {
+	switch entry.Type {
+	case AccountCreated:
+		account := &Account{
+			ID:        entry.Account,
+			Name:      entry.Name,
+			Owner:     entry.Owner,
+			Kind:      entry.Kind,
+			CreatedAt: entry.Time,
+		}
+		if entry.KeyID != "" {
+			account.Keys = []APIKey{{ID: entry.KeyID, Hash: entry.KeyHash, CreatedAt: entry.Time}}
+			repo.byKey[entry.KeyID] = account
+		}
+		repo.accounts[account.ID] = account
+		repo.byName[account.Name] = account
+		if account.ID >= repo.nextID {
+			repo.nextID = account.ID + 1
+		}
+
+	case AccountKeyAdded:
+		account := repo.accounts[entry.Account]
+		account.Keys = append(account.Keys, APIKey{
+			ID:        entry.KeyID,
+			Hash:      entry.KeyHash,
+			CreatedAt: entry.Time,
+		})
+		repo.byKey[entry.KeyID] = account
+
+	case AccountKeyRevoked:
+		account := repo.accounts[entry.Account]
+		for i := range account.Keys {
+			if account.Keys[i].ID == entry.KeyID {
+				account.Keys[i].RevokedAt = entry.Time
+			}
+		}
+
+	case AccountRated:
+		repo.applyRated(entry)
+	}
+}
+
+// Returns a copy safe to use without the lock.
+func (account *Account) copy() Account {
+	c := *account
+	c.Keys = append([]APIKey(nil), account.Keys...)
+	c.Ratings = make(map[string]rating.Rating, len(account.Ratings))
+	for category, r := range account.Ratings {
+		c.Ratings[category] = r
+	}
+	// The history is only appended to, later entries aren't seen.
+	c.History = account.History[:len(account.History):len(account.History)]
+	return c
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Accounts of the server.
+var Accounts = NewAccountRepository()
@@ -0,0 +1,138 @@
+/*
+Unittest for accounts and API keys.
+*/
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/rating"
+)
+
+func TestAccountKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "games.log.accounts")
+	store, err := OpenFileAccountStore(path)
+	if err != nil {
+		t.Fatalf("fail in OpenFileAccountStore: %s", err)
+	}
+	repo, _ := OpenAccountRepository(store)
+
+	user, err := repo.Create("alice", "", AccountUser)
+	if err != nil {
+		t.Fatalf("fail in Create: %s", err)
+	}
+	bot, err := repo.Create("alicebot", "alice", AccountBot)
+	if err != nil || bot.Owner != "alice" {
+		t.Fatalf("fail in Create: %v", err)
+	}
+	if _, err := repo.Create("alice", "", AccountUser); err != ErrNameTaken {
+		t.Errorf("expected the name to be taken, got %v", err)
+	}
+	if _, err := repo.Create("orphan", "alicebot", AccountBot); err == nil {
+		t.Errorf("expected bots to be owned by users only")
+	}
+
+	key, apiKey, err := repo.AddKey(bot.ID)
+	if err != nil {
+		t.Fatalf("fail in AddKey: %s", err)
+	}
+	if account, err := repo.Authenticate(key); err != nil || account.ID != bot.ID {
+		t.Errorf("expected the key to belong to the bot, got %v", err)
+	}
+	for _, invalid := range []string{"", key + "x", APIKeyPrefix + apiKey.ID + "_", key[len(APIKeyPrefix):]} {
+		if _, err := repo.Authenticate(invalid); err != ErrInvalidKey {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+	userKey, _, _ := repo.AddKey(user.ID)
+	if err := repo.RevokeKey(bot.ID, apiKey.ID); err != nil {
+		t.Fatalf("fail in RevokeKey: %s", err)
+	}
+	if _, err := repo.Authenticate(key); err != ErrInvalidKey {
+		t.Errorf("expected the revoked key to be rejected")
+	}
+	if err := repo.RevokeKey(bot.ID, apiKey.ID); err != ErrKeyNotFound {
+		t.Errorf("expected revoking twice to fail, got %v", err)
+	}
+	store.Close()
+
+	store, _ = OpenFileAccountStore(path)
+	defer store.Close()
+	repo, err = OpenAccountRepository(store)
+	if err != nil {
+		t.Fatalf("fail in OpenAccountRepository: %s", err)
+	}
+	if accounts := repo.List(); len(accounts) != 2 {
+		t.Fatalf("expected 2 accounts, got %d", len(accounts))
+	}
+	if _, err := repo.Authenticate(key); err != ErrInvalidKey {
+		t.Errorf("expected the key to stay revoked")
+	}
+	if account, err := repo.Authenticate(userKey); err != nil || account.Name != "alice" {
+		t.Errorf("expected the key of the user to be restored")
+	}
+	if next, _ := repo.Create("bob", "", AccountUser); next.ID != 3 {
+		t.Errorf("expected ID 3, got %d", next.ID)
+	}
+}
+
+func TestAccountCreatedWithKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "games.log.accounts")
+	store, _ := OpenFileAccountStore(path)
+	repo, _ := OpenAccountRepository(store)
+
+	account, key, apiKey, err := repo.CreateWithKey("alice", "", AccountUser)
+	if err != nil || len(account.Keys) != 1 || account.Keys[0] != apiKey {
+		t.Fatalf("fail in CreateWithKey: %+v, %v", account, err)
+	}
+	// A name that is taken leaves no key behind.
+	if _, _, _, err := repo.CreateWithKey("alice", "", AccountUser); err != ErrNameTaken {
+		t.Errorf("expected the name to be taken, got %v", err)
+	}
+	store.Close()
+
+	store, _ = OpenFileAccountStore(path)
+	defer store.Close()
+	entries, _ := store.Load()
+	if len(entries) != 1 {
+		t.Errorf("expected the account and its key in one entry, got %d", len(entries))
+	}
+	repo, err = OpenAccountRepository(store)
+	if err != nil {
+		t.Fatalf("fail in OpenAccountRepository: %s", err)
+	}
+	if found, err := repo.Authenticate(key); err != nil || found.ID != account.ID {
+		t.Errorf("expected the key to be restored, got %v", err)
+	}
+}
+func TestRateGame(t *testing.T) {
+	repo := NewAccountRepository()
+	repo.Create("alice", "", AccountUser)
+	white, _ := repo.Create("white", "alice", AccountBot)
+	black, _ := repo.Create("black", "alice", AccountBot)
+
+	game := RatedGame{GameID: 7, Category: rating.Blitz, White: white.ID, Black: black.ID, Score: 1, Time: time.Now()}
+	if err := repo.RateGame(game, rating.DefaultConfig); err != nil {
+		t.Fatalf("fail in RateGame: %s", err)
+	}
+	if err := repo.RateGame(game, rating.DefaultConfig); err != ErrAlreadyRated {
+		t.Errorf("expected a game to be rated once, got %v", err)
+	}
+
+	white, _ = repo.Get(white.ID)
+	black, _ = repo.Get(black.ID)
+	if r := white.Rating(rating.Blitz); r.Games != 1 || r.Elo <= rating.InitialRating {
+		t.Errorf("expected the winner to gain, got %+v", r)
+	}
+	if r := black.Rating(rating.Blitz); r.Games != 1 || r.Elo >= rating.InitialRating {
+		t.Errorf("expected the loser to lose, got %+v", r)
+	}
+	if r := white.Rating(rating.Rapid); r.Games != 0 {
+		t.Errorf("expected categories to be rated separately")
+	}
+	if len(black.History) != 1 || black.History[0].Score != 0 || black.History[0].Opponent != white.ID {
+		t.Errorf("expected the game in the history of black, got %+v", black.History)
+	}
+}
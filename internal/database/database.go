@@ -5,24 +5,151 @@ package database
 
 import (
 	"sync"
+	"time"
 
 	"github.com/matetirpak/chess-server-and-api-for-developers/internal/game_logic"
 )
 
+// Time control of a game.
+type TimeControl struct {
+	Base      time.Duration `json:"base"`
+	Increment time.Duration `json:"increment"` // Fischer increment, added after each move.
+	Delay     time.Duration `json:"delay"`
+	DelayMode string        `json:"delaymode,omitempty"` // "simple" or "bronstein", set if Delay is used.
+}
+
+// Entry of the move history.
+type MoveRecord struct {
+	Color     string        `json:"color"`
+	Move      string        `json:"move"`
+	ThinkTime time.Duration `json:"thinktime"`
+	Fallback  bool          `json:"fallback,omitempty"` // Played by the server after the move time ran out.
+	Opening   bool          `json:"opening,omitempty"`  // Played by the server from a given opening.
+}
+
+// Event of a game, delivered to all subscribers.
+type Event struct {
+	Type    string
+	Payload interface{}
+}
+
 type Game struct {
 	Name          string
 	ID            int32
-	Password      string
+	PasswordHash  string // See secrets.go.
 	Started       bool
 	HasWPlayer    bool
 	W_playerToken string
 	HasBPlayer    bool
 	B_playerToken string
+	W_account     int32 // Account playing white, 0 if anonymous.
+	B_account     int32
+	W_callback    string // URL the server asks for the moves of white, "" if white moves itself.
+	B_callback    string
 	Winner        string
+	Termination   string
+	DrawOffer     string       // Color with an open draw offer, "" if none.
+	TimeControl   *TimeControl // nil for games without clocks.
+	W_timeLeft    time.Duration
+	B_timeLeft    time.Duration
+	TurnStart     time.Time
+	ClockTimer    *time.Timer
+	MoveTime      time.Duration // Time limit per move, 0 if unlimited.
+	MoveTimeout   string        // "forfeit" or "random", applied when the move time is exceeded.
+	DeadlineTimer *time.Timer
+	Moves         []MoveRecord // Moves[i] leads from the position after i moves to the next one.
 	Mu            sync.RWMutex
-	BoardData     []game_logic.BoardState
+	Journal       []JournalEntry // Entries not persisted yet, see Repository.Journal.
+	Deleted       bool
+
+	// Lifecycle, see lifecycle.go.
+	State      string
+	CreatedAt  time.Time
+	StartedAt  time.Time
+	LastMoveAt time.Time // Time of the last move, or the start.
+	EndedAt    time.Time
+
+	// Positions, derived from the journal. See history.go.
+	position    game_logic.BoardState
+	checkpoints []game_logic.BoardState // Position after every CheckpointInterval plies.
+	halfmoves   int                     // Plies since the last capture or pawn move.
+	journaled   int                     // Number of journal entries, persisted or not.
+
+	subMu       sync.Mutex
+	subscribers map[chan Event]struct{}
+	changed     chan struct{} // Closed on the next change, created lazily.
+}
+
+// Number of events a subscriber can fall behind before it is dropped.
+const subscriberBuffer = 64
+
+// Registers a subscriber for the events of the game. The channel is
+// closed when cancel is called, the game is closed or the subscriber
+// falls too far behind.
+func (game *Game) Subscribe() (<-chan Event, func()) {
+	game.subMu.Lock()
+	defer game.subMu.Unlock()
+	if game.subscribers == nil {
+		game.subscribers = make(map[chan Event]struct{})
+	}
+	ch := make(chan Event, subscriberBuffer)
+	game.subscribers[ch] = struct{}{}
+
+	cancel := func() {
+		game.subMu.Lock()
+		defer game.subMu.Unlock()
+		if _, exists := game.subscribers[ch]; exists {
+			delete(game.subscribers, ch)
+			close(ch)
+		}
+	}
+	return ch, cancel
+}
+
+// Delivers an event to all subscribers without blocking.
+func (game *Game) Publish(event Event) {
+	game.subMu.Lock()
+	defer game.subMu.Unlock()
+	for ch := range game.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(game.subscribers, ch)
+			close(ch)
+		}
+	}
 }
 
-var GamesMap = make(map[int32]*Game)
+// Closes the channels of all subscribers.
+func (game *Game) CloseSubscribers() {
+	game.subMu.Lock()
+	defer game.subMu.Unlock()
+	for ch := range game.subscribers {
+		delete(game.subscribers, ch)
+		close(ch)
+	}
+}
+
+// Returns a channel that is closed on the next change of the game.
+// Read it together with the state it waits on, under game.Mu.
+func (game *Game) Changed() <-chan struct{} {
+	game.subMu.Lock()
+	defer game.subMu.Unlock()
+	if game.changed == nil {
+		game.changed = make(chan struct{})
+	}
+	return game.changed
+}
+
+// Wakes all goroutines waiting on Changed. Called with game.Mu held.
+func (game *Game) NotifyChanged() {
+	game.subMu.Lock()
+	defer game.subMu.Unlock()
+	if game.changed != nil {
+		close(game.changed)
+		game.changed = nil
+	}
+}
 
-var Ids int32 = 1
+// Games of the server.
+var Games Repository = NewMemoryRepository()
@@ -0,0 +1,306 @@
+/*
+Store keeping the journals in one append-only file of JSON lines,
+one line per journal entry. Deleting a game appends a tombstone, the
+lines of deleted games are dropped when the file is compacted.
+Archived games are deleted as well, their journals are appended to a
+second file next to it, one line with the complete journal per game.
+*/
+package database
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// The file is compacted once the lines of deleted games take up
+// more space than the live ones, but not before they reach
+// minCompactSize.
+const minCompactSize = 1 << 20
+
+// Line of the file.
+type logEntry struct {
+	Op    string        `json:"op"` // "append" or "delete"
+	ID    int32         `json:"id"`
+	Entry *JournalEntry `json:"entry,omitempty"`
+}
+
+// Line of the archive.
+type archivedGame struct {
+	ID      int32          `json:"id"`
+	Journal []JournalEntry `json:"journal"`
+}
+
+type FileStore struct {
+	mu       sync.Mutex
+	path     string
+	file     *os.File
+	archive  *os.File        // Opened on the first archived game.
+	sizes    map[int32]int64 // Bytes per live game.
+	deleted  map[int32]bool  // Deleted games with lines in the file.
+	liveSize int64
+	deadSize int64
+	lastID   int32 // Highest ID in the file.
+}
+
+// Opens the file at path, creating it if it doesn't exist.
+func OpenFileStore(path string) (*FileStore, error) {
+	store := &FileStore{
+		path:    path,
+		sizes:   make(map[int32]int64),
+		deleted: make(map[int32]bool),
+	}
+	var err error
+	store.file, err = openLines(path, func(entry logEntry, size int64) error {
+		store.account(entry, size)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if store.deadSize > 0 {
+		if err := store.compact(); err != nil {
+			store.file.Close()
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
+// Rejects lines with an unknown operation.
+func (entry *logEntry) validate() error {
+	if entry.Op != "append" && entry.Op != "delete" {
+		return fmt.Errorf("unknown operation %q", entry.Op)
+	}
+	return nil
+}
+
+// Calls fn for every complete line of the file.
+func (store *FileStore) scan(fn func(entry logEntry, size int64) error) error {
+	_, err := scanLines(store.path, fn)
+	return err
+}
+
+// Updates the sizes with a line of the file.
+func (store *FileStore) account(entry logEntry, size int64) {
+	if entry.ID > store.lastID {
+		store.lastID = entry.ID
+	}
+	if entry.Op == "delete" {
+		store.liveSize -= store.sizes[entry.ID]
+		store.deadSize += store.sizes[entry.ID] + size
+		delete(store.sizes, entry.ID)
+		store.deleted[entry.ID] = true
+		return
+	}
+	store.sizes[entry.ID] += size
+	store.liveSize += size
+}
+
+// Rewrites the file without the lines of deleted games.
+func (store *FileStore) compact() error {
+	tmp := store.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	write := func(entry logEntry) error {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		writer.Write(data)
+		return writer.WriteByte('\n')
+	}
+
+	err = store.scan(func(entry logEntry, size int64) error {
+		if store.deleted[entry.ID] {
+			return nil
+		}
+		return write(entry)
+	})
+	if err == nil && store.deleted[store.lastID] {
+		// Keep the tombstone of the highest ID so it isn't reused.
+		err = write(logEntry{Op: "delete", ID: store.lastID})
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	file.Close()
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, store.path); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(store.path))
+
+	store.file.Close()
+	store.file, err = os.OpenFile(store.path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+	store.deleted = make(map[int32]bool)
+	store.deadSize = 0
+	return nil
+}
+
+// Makes the rename of the compacted file durable.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
+
+func (store *FileStore) write(entries []logEntry) error {
+	var buf []byte
+	sizes := make([]int64, len(entries))
+	for i, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		buf = append(buf, data...)
+		buf = append(buf, '\n')
+		sizes[i] = int64(len(data)) + 1
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if store.file == nil {
+		return os.ErrClosed
+	}
+	if _, err := store.file.Write(buf); err != nil {
+		return err
+	}
+	for i, entry := range entries {
+		store.account(entry, sizes[i])
+	}
+
+	if store.deadSize > minCompactSize && store.deadSize > store.liveSize {
+		return store.compact()
+	}
+	return nil
+}
+
+func (store *FileStore) Append(id int32, entries []JournalEntry) error {
+	lines := make([]logEntry, len(entries))
+	for i := range entries {
+		lines[i] = logEntry{Op: "append", ID: id, Entry: &entries[i]}
+	}
+	return store.write(lines)
+}
+
+func (store *FileStore) Journal(id int32) ([]JournalEntry, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	var journal []JournalEntry
+	err := store.scan(func(entry logEntry, size int64) error {
+		if entry.ID != id {
+			return nil
+		}
+		if entry.Op == "delete" {
+			journal = nil
+		} else {
+			journal = append(journal, *entry.Entry)
+		}
+		return nil
+	})
+	return journal, err
+}
+
+func (store *FileStore) Delete(id int32) error {
+	return store.write([]logEntry{{Op: "delete", ID: id}})
+}
+
+// Returns the path of the archive belonging to the file at path.
+func ArchivePath(path string) string {
+	return path + ".archive"
+}
+
+func (store *FileStore) Archive(id int32, journal []JournalEntry) error {
+	data, err := json.Marshal(archivedGame{ID: id, Journal: journal})
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	store.mu.Lock()
+	if store.file == nil {
+		store.mu.Unlock()
+		return os.ErrClosed
+	}
+	if store.archive == nil {
+		store.archive, err = os.OpenFile(ArchivePath(store.path), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+		if err != nil {
+			store.mu.Unlock()
+			return err
+		}
+	}
+	_, err = store.archive.Write(data)
+	store.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	// Only drop the game once its journal is in the archive.
+	return store.Delete(id)
+}
+
+// Returns the journals of the archived games.
+func (store *FileStore) Archived() (map[int32][]JournalEntry, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	journals := make(map[int32][]JournalEntry)
+	_, err := scanLines(ArchivePath(store.path), func(game archivedGame, size int64) error {
+		journals[game.ID] = game.Journal
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return journals, nil
+}
+
+func (store *FileStore) Load() (map[int32][]JournalEntry, int32, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	journals := make(map[int32][]JournalEntry)
+	err := store.scan(func(entry logEntry, size int64) error {
+		if entry.Op == "delete" {
+			delete(journals, entry.ID)
+		} else {
+			journals[entry.ID] = append(journals[entry.ID], *entry.Entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return journals, store.lastID, nil
+}
+
+func (store *FileStore) Close() error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if store.file == nil {
+		return nil
+	}
+	if store.archive != nil {
+		store.archive.Close()
+		store.archive = nil
+	}
+	err := store.file.Close()
+	store.file = nil
+	return err
+}
@@ -0,0 +1,164 @@
+/*
+Unittest for the file store.
+*/
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Returns a new game with the given moves that wasn't stored yet.
+func newJournaledGame(moves ...string) *Game {
+	game, _ := Replay(0, startedJournal(moves...))
+	return game
+}
+
+func openStoredRepository(t *testing.T, path string) (*FileStore, *MemoryRepository) {
+	store, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("fail in OpenFileStore: %s", err)
+	}
+	repo, err := OpenRepository(store)
+	if err != nil {
+		t.Fatalf("fail in OpenRepository: %s", err)
+	}
+	return store, repo
+}
+
+func TestFileStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "games.log")
+	store, repo := openStoredRepository(t, path)
+
+	game := newJournaledGame("e2 e4", "e7 e5")
+	id := repo.Create(game)
+	deletedGame := newJournaledGame()
+	deleted := repo.Create(deletedGame)
+	game.Mu.Lock()
+	game.Log(JournalEntry{Type: JournalMove, Color: "w", Move: "g1 f3"})
+	repo.Save(game)
+	game.Mu.Unlock()
+	if len(game.Journal) != 0 {
+		t.Errorf("expected persisted entries to be dropped from memory, got %d", len(game.Journal))
+	}
+	repo.Delete(deleted)
+	store.Close()
+
+	store, repo = openStoredRepository(t, path)
+	defer store.Close()
+
+	if _, err := repo.Get(deleted); err != ErrNotFound {
+		t.Errorf("expected the deleted game to stay deleted")
+	}
+	loaded, err := repo.Get(id)
+	if err != nil {
+		t.Fatalf("game %d wasn't reloaded", id)
+	}
+	if !loaded.CheckPassword("secret") || loaded.W_playerToken != "white-token" || loaded.B_playerToken != "black-token" {
+		t.Errorf("credentials weren't restored")
+	}
+	journal, err := repo.Journal(loaded)
+	if err != nil || len(journal) != 7 || loaded.Plies() != 3 {
+		t.Fatalf("expected 7 entries and 3 moves, got %d and %d (%v)", len(journal), loaded.Plies(), err)
+	}
+	if len(loaded.Journal) != 0 || journal[6].Seq != 7 || journal[6].Move != "g1 f3" {
+		t.Errorf("expected the journal to be read back from the store, got %+v", journal[6])
+	}
+	original, _ := game.PositionAt(2)
+	if replayed, _ := loaded.PositionAt(2); replayed != original {
+		t.Errorf("replayed position differs from the original one")
+	}
+	if next := repo.Create(newJournaledGame()); next <= deleted {
+		t.Errorf("expected a new ID after %d, got %d", deleted, next)
+	}
+}
+
+func TestFileStoreCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "games.log")
+	store, repo := openStoredRepository(t, path)
+	for i := 0; i < 10; i++ {
+		game := newJournaledGame("e2 e4")
+		id := repo.Create(game)
+		if i%2 == 1 {
+			repo.Delete(id)
+		}
+	}
+	store.Close()
+	before, _ := os.Stat(path)
+
+	store, repo = openStoredRepository(t, path)
+	defer store.Close()
+	after, _ := os.Stat(path)
+	if after.Size() >= before.Size() {
+		t.Errorf("expected the file to shrink, got %d bytes after %d", after.Size(), before.Size())
+	}
+	if games := repo.List(); len(games) != 5 {
+		t.Errorf("expected 5 games, got %d", len(games))
+	}
+	game := newJournaledGame()
+	if id := repo.Create(game); id != 11 {
+		t.Errorf("expected ID 11, got %d", id)
+	}
+}
+
+func TestFileStoreTruncatedLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "games.log")
+	store, repo := openStoredRepository(t, path)
+	game := newJournaledGame("e2 e4")
+	repo.Create(game)
+	store.Close()
+
+	// Simulate a crash in the middle of a write.
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	file.WriteString(`{"op":"append","id":1,"entry":{"seq":6,"ty`)
+	file.Close()
+
+	store, repo = openStoredRepository(t, path)
+	defer store.Close()
+	loaded, err := repo.Get(1)
+	if err != nil || loaded.Plies() != 1 {
+		t.Fatalf("expected the complete entries to be loaded")
+	}
+	loaded.Mu.Lock()
+	loaded.Log(JournalEntry{Type: JournalMove, Color: "b", Move: "e7 e5"})
+	repo.Save(loaded)
+	journal, err := repo.Journal(loaded)
+	loaded.Mu.Unlock()
+	if err != nil || len(journal) != 6 || journal[5].Seq != 6 {
+		t.Errorf("expected 6 entries after appending, got %d (%v)", len(journal), err)
+	}
+}
+
+func TestFileStoreInvalidLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "games.log")
+	os.WriteFile(path, []byte(`{"op":"append","id":1,"entry":{"seq":1,"type":"created"}}`+"\n"+`{"op":"move","id":1}`+"\n"), 0o600)
+	_, err := OpenFileStore(path)
+	if err == nil || !strings.Contains(err.Error(), "games.log:2: unknown operation") {
+		t.Errorf("expected the second line to be rejected, got %v", err)
+	}
+}
+
+func TestFileStoreArchive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "games.log")
+	store, repo := openStoredRepository(t, path)
+	id := repo.Create(newJournaledGame("e2 e4"))
+	if _, err := repo.Archive(id); err != nil {
+		t.Fatalf("fail in Archive: %s", err)
+	}
+	store.Close()
+
+	store, repo = openStoredRepository(t, path)
+	defer store.Close()
+	if _, err := repo.Get(id); err != ErrNotFound {
+		t.Errorf("expected the archived game to be removed")
+	}
+	archived, err := store.Archived()
+	if err != nil {
+		t.Fatalf("fail in Archived: %s", err)
+	}
+	if len(archived[id]) != 5 {
+		t.Errorf("expected the 5 entries of game %d in the archive, got %d", id, len(archived[id]))
+	}
+}
@@ -0,0 +1,77 @@
+/*
+Positions of a game. Only the current position and a checkpoint
+every CheckpointInterval plies are kept, other positions are rebuilt
+from the moves when they are requested.
+*/
+package database
+
+import (
+	"fmt"
+
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/game_logic"
+)
+
+// Number of plies between two checkpoints.
+const CheckpointInterval = 16
+
+// Sets the game to the initial position without moves.
+func (game *Game) ResetBoard() {
+	var states []game_logic.BoardState
+	game_logic.InitializeBoard(&states)
+	game.position = states[0]
+	game.checkpoints = states[:1]
+	game.halfmoves = 0
+	game.Moves = nil
+}
+
+// Returns the current position. Changes to it stay with the game.
+func (game *Game) Position() *game_logic.BoardState {
+	return &game.position
+}
+
+// Returns the number of moves made so far.
+func (game *Game) Plies() int {
+	return len(game.Moves)
+}
+
+// Makes a validated move and records it.
+func (game *Game) PushMove(move game_logic.Move, record MoveRecord) {
+	from, to := move.From(), move.To()
+	piece := game.position.Board[from[0]][from[1]]
+	if piece == 'p' || piece == 'P' || game.position.Board[to[0]][to[1]] != game_logic.Empty {
+		game.halfmoves = 0
+	} else {
+		game.halfmoves++
+	}
+	game.position = game_logic.MakeMove(&move, game.position)
+	game.Moves = append(game.Moves, record)
+	if len(game.Moves)%CheckpointInterval == 0 {
+		game.checkpoints = append(game.checkpoints, game.position)
+	}
+}
+
+// Returns the current position in FEN.
+func (game *Game) FEN() string {
+	return game_logic.FEN(&game.position, game.halfmoves, len(game.Moves)/2+1)
+}
+
+// Returns the position after idx moves.
+func (game *Game) PositionAt(idx int) (game_logic.BoardState, error) {
+	if idx < 0 || idx > len(game.Moves) {
+		return game_logic.BoardState{}, fmt.Errorf("move index %d out of range [0, %d]", idx, len(game.Moves))
+	}
+	if idx == len(game.Moves) {
+		return game.position, nil
+	}
+
+	from := idx / CheckpointInterval * CheckpointInterval
+	bstate := game.checkpoints[from/CheckpointInterval]
+	for _, record := range game.Moves[from:idx] {
+		move, err := game_logic.StringToMoveStruct(record.Move, rune(record.Color[0]))
+		if err != nil {
+			return game_logic.BoardState{}, err
+		}
+		bstate = game_logic.MakeMove(&move, bstate)
+	}
+	return bstate, nil
+}
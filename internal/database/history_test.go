@@ -0,0 +1,75 @@
+/*
+Unittest for rebuilding positions from checkpoints.
+*/
+package database
+
+import (
+	"testing"
+
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/game_logic"
+)
+
+// Knights going back and forth, long enough to pass several checkpoints.
+func knightShuffle(plies int) []string {
+	cycle := []string{"g1 f3", "g8 f6", "f3 g1", "f6 g8"}
+	moves := make([]string, plies)
+	for i := range moves {
+		moves[i] = cycle[i%len(cycle)]
+	}
+	return moves
+}
+
+func TestPositionAt(t *testing.T) {
+	moves := knightShuffle(2*CheckpointInterval + 5)
+	game := &Game{}
+	game.ResetBoard()
+
+	var states []game_logic.BoardState
+	game_logic.InitializeBoard(&states)
+	colors := []string{"w", "b"}
+	for i, moveStr := range moves {
+		move, err := game_logic.StringToMoveStruct(moveStr, rune(colors[i%2][0]))
+		if err != nil {
+			t.Fatalf("fail in StringToMoveStruct: %s", err)
+		}
+		states = append(states, game_logic.MakeMove(&move, states[len(states)-1]))
+		game.PushMove(move, MoveRecord{Color: colors[i%2], Move: moveStr})
+	}
+
+	if len(game.checkpoints) != 3 {
+		t.Errorf("expected 3 checkpoints, got %d", len(game.checkpoints))
+	}
+	for idx, expected := range states {
+		bstate, err := game.PositionAt(idx)
+		if err != nil {
+			t.Fatalf("fail in PositionAt(%d): %s", idx, err)
+		}
+		if bstate != expected {
+			t.Errorf("position after %d moves differs", idx)
+		}
+	}
+	for _, idx := range []int{-1, len(moves) + 1} {
+		if _, err := game.PositionAt(idx); err == nil {
+			t.Errorf("expected an error for index %d", idx)
+		}
+	}
+}
+
+func TestFEN(t *testing.T) {
+	game := &Game{}
+	game.ResetBoard()
+	colors := []string{"w", "b"}
+	for i, moveStr := range append(knightShuffle(5), "e7 e5") {
+		move, _ := game_logic.StringToMoveStruct(moveStr, rune(colors[i%2][0]))
+		game.PushMove(move, MoveRecord{Color: colors[i%2], Move: moveStr})
+		if i == 4 {
+			// Five knight moves count towards the fifty-move rule.
+			if fen := game.FEN(); fen != "rnbqkbnr/pppppppp/8/8/8/5N2/PPPPPPPP/RNBQKB1R b - - 5 3" {
+				t.Errorf("unexpected FEN %s", fen)
+			}
+		}
+	}
+	if fen := game.FEN(); fen != "rnbqkbnr/pppp1ppp/8/4p3/8/5N2/PPPPPPPP/RNBQKB1R w - e6 0 4" {
+		t.Errorf("expected the pawn move to reset the clock, got %s", fen)
+	}
+}
@@ -0,0 +1,208 @@
+/*
+Journal of a game. Everything that happens to a game is appended to
+its journal, the rest of the game state can be rebuilt from it by
+replaying the entries through game_logic. Checkmate and stalemate
+aren't journaled but derived from the moves, so results follow fixed
+rules when a journal is replayed again.
+*/
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/game_logic"
+)
+
+// Types of journal entries.
+const (
+	JournalCreated     = "created"
+	JournalJoined      = "joined"
+	JournalStarted     = "started"
+	JournalMove        = "move"
+	JournalDrawOffer   = "drawoffer"
+	JournalDrawDecline = "drawdecline"
+	JournalDrawAccept  = "drawaccept"
+	JournalForfeit     = "forfeit"
+	JournalTimeout     = "timeout"     // Flag fall or exceeded move time.
+	JournalAdjudicated = "adjudicated" // Decided by the tablebases.
+	JournalAborted     = "aborted"     // Ended without result, e.g. after inactivity.
+)
+
+type JournalEntry struct {
+	Seq   int       `json:"seq"`
+	Time  time.Time `json:"time"`
+	Type  string    `json:"type"`
+	Color string    `json:"color,omitempty"`
+
+	// Created
+	Name         string        `json:"name,omitempty"`
+	PasswordHash string        `json:"passwordhash,omitempty"`
+	Password     string        `json:"password,omitempty"` // Plain password of journals written before hashing.
+	TimeControl  *TimeControl  `json:"timecontrol,omitempty"`
+	MoveTime     time.Duration `json:"movetime,omitempty"`
+	MoveTimeout  string        `json:"movetimeout,omitempty"`
+
+	// Joined
+	Token    string `json:"token,omitempty"`
+	Account  int32  `json:"account,omitempty"` // 0 if anonymous.
+	Callback string `json:"callback,omitempty"`
+
+	// Move
+	Move      string        `json:"move,omitempty"`
+	ThinkTime time.Duration `json:"thinktime,omitempty"`
+	Fallback  bool          `json:"fallback,omitempty"`
+	Opening   bool          `json:"opening,omitempty"`
+
+	// Clocks after the entry, set for games with a time control.
+	W_timeLeft time.Duration `json:"wtimeleft,omitempty"`
+	B_timeLeft time.Duration `json:"btimeleft,omitempty"`
+
+	// End of the game
+	Winner string `json:"winner,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Appends an entry to the journal. Called with game.Mu held.
+func (game *Game) Log(entry JournalEntry) {
+	game.journaled++
+	entry.Seq = game.journaled
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	game.Journal = append(game.Journal, entry)
+}
+
+// Returns the entries that weren't persisted yet and drops them, the
+// store keeps them from now on. Called with game.Mu held.
+func (game *Game) unsaved() []JournalEntry {
+	entries := game.Journal
+	game.Journal = nil
+	return entries
+}
+
+// Rebuilds a game by replaying its journal. The entries stay in
+// Journal until they are persisted.
+func Replay(id int32, entries []JournalEntry) (*Game, error) {
+	if len(entries) == 0 || entries[0].Type != JournalCreated {
+		return nil, fmt.Errorf("game %d: journal doesn't start with %q", id, JournalCreated)
+	}
+	game := &Game{ID: id}
+	for _, entry := range entries {
+		if err := game.replayEntry(entry); err != nil {
+			return nil, fmt.Errorf("game %d: entry %d (%s): %v", id, entry.Seq, entry.Type, err)
+		}
+	}
+	game.Journal = entries
+	game.journaled = len(entries)
+	return game, nil
+}
+
+func (game *Game) replayEntry(entry JournalEntry) error {
+	if game.Over() {
+		return errors.New("game has already ended")
+	}
+
+	switch entry.Type {
+	case JournalCreated:
+		game.Name = entry.Name
+		game.PasswordHash = entry.PasswordHash
+		if game.PasswordHash == "" {
+			game.PasswordHash = HashSecret(entry.Password)
+		}
+		game.TimeControl = entry.TimeControl
+		game.MoveTime = entry.MoveTime
+		game.MoveTimeout = entry.MoveTimeout
+		game.Winner = "n"
+		game.State = StateWaiting
+		game.CreatedAt = entry.Time
+		game.ResetBoard()
+
+	case JournalJoined:
+		switch entry.Color {
+		case "w":
+			game.HasWPlayer = true
+			game.W_playerToken = entry.Token
+			game.W_account = entry.Account
+			game.W_callback = entry.Callback
+		case "b":
+			game.HasBPlayer = true
+			game.B_playerToken = entry.Token
+			game.B_account = entry.Account
+			game.B_callback = entry.Callback
+		default:
+			return fmt.Errorf("invalid color %q", entry.Color)
+		}
+
+	case JournalStarted:
+		game.Started = true
+		game.State = StateActive
+		game.StartedAt = entry.Time
+		game.LastMoveAt = entry.Time
+		game.TurnStart = entry.Time
+		game.setClocks(entry)
+
+	case JournalMove:
+		bstate := game.position
+		if !game.Started || bstate.TurnColor != entry.Color {
+			return errors.New("not the turn of " + entry.Color)
+		}
+		move, err := game_logic.StringToMoveStruct(entry.Move, rune(entry.Color[0]))
+		if err != nil {
+			return err
+		}
+		if err := game_logic.ValidateMove(&move, &bstate); err != nil {
+			return err
+		}
+		game.PushMove(move, MoveRecord{
+			Color:     entry.Color,
+			Move:      entry.Move,
+			ThinkTime: entry.ThinkTime,
+			Fallback:  entry.Fallback,
+			Opening:   entry.Opening,
+		})
+		game.TurnStart = entry.Time
+		game.LastMoveAt = entry.Time
+		game.setClocks(entry)
+		if game.DrawOffer == opponent(entry.Color) {
+			game.DrawOffer = ""
+		}
+		winner, reason := game_logic.GameEnd(&game.position)
+		if winner != "n" {
+			game.Finish(winner, reason, entry.Time)
+		}
+
+	case JournalDrawOffer:
+		game.DrawOffer = entry.Color
+
+	case JournalDrawDecline:
+		game.DrawOffer = ""
+
+	case JournalForfeit, JournalTimeout, JournalDrawAccept, JournalAdjudicated:
+		game.setClocks(entry)
+		game.Finish(entry.Winner, entry.Reason, entry.Time)
+
+	case JournalAborted:
+		game.setClocks(entry)
+		game.Abort(entry.Reason, entry.Time)
+
+	default:
+		return fmt.Errorf("unknown entry type %q", entry.Type)
+	}
+	return nil
+}
+
+func (game *Game) setClocks(entry JournalEntry) {
+	if game.TimeControl != nil {
+		game.W_timeLeft = entry.W_timeLeft
+		game.B_timeLeft = entry.B_timeLeft
+	}
+}
+
+func opponent(color string) string {
+	if color == "w" {
+		return "b"
+	}
+	return "w"
+}
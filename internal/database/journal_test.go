@@ -0,0 +1,120 @@
+/*
+Unittest for replaying journals.
+*/
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+// Journal of a started game followed by the given moves.
+func startedJournal(moves ...string) []JournalEntry {
+	game := &Game{}
+	game.Log(JournalEntry{Type: JournalCreated, Name: "journal", PasswordHash: HashSecret("secret")})
+	game.Log(JournalEntry{Type: JournalJoined, Color: "w", Token: "white-token"})
+	game.Log(JournalEntry{Type: JournalJoined, Color: "b", Token: "black-token"})
+	game.Log(JournalEntry{Type: JournalStarted})
+	colors := []string{"w", "b"}
+	for i, move := range moves {
+		game.Log(JournalEntry{Type: JournalMove, Color: colors[i%2], Move: move, ThinkTime: time.Second})
+	}
+	return game.Journal
+}
+
+func TestReplayDerivesCheckmate(t *testing.T) {
+	game, err := Replay(1, startedJournal("f2 f3", "e7 e5", "g2 g4", "d8 h4"))
+	if err != nil {
+		t.Fatalf("fail in Replay: %s", err)
+	}
+	if game.Winner != "b" || game.Termination != "checkmate" {
+		t.Errorf("expected black to win by checkmate, got %q %q", game.Winner, game.Termination)
+	}
+	if game.Plies() != 4 {
+		t.Errorf("expected 4 moves, got %d", game.Plies())
+	}
+	if last := game.Position(); last.TurnColor != "n" || last.Winner != "b" {
+		t.Errorf("expected the final state to be decided, got %q %q", last.TurnColor, last.Winner)
+	}
+	if game.W_playerToken != "white-token" || game.B_playerToken != "black-token" || !game.CheckPassword("secret") {
+		t.Errorf("credentials weren't restored")
+	}
+}
+
+func TestReplayLegacyPassword(t *testing.T) {
+	journal := startedJournal()
+	journal[0].PasswordHash = ""
+	journal[0].Password = "secret"
+	game, err := Replay(1, journal)
+	if err != nil {
+		t.Fatalf("fail in Replay: %s", err)
+	}
+	if !game.CheckPassword("secret") || game.CheckPassword("") {
+		t.Errorf("expected the plain password to be hashed")
+	}
+}
+
+func TestReplayEvents(t *testing.T) {
+	journal := startedJournal("e2 e4")
+	journal = append(journal,
+		JournalEntry{Seq: 6, Type: JournalDrawOffer, Color: "b"},
+		JournalEntry{Seq: 7, Type: JournalMove, Color: "b", Move: "e7 e5"},
+	)
+	game, err := Replay(1, journal)
+	if err != nil {
+		t.Fatalf("fail in Replay: %s", err)
+	}
+	if game.DrawOffer != "b" {
+		t.Errorf("expected the offer of black to stand after its own move")
+	}
+
+	journal = append(journal, JournalEntry{Seq: 8, Type: JournalForfeit, Color: "w", Winner: "b", Reason: "forfeit"})
+	game, err = Replay(1, journal)
+	if err != nil {
+		t.Fatalf("fail in Replay: %s", err)
+	}
+	if game.Winner != "b" || game.Termination != "forfeit" || game.DrawOffer != "" {
+		t.Errorf("expected black to win by forfeit, got %q %q", game.Winner, game.Termination)
+	}
+}
+
+func TestReplayRejectsInvalidJournals(t *testing.T) {
+	journals := map[string][]JournalEntry{
+		"illegal move":    startedJournal("e2 e5"),
+		"wrong turn":      startedJournal("e2 e4", "d2 d4"),
+		"no created":      startedJournal()[1:],
+		"after game over": startedJournal("f2 f3", "e7 e5", "g2 g4", "d8 h4", "a2 a3"),
+	}
+	for name, journal := range journals {
+		if _, err := Replay(1, journal); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestReplayLifecycle(t *testing.T) {
+	journal := startedJournal()
+	game, err := Replay(1, journal[:3])
+	if err != nil {
+		t.Fatalf("fail in Replay: %s", err)
+	}
+	if game.State != StateWaiting || game.CreatedAt != journal[0].Time {
+		t.Errorf("expected a waiting game created at %v, got %q %v", journal[0].Time, game.State, game.CreatedAt)
+	}
+
+	aborted := journal[3].Time.Add(time.Hour)
+	journal = append(journal, JournalEntry{Seq: 5, Time: aborted, Type: JournalAborted, Reason: "inactivity"})
+	game, err = Replay(1, journal)
+	if err != nil {
+		t.Fatalf("fail in Replay: %s", err)
+	}
+	if game.State != StateAborted || !game.Over() || game.Winner != "n" {
+		t.Errorf("expected an aborted game without winner, got %q %q", game.State, game.Winner)
+	}
+	if game.StartedAt != journal[3].Time || game.EndedAt != aborted {
+		t.Errorf("timestamps weren't restored")
+	}
+	if game.Position().TurnColor != "n" {
+		t.Errorf("expected nobody to move in an aborted game")
+	}
+}
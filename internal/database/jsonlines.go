@@ -0,0 +1,71 @@
+/*
+Reading and opening the append-only files of JSON lines the stores
+keep their data in.
+*/
+package database
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Implemented by lines that can be decoded but still be invalid.
+type validator interface {
+	validate() error
+}
+
+// Calls fn for every complete line of the file at path with the
+// decoded line and its size. Returns the length of the valid part of
+// the file, 0 if the file doesn't exist.
+func scanLines[E any](path string, fn func(entry E, size int64) error) (int64, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return offset, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		var entry E
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return 0, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		if v, ok := any(&entry).(validator); ok {
+			if err := v.validate(); err != nil {
+				return 0, fmt.Errorf("%s:%d: %v", path, line, err)
+			}
+		}
+		if err := fn(entry, int64(len(data))); err != nil {
+			return 0, err
+		}
+		offset += int64(len(data))
+	}
+}
+
+// Scans the file at path like scanLines and opens it for appending,
+// creating it if it doesn't exist.
+func openLines[E any](path string, fn func(entry E, size int64) error) (*os.File, error) {
+	valid, err := scanLines(path, fn)
+	if err != nil {
+		return nil, err
+	}
+	// A crash can leave the last line incomplete.
+	if err := os.Truncate(path, valid); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+}
@@ -0,0 +1,41 @@
+/*
+Lifecycle of a game. A game waits for its players, is active once
+both have joined and ends either finished with a result or aborted
+without one.
+*/
+package database
+
+import "time"
+
+// States of a game.
+const (
+	StateWaiting  = "waiting"
+	StateActive   = "active"
+	StateFinished = "finished"
+	StateAborted  = "aborted"
+)
+
+// Reports whether the game has ended, with or without a result.
+func (game *Game) Over() bool {
+	return game.State == StateFinished || game.State == StateAborted
+}
+
+// Ends the game with the given winner ('w', 'b' or 'r') and reason.
+func (game *Game) Finish(winner string, reason string, at time.Time) {
+	game.position.TurnColor = "n"
+	game.position.Winner = winner
+	game.Winner = winner
+	game.Termination = reason
+	game.DrawOffer = ""
+	game.State = StateFinished
+	game.EndedAt = at
+}
+
+// Ends the game without a result.
+func (game *Game) Abort(reason string, at time.Time) {
+	game.position.TurnColor = "n"
+	game.Termination = reason
+	game.DrawOffer = ""
+	game.State = StateAborted
+	game.EndedAt = at
+}
@@ -0,0 +1,79 @@
+/*
+Store keeping a journal in an append-only file of JSON lines, one
+line per entry. Used for the journals of accounts and tournaments,
+which are never deleted, so the file doesn't need compaction.
+*/
+package database
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+type FileLineStore[E any] struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+type FileAccountStore = FileLineStore[AccountEntry]
+
+// Returns the path of the account journal belonging to the game
+// file at path.
+func AccountsPath(path string) string {
+	return path + ".accounts"
+}
+
+// Opens the account journal at path, creating it if it doesn't
+// exist.
+func OpenFileAccountStore(path string) (*FileAccountStore, error) {
+	return openFileLineStore[AccountEntry](path)
+}
+
+// Opens the file at path, creating it if it doesn't exist.
+func openFileLineStore[E any](path string) (*FileLineStore[E], error) {
+	file, err := openLines(path, func(entry E, size int64) error { return nil })
+	if err != nil {
+		return nil, err
+	}
+	return &FileLineStore[E]{path: path, file: file}, nil
+}
+
+func (store *FileLineStore[E]) Append(entry E) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if store.file == nil {
+		return os.ErrClosed
+	}
+	_, err = store.file.Write(data)
+	return err
+}
+
+func (store *FileLineStore[E]) Load() ([]E, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	var entries []E
+	_, err := scanLines(store.path, func(entry E, size int64) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	return entries, err
+}
+
+func (store *FileLineStore[E]) Close() error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if store.file == nil {
+		return nil
+	}
+	err := store.file.Close()
+	store.file = nil
+	return err
+}
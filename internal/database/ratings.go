@@ -0,0 +1,116 @@
+/*
+Ratings of accounts, kept per time control category. Every rated game
+is recorded in the journals of both accounts together with the rating
+after it, so the history can be shown as a graph.
+*/
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/rating"
+)
+
+var ErrAlreadyRated = errors.New("game was already rated")
+
+// Rated game of an account.
+type RatingRecord struct {
+	Time     time.Time     `json:"time"`
+	GameID   int32         `json:"gameid"`
+	Category string        `json:"category"`
+	Opponent int32         `json:"opponent"`
+	Score    float64       `json:"score"`  // 1 for a win, 0.5 for a draw, 0 for a loss.
+	Rating   rating.Rating `json:"rating"` // After the game.
+}
+
+// Game between two accounts to be rated.
+type RatedGame struct {
+	GameID   int32
+	Category string
+	White    int32
+	Black    int32
+	Score    float64 // Score of white.
+	Time     time.Time
+}
+
+// Returns the rating of the account in a category, the initial one
+// if it has no rated games in it.
+func (account *Account) Rating(category string) rating.Rating {
+	if r, exists := account.Ratings[category]; exists {
+		return r
+	}
+	return rating.New()
+}
+
+// Updates the ratings of both players with the result of a game.
+// Each game is rated once.
+func (repo *AccountRepository) RateGame(game RatedGame, config rating.Config) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	white, exists := repo.accounts[game.White]
+	if !exists {
+		return ErrAccountMissing
+	}
+	black, exists := repo.accounts[game.Black]
+	if !exists {
+		return ErrAccountMissing
+	}
+
+	newWhite, newBlack := config.Rate(white.Rating(game.Category), black.Rating(game.Category), game.Score)
+	entries := []AccountEntry{
+		{Time: game.Time, Type: AccountRated, Account: game.White, Record: &RatingRecord{
+			Time:     game.Time,
+			GameID:   game.GameID,
+			Category: game.Category,
+			Opponent: game.Black,
+			Score:    game.Score,
+			Rating:   newWhite,
+		}},
+		{Time: game.Time, Type: AccountRated, Account: game.Black, Record: &RatingRecord{
+			Time:     game.Time,
+			GameID:   game.GameID,
+			Category: game.Category,
+			Opponent: game.White,
+			Score:    1 - game.Score,
+			Rating:   newBlack,
+		}},
+	}
+	// Check both before writing either.
+	for _, entry := range entries {
+		if err := repo.check(entry); err != nil {
+			return err
+		}
+	}
+	for _, entry := range entries {
+		if err := repo.commit(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (repo *AccountRepository) checkRated(entry AccountEntry) error {
+	account, exists := repo.accounts[entry.Account]
+	if !exists {
+		return ErrAccountMissing
+	}
+	if entry.Record == nil || !rating.ValidCategory(entry.Record.Category) {
+		return errors.New("invalid rating record")
+	}
+	for _, record := range account.History {
+		if record.GameID == entry.Record.GameID {
+			return ErrAlreadyRated
+		}
+	}
+	return nil
+}
+
+func (repo *AccountRepository) applyRated(entry AccountEntry) {
+	account := repo.accounts[entry.Account]
+	if account.Ratings == nil {
+		account.Ratings = make(map[string]rating.Rating)
+	}
+	account.Ratings[entry.Record.Category] = entry.Record.Rating
+	account.History = append(account.History, *entry.Record)
+}
@@ -0,0 +1,187 @@
+/*
+Storage of the games. Handlers access games only through a
+Repository so lookups, ID assignment and deletion are synchronised.
+With a Store, every change is written through to it.
+*/
+package database
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"sync"
+)
+
+var ErrNotFound = errors.New("game not found")
+
+type Repository interface {
+	// Assigns a new ID to the game and stores it.
+	Create(game *Game) int32
+	// Returns the game with the given ID.
+	Get(id int32) (*Game, error)
+	// Returns all games ordered by ID.
+	List() []*Game
+	// Removes the game and marks it as deleted.
+	Delete(id int32) (*Game, error)
+	// Removes an ended game like Delete, but keeps its journal in
+	// the archive of the store.
+	Archive(id int32) (*Game, error)
+	// Persists the new journal entries of a game. Called with
+	// game.Mu held after every change.
+	Save(game *Game) error
+	// Returns the complete journal of a game, reading the persisted
+	// entries back from the store. Called with game.Mu held.
+	Journal(game *Game) ([]JournalEntry, error)
+}
+
+// Repository keeping the games in memory, optionally backed by a
+// Store.
+type MemoryRepository struct {
+	mu     sync.RWMutex
+	games  map[int32]*Game
+	nextID int32
+	store  Store // nil if games aren't persisted.
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		games:  make(map[int32]*Game),
+		nextID: 1,
+	}
+}
+
+// Returns a repository backed by store, filled with the games
+// stored in it.
+func OpenRepository(store Store) (*MemoryRepository, error) {
+	journals, lastID, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	repo := NewMemoryRepository()
+	repo.store = store
+	repo.nextID = lastID + 1
+	for id, journal := range journals {
+		game, err := Replay(id, journal)
+		if err != nil {
+			// Keep the journal so the game can be recovered.
+			log.Printf("Failed to replay stored game: %v", err)
+			continue
+		}
+		// The entries are in the store already.
+		game.unsaved()
+		repo.games[id] = game
+	}
+	return repo, nil
+}
+
+func (repo *MemoryRepository) Create(game *Game) int32 {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	game.ID = repo.nextID
+	repo.nextID++
+	repo.games[game.ID] = game
+	if err := repo.Save(game); err != nil {
+		log.Printf("Failed to store game %d: %v", game.ID, err)
+	}
+	return game.ID
+}
+
+func (repo *MemoryRepository) Get(id int32) (*Game, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	game, exists := repo.games[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return game, nil
+}
+
+func (repo *MemoryRepository) List() []*Game {
+	repo.mu.RLock()
+	games := make([]*Game, 0, len(repo.games))
+	for _, game := range repo.games {
+		games = append(games, game)
+	}
+	repo.mu.RUnlock()
+
+	sort.Slice(games, func(i, j int) bool {
+		return games[i].ID < games[j].ID
+	})
+	return games
+}
+
+func (repo *MemoryRepository) Delete(id int32) (*Game, error) {
+	game, err := repo.remove(id)
+	if err != nil {
+		return nil, err
+	}
+	if repo.store != nil {
+		if err := repo.store.Delete(id); err != nil {
+			log.Printf("Failed to delete stored game %d: %v", id, err)
+		}
+	}
+	return game, nil
+}
+
+func (repo *MemoryRepository) Archive(id int32) (*Game, error) {
+	game, err := repo.remove(id)
+	if err != nil {
+		return nil, err
+	}
+	if repo.store != nil {
+		game.Mu.RLock()
+		journal, err := repo.Journal(game)
+		game.Mu.RUnlock()
+		if err == nil {
+			err = repo.store.Archive(id, journal)
+		}
+		if err != nil {
+			log.Printf("Failed to archive stored game %d: %v", id, err)
+		}
+	}
+	return game, nil
+}
+
+// Takes a game out of the repository and marks it as deleted.
+func (repo *MemoryRepository) remove(id int32) (*Game, error) {
+	repo.mu.Lock()
+	game, exists := repo.games[id]
+	if !exists {
+		repo.mu.Unlock()
+		return nil, ErrNotFound
+	}
+	delete(repo.games, id)
+	repo.mu.Unlock()
+
+	game.Mu.Lock()
+	game.Deleted = true
+	game.Mu.Unlock()
+	return game, nil
+}
+
+func (repo *MemoryRepository) Save(game *Game) error {
+	if repo.store == nil || game.Deleted {
+		return nil
+	}
+	entries := game.unsaved()
+	if len(entries) == 0 {
+		return nil
+	}
+	if err := repo.store.Append(game.ID, entries); err != nil {
+		// Keep them for the next try.
+		game.Journal = append(entries, game.Journal...)
+		return err
+	}
+	return nil
+}
+
+func (repo *MemoryRepository) Journal(game *Game) ([]JournalEntry, error) {
+	if repo.store == nil {
+		return append([]JournalEntry(nil), game.Journal...), nil
+	}
+	journal, err := repo.store.Journal(game.ID)
+	if err != nil {
+		return nil, err
+	}
+	return append(journal, game.Journal...), nil
+}
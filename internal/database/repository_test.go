@@ -0,0 +1,63 @@
+/*
+Unittest for the game repository. Run with -race.
+*/
+package database
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestCreateAssignsUniqueIDs(t *testing.T) {
+	repo := NewMemoryRepository()
+
+	const n = 200
+	var wg sync.WaitGroup
+	ids := make([]int32, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ids[i] = repo.Create(&Game{})
+			repo.List()
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[int32]bool)
+	for _, id := range ids {
+		if seen[id] {
+			t.Fatalf("ID %d was assigned twice", id)
+		}
+		seen[id] = true
+	}
+	games := repo.List()
+	if len(games) != n {
+		t.Fatalf("expected %d games, got %d", n, len(games))
+	}
+	for i := 1; i < len(games); i++ {
+		if games[i-1].ID >= games[i].ID {
+			t.Errorf("List is not ordered by ID")
+		}
+	}
+}
+
+func TestDelete(t *testing.T) {
+	repo := NewMemoryRepository()
+	id := repo.Create(&Game{})
+	game, _ := repo.Get(id)
+
+	if _, err := repo.Delete(id); err != nil {
+		t.Fatalf("fail in Delete: %s", err)
+	}
+	if !game.Deleted {
+		t.Errorf("expected the game to be marked as deleted")
+	}
+	if _, err := repo.Get(id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound from Get, got %v", err)
+	}
+	if _, err := repo.Delete(id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound from Delete, got %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+/*
+Results of finished games played by accounts. The index keeps a small
+summary of every such game, also after the game was archived, so
+statistics and leaderboards don't depend on the games staying in
+memory.
+*/
+package database
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/rating"
+)
+
+type GameResult struct {
+	GameID      int32
+	White       int32 // Accounts, 0 if anonymous.
+	Black       int32
+	Winner      string // 'w', 'b' or 'r'.
+	Termination string
+	Category    string
+	Plies       int
+	WhiteMoves  int // Moves of the player, without the opening.
+	BlackMoves  int
+	WhiteThink  time.Duration // Time spent on all moves.
+	BlackThink  time.Duration
+	StartedAt   time.Time
+	EndedAt     time.Time
+}
+
+// Returns the score of an account in the game, 1 for a win, 0.5
+// for a draw and 0 for a loss.
+func (result GameResult) Score(account int32) float64 {
+	switch {
+	case result.Winner == "r":
+		return 0.5
+	case result.Winner == "w" && result.White == account,
+		result.Winner == "b" && result.Black == account:
+		return 1
+	}
+	return 0
+}
+
+// Returns the summary of a game, false if the game isn't finished
+// or has no account among its players. Called with game.Mu held.
+func ResultOf(game *Game) (GameResult, bool) {
+	if game.State != StateFinished || (game.W_account == 0 && game.B_account == 0) {
+		return GameResult{}, false
+	}
+	result := GameResult{
+		GameID:      game.ID,
+		White:       game.W_account,
+		Black:       game.B_account,
+		Winner:      game.Winner,
+		Termination: game.Termination,
+		Category:    game.Category(),
+		Plies:       game.Plies(),
+		StartedAt:   game.StartedAt,
+		EndedAt:     game.EndedAt,
+	}
+	for _, record := range game.Moves {
+		if record.Opening {
+			continue
+		}
+		if record.Color == "w" {
+			result.WhiteMoves++
+			result.WhiteThink += record.ThinkTime
+		} else {
+			result.BlackMoves++
+			result.BlackThink += record.ThinkTime
+		}
+	}
+	return result, true
+}
+
+// Returns the rating category of the time limits of a game.
+func (game *Game) Category() string {
+	if game.TimeControl != nil {
+		tc := game.TimeControl
+		return rating.Category(tc.Base, tc.Increment+tc.Delay)
+	}
+	// A move time is like an increment without base time.
+	return rating.Category(0, game.MoveTime)
+}
+
+type ResultIndex struct {
+	mu      sync.RWMutex
+	results map[int32]GameResult
+}
+
+func NewResultIndex() *ResultIndex {
+	return &ResultIndex{results: make(map[int32]GameResult)}
+}
+
+// Adds the result of a game, replacing an earlier one of the same
+// game.
+func (index *ResultIndex) Add(result GameResult) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	index.results[result.GameID] = result
+}
+
+func (index *ResultIndex) Get(gameID int32) (GameResult, bool) {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+	result, exists := index.results[gameID]
+	return result, exists
+}
+
+// Adds the results of all finished games among games.
+func (index *ResultIndex) AddGames(games []*Game) {
+	for _, game := range games {
+		game.Mu.RLock()
+		result, finished := ResultOf(game)
+		game.Mu.RUnlock()
+		if finished {
+			index.Add(result)
+		}
+	}
+}
+
+// Returns all results ordered by game ID.
+func (index *ResultIndex) List() []GameResult {
+	index.mu.RLock()
+	results := make([]GameResult, 0, len(index.results))
+	for _, result := range index.results {
+		results = append(results, result)
+	}
+	index.mu.RUnlock()
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].GameID < results[j].GameID
+	})
+	return results
+}
+
+// Adds the results of archived games from their journals. Journals
+// that can't be replayed are skipped.
+func (index *ResultIndex) AddArchived(journals map[int32][]JournalEntry) {
+	for id, journal := range journals {
+		game, err := Replay(id, journal)
+		if err != nil {
+			continue
+		}
+		if result, finished := ResultOf(game); finished {
+			index.Add(result)
+		}
+	}
+}
+
+// Results of the server.
+var Results = NewResultIndex()
@@ -0,0 +1,38 @@
+/*
+Unittest for summarizing finished games.
+*/
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResultOfSkipsOpening(t *testing.T) {
+	game := &Game{
+		ID:        1,
+		State:     StateFinished,
+		Winner:    "w",
+		W_account: 1,
+		B_account: 2,
+		Moves: []MoveRecord{
+			{Color: "w", Move: "e2 e4", Opening: true},
+			{Color: "b", Move: "e7 e5", Opening: true},
+			{Color: "w", Move: "g1 f3", ThinkTime: 3 * time.Second},
+			{Color: "b", Move: "b8 c6", ThinkTime: 2 * time.Second, Fallback: true},
+		},
+	}
+	result, ok := ResultOf(game)
+	if !ok {
+		t.Fatalf("expected a result")
+	}
+	if result.Plies != 4 {
+		t.Errorf("expected 4 plies, got %d", result.Plies)
+	}
+	if result.WhiteMoves != 1 || result.WhiteThink != 3*time.Second {
+		t.Errorf("expected one move of white in 3s, got %d in %s", result.WhiteMoves, result.WhiteThink)
+	}
+	if result.BlackMoves != 1 || result.BlackThink != 2*time.Second {
+		t.Errorf("expected one move of black in 2s, got %d in %s", result.BlackMoves, result.BlackThink)
+	}
+}
@@ -0,0 +1,29 @@
+/*
+Secrets, session passwords and API keys, are only kept as hashes, in
+memory as well as in the journals. They are random tokens, so a plain
+SHA-256 is enough.
+*/
+package database
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+)
+
+// Returns the hash stored for a secret.
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Reports whether secret matches the stored hash. Compares in
+// constant time.
+func SecretMatches(hash string, secret string) bool {
+	return hash != "" && subtle.ConstantTimeCompare([]byte(HashSecret(secret)), []byte(hash)) == 1
+}
+
+// Reports whether password is the one of the game.
+func (game *Game) CheckPassword(password string) bool {
+	return SecretMatches(game.PasswordHash, password)
+}
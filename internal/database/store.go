@@ -0,0 +1,22 @@
+/*
+Persistence of games. A Store keeps the journals of the games so
+they survive restarts of the server, the repository appends every
+new entry to it. Persisted entries aren't kept in memory, they are
+read back when a journal is requested.
+*/
+package database
+
+type Store interface {
+	// Appends entries to the journal of a game.
+	Append(id int32, entries []JournalEntry) error
+	// Returns the journal of a game.
+	Journal(id int32) ([]JournalEntry, error)
+	// Removes a game.
+	Delete(id int32) error
+	// Removes a game but keeps its complete journal in an archive.
+	Archive(id int32, journal []JournalEntry) error
+	// Returns the journals of all stored games and the highest ID
+	// ever stored, so IDs of deleted games aren't reused.
+	Load() (map[int32][]JournalEntry, int32, error)
+	Close() error
+}
@@ -0,0 +1,572 @@
+/*
+Tournaments between accounts. A tournament is created by a user,
+accounts register until it starts, then it is played round by round.
+Every game of a round is a regular game; the tournament records its
+result once it finished or was aborted. In knockout tournaments, the
+games of a match are added to the round one after the other.
+
+Like accounts, tournaments are kept as a journal of entries that is
+replayed when the server starts.
+*/
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/tournament"
+)
+
+// States of tournaments.
+const (
+	TournamentRegistering = "registering"
+	TournamentRunning     = "running"
+	TournamentFinished    = "finished"
+)
+
+var (
+	ErrTournamentMissing = errors.New("tournament not found")
+	ErrNotRegistering    = errors.New("tournament doesn't take registrations anymore")
+	ErrAlreadyRegistered = errors.New("account is already registered")
+)
+
+type Tournament struct {
+	ID          int32
+	Name        string
+	Format      string // tournament.RoundRobin, DoubleRoundRobin, Swiss or Knockout.
+	Rounds      int    // Planned rounds, known for round robins and knockouts once started.
+	Owner       int32  // Account of the creating user.
+	TimeControl *TimeControl
+	MoveTime    time.Duration
+	MoveTimeout string
+
+	// Knockout only.
+	GamesPerMatch   int
+	TiebreakGames   int
+	TiebreakControl *TimeControl // Of tiebreak and armageddon games, nil for the regular one.
+
+	State     string
+	Players   []int32 // In order of registration, which is the seeding.
+	Round     int     // Current round, 0 before the start.
+	Games     []TournamentGame
+	CreatedAt time.Time
+	StartedAt time.Time
+	EndedAt   time.Time
+}
+
+// Game of a round, or a bye if Black is 0.
+type TournamentGame struct {
+	Round      int     `json:"round"`
+	Match      int     `json:"match,omitempty"` // Knockout only.
+	Kind       string  `json:"kind,omitempty"`  // Knockout only.
+	White      int32   `json:"white"`
+	Black      int32   `json:"black,omitempty"`
+	GameID     int32   `json:"gameid,omitempty"` // 0 for byes.
+	Done       bool    `json:"done,omitempty"`
+	WhiteScore float64 `json:"whitescore,omitempty"`
+	BlackScore float64 `json:"blackscore,omitempty"`
+}
+
+// Returns the finished games, byes included.
+func (t *Tournament) Results() []tournament.Result {
+	var results []tournament.Result
+	for _, game := range t.Games {
+		if game.Done {
+			results = append(results, tournament.Result{
+				Round:      game.Round,
+				Match:      game.Match,
+				Kind:       game.Kind,
+				White:      game.White,
+				Black:      game.Black,
+				WhiteScore: game.WhiteScore,
+				BlackScore: game.BlackScore,
+			})
+		}
+	}
+	return results
+}
+
+// Returns whether all games of the current round are finished.
+func (t *Tournament) RoundDone() bool {
+	for _, game := range t.Games {
+		if game.Round == t.Round && !game.Done {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the games of the matches of a knockout tournament.
+func (t *Tournament) MatchConfig() tournament.MatchConfig {
+	return tournament.MatchConfig{Games: t.GamesPerMatch, TiebreakGames: t.TiebreakGames}
+}
+
+func (t *Tournament) Registered(account int32) bool {
+	for _, player := range t.Players {
+		if player == account {
+			return true
+		}
+	}
+	return false
+}
+
+// Types of tournament journal entries.
+const (
+	TournamentCreated    = "created"
+	TournamentRegistered = "registered"
+	TournamentStarted    = "started"
+	TournamentRound      = "round"
+	TournamentGames      = "games" // Games added to the current round.
+	TournamentResult     = "result"
+	TournamentEnded      = "ended"
+)
+
+type TournamentEntry struct {
+	Time       time.Time `json:"time"`
+	Type       string    `json:"type"`
+	Tournament int32     `json:"tournament"`
+
+	// Created
+	Name        string        `json:"name,omitempty"`
+	Format      string        `json:"format,omitempty"`
+	Owner       int32         `json:"owner,omitempty"`
+	TimeControl *TimeControl  `json:"timecontrol,omitempty"`
+	MoveTime    time.Duration `json:"movetime,omitempty"`
+	MoveTimeout string        `json:"movetimeout,omitempty"`
+
+	// Created, knockout only
+	GamesPerMatch   int          `json:"gamespermatch,omitempty"`
+	TiebreakGames   int          `json:"tiebreakgames,omitempty"`
+	TiebreakControl *TimeControl `json:"tiebreakcontrol,omitempty"`
+
+	// Created and started
+	Rounds int `json:"rounds,omitempty"`
+
+	// Registered
+	Account int32 `json:"account,omitempty"`
+
+	// Round and games
+	Round int              `json:"round,omitempty"`
+	Games []TournamentGame `json:"games,omitempty"`
+
+	// Result
+	GameID     int32   `json:"gameid,omitempty"`
+	WhiteScore float64 `json:"whitescore,omitempty"`
+	BlackScore float64 `json:"blackscore,omitempty"`
+}
+
+// Persistence of the tournament journal.
+type TournamentStore interface {
+	Append(entry TournamentEntry) error
+	Load() ([]TournamentEntry, error)
+	Close() error
+}
+
+type FileTournamentStore = FileLineStore[TournamentEntry]
+
+// Returns the path of the tournament journal belonging to the game
+// file at path.
+func TournamentsPath(path string) string {
+	return path + ".tournaments"
+}
+
+// Opens the tournament journal at path, creating it if it doesn't
+// exist.
+func OpenFileTournamentStore(path string) (*FileTournamentStore, error) {
+	return openFileLineStore[TournamentEntry](path)
+}
+
+type TournamentRepository struct {
+	mu          sync.RWMutex
+	tournaments map[int32]*Tournament
+	byGame      map[int32]*Tournament // By the ID of a game of a round.
+	nextID      int32
+	store       TournamentStore // nil if tournaments aren't persisted.
+}
+
+func NewTournamentRepository() *TournamentRepository {
+	return &TournamentRepository{
+		tournaments: make(map[int32]*Tournament),
+		byGame:      make(map[int32]*Tournament),
+		nextID:      1,
+	}
+}
+
+// Returns a repository backed by store, filled with the tournaments
+// stored in it.
+func OpenTournamentRepository(store TournamentStore) (*TournamentRepository, error) {
+	entries, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	repo := NewTournamentRepository()
+	for i, entry := range entries {
+		if err := repo.check(entry); err != nil {
+			return nil, fmt.Errorf("tournament entry %d (%s): %v", i+1, entry.Type, err)
+		}
+		repo.apply(entry)
+	}
+	repo.store = store
+	return repo, nil
+}
+
+// Creates a tournament taking registrations. Only the ID, the state
+// and the creation time of t are set by the repository.
+func (repo *TournamentRepository) Create(t Tournament) (Tournament, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	entry := TournamentEntry{
+		Time:        time.Now(),
+		Type:        TournamentCreated,
+		Tournament:  repo.nextID,
+		Name:        t.Name,
+		Format:      t.Format,
+		Rounds:      t.Rounds,
+		Owner:       t.Owner,
+		TimeControl: t.TimeControl,
+		MoveTime:    t.MoveTime,
+		MoveTimeout: t.MoveTimeout,
+
+		GamesPerMatch:   t.GamesPerMatch,
+		TiebreakGames:   t.TiebreakGames,
+		TiebreakControl: t.TiebreakControl,
+	}
+	if err := repo.commit(entry); err != nil {
+		return Tournament{}, err
+	}
+	return repo.tournaments[entry.Tournament].copy(), nil
+}
+
+func (repo *TournamentRepository) Get(id int32) (Tournament, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	t, exists := repo.tournaments[id]
+	if !exists {
+		return Tournament{}, ErrTournamentMissing
+	}
+	return t.copy(), nil
+}
+
+// Returns all tournaments ordered by ID.
+func (repo *TournamentRepository) List() []Tournament {
+	repo.mu.RLock()
+	tournaments := make([]Tournament, 0, len(repo.tournaments))
+	for _, t := range repo.tournaments {
+		tournaments = append(tournaments, t.copy())
+	}
+	repo.mu.RUnlock()
+
+	sort.Slice(tournaments, func(i, j int) bool {
+		return tournaments[i].ID < tournaments[j].ID
+	})
+	return tournaments
+}
+
+// Returns the tournament a game belongs to, false if it isn't a game
+// of a tournament.
+func (repo *TournamentRepository) ByGame(gameID int32) (Tournament, bool) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	t, exists := repo.byGame[gameID]
+	if !exists {
+		return Tournament{}, false
+	}
+	return t.copy(), true
+}
+
+// Registers an account for a tournament.
+func (repo *TournamentRepository) Register(id int32, account int32) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	return repo.commit(TournamentEntry{
+		Time:       time.Now(),
+		Type:       TournamentRegistered,
+		Tournament: id,
+		Account:    account,
+	})
+}
+
+// Closes the registration. rounds is the number of rounds to play.
+func (repo *TournamentRepository) Start(id int32, rounds int) (Tournament, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	err := repo.commit(TournamentEntry{
+		Time:       time.Now(),
+		Type:       TournamentStarted,
+		Tournament: id,
+		Rounds:     rounds,
+	})
+	if err != nil {
+		return Tournament{}, err
+	}
+	return repo.tournaments[id].copy(), nil
+}
+
+// Adds the games of the next round. Byes have to be done already.
+func (repo *TournamentRepository) StartRound(id int32, round int, games []TournamentGame) (Tournament, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	err := repo.commit(TournamentEntry{
+		Time:       time.Now(),
+		Type:       TournamentRound,
+		Tournament: id,
+		Round:      round,
+		Games:      games,
+	})
+	if err != nil {
+		return Tournament{}, err
+	}
+	return repo.tournaments[id].copy(), nil
+}
+
+// Adds games to the current round, e.g. the next game of a match.
+func (repo *TournamentRepository) AddGames(id int32, games []TournamentGame) (Tournament, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	err := repo.commit(TournamentEntry{
+		Time:       time.Now(),
+		Type:       TournamentGames,
+		Tournament: id,
+		Games:      games,
+	})
+	if err != nil {
+		return Tournament{}, err
+	}
+	return repo.tournaments[id].copy(), nil
+}
+
+// Records the result of a game of a round. Returns the tournament
+// after it.
+func (repo *TournamentRepository) RecordResult(gameID int32, whiteScore float64, blackScore float64) (Tournament, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	t, exists := repo.byGame[gameID]
+	if !exists {
+		return Tournament{}, ErrTournamentMissing
+	}
+	err := repo.commit(TournamentEntry{
+		Time:       time.Now(),
+		Type:       TournamentResult,
+		Tournament: t.ID,
+		GameID:     gameID,
+		WhiteScore: whiteScore,
+		BlackScore: blackScore,
+	})
+	if err != nil {
+		return Tournament{}, err
+	}
+	return t.copy(), nil
+}
+
+// Ends a tournament after its last round.
+func (repo *TournamentRepository) End(id int32) (Tournament, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	err := repo.commit(TournamentEntry{
+		Time:       time.Now(),
+		Type:       TournamentEnded,
+		Tournament: id,
+	})
+	if err != nil {
+		return Tournament{}, err
+	}
+	return repo.tournaments[id].copy(), nil
+}
+
+// Validates an entry, persists it and applies it. Called with
+// repo.mu held.
+func (repo *TournamentRepository) commit(entry TournamentEntry) error {
+	if err := repo.check(entry); err != nil {
+		return err
+	}
+	if repo.store != nil {
+		if err := repo.store.Append(entry); err != nil {
+			return err
+		}
+	}
+	repo.apply(entry)
+	return nil
+}
+
+// Returns why an entry can't be applied, nil if it can.
+func (repo *TournamentRepository) check(entry TournamentEntry) error {
+	if entry.Type == TournamentCreated {
+		if !tournament.ValidFormat(entry.Format) {
+			return fmt.Errorf("invalid format %q", entry.Format)
+		}
+		if entry.Format == tournament.Swiss && entry.Rounds < 1 {
+			return errors.New("a Swiss tournament needs at least one round")
+		}
+		if entry.Format == tournament.Knockout && (entry.GamesPerMatch < 1 || entry.TiebreakGames < 0) {
+			return errors.New("a match needs at least one game")
+		}
+		return nil
+	}
+
+	t, exists := repo.tournaments[entry.Tournament]
+	if !exists {
+		return ErrTournamentMissing
+	}
+	switch entry.Type {
+	case TournamentRegistered:
+		if t.State != TournamentRegistering {
+			return ErrNotRegistering
+		}
+		if entry.Account == 0 {
+			return ErrAccountMissing
+		}
+		if t.Registered(entry.Account) {
+			return ErrAlreadyRegistered
+		}
+
+	case TournamentStarted:
+		if t.State != TournamentRegistering {
+			return errors.New("tournament was already started")
+		}
+		if len(t.Players) < 2 {
+			return tournament.ErrTooFewPlayers
+		}
+		if entry.Rounds < 1 {
+			return errors.New("a tournament needs at least one round")
+		}
+
+	case TournamentRound:
+		if t.State != TournamentRunning {
+			return errors.New("tournament isn't running")
+		}
+		if entry.Round != t.Round+1 || entry.Round > t.Rounds {
+			return fmt.Errorf("round %d can't follow round %d of %d", entry.Round, t.Round, t.Rounds)
+		}
+		if !t.RoundDone() {
+			return fmt.Errorf("round %d isn't finished", t.Round)
+		}
+		return repo.checkGames(t, entry.Round, entry.Games)
+
+	case TournamentGames:
+		if t.State != TournamentRunning {
+			return errors.New("tournament isn't running")
+		}
+		return repo.checkGames(t, t.Round, entry.Games)
+
+	case TournamentResult:
+		for _, game := range t.Games {
+			if game.GameID == entry.GameID {
+				if game.Done {
+					return fmt.Errorf("game %d has a result already", entry.GameID)
+				}
+				return nil
+			}
+		}
+		return fmt.Errorf("game %d isn't part of the tournament", entry.GameID)
+
+	case TournamentEnded:
+		if t.State != TournamentRunning || !t.RoundDone() {
+			return errors.New("tournament has unfinished games")
+		}
+
+	default:
+		return fmt.Errorf("unknown entry type %q", entry.Type)
+	}
+	return nil
+}
+
+// Returns why games can't be added to a round of t, nil if they can.
+func (repo *TournamentRepository) checkGames(t *Tournament, round int, games []TournamentGame) error {
+	for _, game := range games {
+		if game.Round != round || !t.Registered(game.White) ||
+			(game.Black != 0 && !t.Registered(game.Black)) {
+			return errors.New("invalid game of round")
+		}
+		if game.GameID != 0 {
+			if _, exists := repo.byGame[game.GameID]; exists {
+				return fmt.Errorf("game %d belongs to a round already", game.GameID)
+			}
+		}
+	}
+	return nil
+}
+
+// Applies a checked entry. Called with repo.mu held, or while
+// loading.
+func (repo *TournamentRepository) apply(entry TournamentEntry) {
+	switch entry.Type {
+	case TournamentCreated:
+		repo.tournaments[entry.Tournament] = &Tournament{
+			ID:          entry.Tournament,
+			Name:        entry.Name,
+			Format:      entry.Format,
+			Rounds:      entry.Rounds,
+			Owner:       entry.Owner,
+			TimeControl: entry.TimeControl,
+			MoveTime:    entry.MoveTime,
+			MoveTimeout: entry.MoveTimeout,
+			State:       TournamentRegistering,
+			CreatedAt:   entry.Time,
+
+			GamesPerMatch:   entry.GamesPerMatch,
+			TiebreakGames:   entry.TiebreakGames,
+			TiebreakControl: entry.TiebreakControl,
+		}
+		if entry.Tournament >= repo.nextID {
+			repo.nextID = entry.Tournament + 1
+		}
+
+	case TournamentRegistered:
+		t := repo.tournaments[entry.Tournament]
+		t.Players = append(t.Players, entry.Account)
+
+	case TournamentStarted:
+		t := repo.tournaments[entry.Tournament]
+		t.State = TournamentRunning
+		t.Rounds = entry.Rounds
+		t.StartedAt = entry.Time
+
+	case TournamentRound:
+		t := repo.tournaments[entry.Tournament]
+		t.Round = entry.Round
+		t.Games = append(t.Games, entry.Games...)
+		for _, game := range entry.Games {
+			if game.GameID != 0 {
+				repo.byGame[game.GameID] = t
+			}
+		}
+
+	case TournamentGames:
+		t := repo.tournaments[entry.Tournament]
+		t.Games = append(t.Games, entry.Games...)
+		for _, game := range entry.Games {
+			if game.GameID != 0 {
+				repo.byGame[game.GameID] = t
+			}
+		}
+
+	case TournamentResult:
+		t := repo.tournaments[entry.Tournament]
+		for i := range t.Games {
+			if t.Games[i].GameID == entry.GameID {
+				t.Games[i].Done = true
+				t.Games[i].WhiteScore = entry.WhiteScore
+				t.Games[i].BlackScore = entry.BlackScore
+			}
+		}
+
+	case TournamentEnded:
+		t := repo.tournaments[entry.Tournament]
+		t.State = TournamentFinished
+		t.EndedAt = entry.Time
+	}
+}
+
+// Returns a copy safe to use without the lock.
+func (t *Tournament) copy() Tournament {
+	c := *t
+	c.Players = append([]int32(nil), t.Players...)
+	c.Games = append([]TournamentGame(nil), t.Games...)
+	return c
+}
+
+// Tournaments of the server.
+var Tournaments = NewTournamentRepository()
@@ -0,0 +1,71 @@
+/*
+Unittest for the tournament journal.
+*/
+package database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/tournament"
+)
+
+func TestTournamentJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "games.log.tournaments")
+	store, err := OpenFileTournamentStore(path)
+	if err != nil {
+		t.Fatalf("fail in OpenFileTournamentStore: %s", err)
+	}
+	repo, _ := OpenTournamentRepository(store)
+
+	created, err := repo.Create(Tournament{Name: "cup", Format: tournament.Swiss, Rounds: 2, Owner: 1})
+	if err != nil {
+		t.Fatalf("fail in Create: %s", err)
+	}
+	if _, err := repo.Create(Tournament{Name: "cup", Format: tournament.Swiss}); err == nil {
+		t.Errorf("expected Swiss tournaments without rounds to be rejected")
+	}
+	for _, account := range []int32{2, 3, 4} {
+		if err := repo.Register(created.ID, account); err != nil {
+			t.Fatalf("fail in Register: %s", err)
+		}
+	}
+	if err := repo.Register(created.ID, 2); err != ErrAlreadyRegistered {
+		t.Errorf("expected a second registration to fail, got %v", err)
+	}
+	repo.Start(created.ID, 2)
+	if err := repo.Register(created.ID, 5); err != ErrNotRegistering {
+		t.Errorf("expected registrations to be closed, got %v", err)
+	}
+	_, err = repo.StartRound(created.ID, 1, []TournamentGame{
+		{Round: 1, White: 2, Black: 3, GameID: 10},
+		{Round: 1, White: 4, Done: true, WhiteScore: tournament.ByeScore},
+	})
+	if err != nil {
+		t.Fatalf("fail in StartRound: %s", err)
+	}
+	if _, err := repo.StartRound(created.ID, 2, nil); err == nil {
+		t.Errorf("expected round 2 to wait for round 1")
+	}
+	if current, _ := repo.RecordResult(10, 0.5, 0.5); !current.RoundDone() {
+		t.Errorf("expected round 1 to be done")
+	}
+	if _, err := repo.RecordResult(10, 1, 0); err == nil {
+		t.Errorf("expected a result to be recorded once")
+	}
+	store.Close()
+
+	store, _ = OpenFileTournamentStore(path)
+	defer store.Close()
+	repo, err = OpenTournamentRepository(store)
+	if err != nil {
+		t.Fatalf("fail in OpenTournamentRepository: %s", err)
+	}
+	restored, _ := repo.Get(created.ID)
+	if restored.State != TournamentRunning || len(restored.Players) != 3 || len(restored.Results()) != 2 {
+		t.Errorf("expected the tournament to be restored, got %+v", restored)
+	}
+	if byGame, member := repo.ByGame(10); !member || byGame.ID != created.ID {
+		t.Errorf("expected game 10 to belong to the tournament")
+	}
+}
@@ -6,9 +6,7 @@ board information are implemented here.
 package game_logic
 
 import (
-	"encoding/json"
 	"math"
-	"strings"
 )
 
 // BoardState represents the chessboard and its metadata
@@ -93,9 +91,9 @@ func getColorAndPiece(row int, col int, board [8][8]rune) (color rune, piece run
 
 	// Return color and piece in lowercase
 	if p >= 'a' && p <= 'z' {
-		return 'w', rune(strings.ToLower(string(p))[0])
+		return 'w', p
 	}
-	return 'b', rune(strings.ToLower(string(p))[0])
+	return 'b', p - 'A' + 'a'
 }
 
 // Checks whether a position is within the board's bounds.
@@ -109,9 +107,8 @@ func MakeMove(move *Move, bstate BoardState) BoardState {
 	toRow, toCol := move.to[0], move.to[1]
 	fromColor, fromPiece := getColorAndPiece(fromRow, fromCol, bstate.Board)
 
-	var newBstate BoardState
-	data, _ := json.Marshal(bstate)
-	json.Unmarshal(data, &newBstate)
+	// BoardState only holds values, so assigning it copies the board.
+	newBstate := bstate
 
 	// Update king positions
 	if fromPiece == 'x' {
@@ -146,3 +143,30 @@ func MakeMove(move *Move, bstate BoardState) BoardState {
 
 	return newBstate
 }
+
+// Checks whether a player lacks the pieces to ever checkmate:
+// a lone king, or king and a single bishop or knight against
+// a lone king.
+func InsufficientMaterial(color rune, bstate *BoardState) bool {
+	var own, enemy []rune
+	for row := 0; row < 8; row++ {
+		for col := 0; col < 8; col++ {
+			pieceColor, piece := getColorAndPiece(row, col, bstate.Board)
+			if piece == Empty || piece == 'x' {
+				continue
+			}
+			if pieceColor == color {
+				own = append(own, piece)
+			} else {
+				enemy = append(enemy, piece)
+			}
+		}
+	}
+	if len(own) == 0 {
+		return true
+	}
+	if len(own) == 1 && len(enemy) == 0 {
+		return own[0] == 'b' || own[0] == 'k'
+	}
+	return false
+}
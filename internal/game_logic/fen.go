@@ -0,0 +1,60 @@
+/*
+Conversion of positions to the Forsyth-Edwards Notation (FEN).
+*/
+
+package game_logic
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FEN letters of the pieces, by their lowercase letters on the board.
+var FENLetters = map[rune]rune{'p': 'p', 'r': 'r', 'k': 'n', 'b': 'b', 'q': 'q', 'x': 'k'}
+
+// Returns the position in FEN, given the plies since the last capture
+// or pawn move and the number of the move. Castling isn't played, so
+// the castling field is always "-".
+func FEN(bstate *BoardState, halfmoves int, fullmove int) string {
+	var sb strings.Builder
+	for row := 0; row < 8; row++ {
+		empty := 0
+		for col := 0; col < 8; col++ {
+			color, piece := getColorAndPiece(row, col, bstate.Board)
+			if piece == Empty {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				sb.WriteByte(byte('0' + empty))
+				empty = 0
+			}
+			letter := FENLetters[piece]
+			if color == 'w' {
+				letter = letter - 'a' + 'A'
+			}
+			sb.WriteRune(letter)
+		}
+		if empty > 0 {
+			sb.WriteByte(byte('0' + empty))
+		}
+		if row < 7 {
+			sb.WriteByte('/')
+		}
+	}
+
+	turn := bstate.TurnColor
+	if turn != "b" {
+		turn = "w"
+	}
+	// The target field lies behind the pawn that moved two fields.
+	enPassant := "-"
+	if isInBounds(bstate.EnPassant) {
+		row := bstate.EnPassant[0] + 1
+		if turn == "w" {
+			row = bstate.EnPassant[0] - 1
+		}
+		enPassant = string([]byte{byte('a' + bstate.EnPassant[1]), byte('0' + 8 - row)})
+	}
+	return fmt.Sprintf("%s %s - %s %d %d", sb.String(), turn, enPassant, halfmoves, fullmove)
+}
@@ -449,3 +449,64 @@ func CompareMoves(t *testing.T, actualMoves []*Move, expectedMoves []*Move) {
 		}
 	}
 }
+
+func TestFEN(t *testing.T) {
+	var states []BoardState
+	InitializeBoard(&states)
+	bstate := states[0]
+	if fen := FEN(&bstate, 0, 1); fen != "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w - - 0 1" {
+		t.Errorf("unexpected FEN of the initial position: %s", fen)
+	}
+
+	move, _ := StringToMoveStruct("e2 e4", 'w')
+	bstate = MakeMove(&move, bstate)
+	if fen := FEN(&bstate, 0, 1); fen != "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b - e3 0 1" {
+		t.Errorf("unexpected FEN after e2 e4: %s", fen)
+	}
+	move, _ = StringToMoveStruct("g8 f6", 'b')
+	bstate = MakeMove(&move, bstate)
+	if fen := FEN(&bstate, 1, 2); fen != "rnbqkb1r/pppppppp/5n2/8/4P3/8/PPPP1PPP/RNBQKBNR w - - 1 2" {
+		t.Errorf("unexpected FEN after g8 f6: %s", fen)
+	}
+}
+
+func TestSAN(t *testing.T) {
+	var states []BoardState
+	InitializeBoard(&states)
+	bstate := states[0]
+	play := func(moveStr string, expected string) {
+		t.Helper()
+		move, _ := StringToMoveStruct(moveStr, rune(bstate.TurnColor[0]))
+		san, err := SAN(&move, &bstate)
+		if err != nil {
+			t.Fatalf("fail in SAN of %s: %v", moveStr, err)
+		}
+		if san != expected {
+			t.Errorf("expected %s for %s, got %s", expected, moveStr, san)
+		}
+		bstate = MakeMove(&move, bstate)
+	}
+	play("e2 e4", "e4")
+	play("d7 d5", "d5")
+	play("e4 d5", "exd5")
+	play("g8 f6", "Nf6")
+	play("g1 f3", "Nf3")
+	play("f6 d5", "Nxd5")
+	play("d2 d4", "d4")
+	play("d5 b4", "Nb4")
+	// Both knights reach d2, the file tells them apart.
+	play("b1 d2", "Nbd2")
+	play("b4 c2", "Nxc2+")
+
+	move, _ := StringToMoveStruct("a1 a5", 'w')
+	if _, err := SAN(&move, &bstate); err == nil {
+		t.Errorf("expected an error for an illegal move")
+	}
+
+	InitializeBoard(&states)
+	bstate = states[0]
+	play("f2 f3", "f3")
+	play("e7 e5", "e5")
+	play("g2 g4", "g4")
+	play("d8 h4", "Qh4#")
+}
@@ -287,6 +287,33 @@ func isRemis(boardState *BoardState) (bool, error) {
 	return false, nil
 }
 
+// Checks whether the king of the given player is under attack.
+func IsInCheck(color rune, boardState *BoardState) bool {
+	attacked, err := kingAttacked(color, boardState)
+	return err == nil && attacked
+}
+
+// Checks whether the player to move has run out of moves.
+// Returns the winner ('w', 'b' or 'r' for remis) and the reason
+// ("checkmate" or "stalemate"), or 'n' and an empty reason if the
+// game goes on.
+func GameEnd(boardState *BoardState) (string, string) {
+	if boardState.TurnColor != "w" && boardState.TurnColor != "b" {
+		return "n", ""
+	}
+	if len(LegalMoves(boardState)) > 0 {
+		return "n", ""
+	}
+	color := rune(boardState.TurnColor[0])
+	if IsInCheck(color, boardState) {
+		if color == 'w' {
+			return "b", "checkmate"
+		}
+		return "w", "checkmate"
+	}
+	return "r", "stalemate"
+}
+
 func getKingdataFromColor(color rune, boardState *BoardState) (int, int, rune) {
 	var row, col int
 	var enemy_color rune
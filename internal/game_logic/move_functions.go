@@ -6,7 +6,6 @@ move formatting, move generation and move validation.
 package game_logic
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 )
@@ -22,6 +21,34 @@ type Move struct {
 	next    *Move
 }
 
+// Returns the starting field of the move as [row, col].
+func (move Move) From() [2]int {
+	return move.from
+}
+
+// Returns the target field of the move as [row, col].
+func (move Move) To() [2]int {
+	return move.to
+}
+
+// Returns the color of the moving player, 'w' or 'b'.
+func (move Move) Color() rune {
+	return move.color
+}
+
+// Reports whether the move captures a piece.
+func (move Move) IsCapture() bool {
+	return move.capture
+}
+
+// Converts the move to the "e2 e4" notation used by the API.
+func (move Move) String() string {
+	indexToChess := func(pos [2]int) string {
+		return string([]byte{byte('a' + pos[1]), byte('0' + 8 - pos[0])})
+	}
+	return indexToChess(move.from) + " " + indexToChess(move.to)
+}
+
 // Comparator for Move
 func eqMove(move1, move2 *Move) bool {
 	if move1 == nil || move2 == nil {
@@ -320,11 +347,7 @@ func ValidateMove(move *Move, bstate *BoardState) error {
 		return errors.New("move doesn't exist")
 	}
 
-	var tmpBstate BoardState
-	data, _ := json.Marshal(bstate)
-	json.Unmarshal(data, &tmpBstate)
-
-	new_bstate := MakeMove(move, tmpBstate)
+	new_bstate := MakeMove(move, *bstate)
 
 	attacked, err := kingAttacked(color, &new_bstate)
 	if err != nil {
@@ -336,3 +359,23 @@ func ValidateMove(move *Move, bstate *BoardState) error {
 
 	return nil
 }
+
+// Returns all moves the player to move is allowed to make.
+func LegalMoves(bstate *BoardState) []Move {
+	if bstate.TurnColor != "w" && bstate.TurnColor != "b" {
+		return nil
+	}
+	color := rune(bstate.TurnColor[0])
+
+	var legal []Move
+	moves := allPossibleMoves(color, bstate, []rune{})
+	for moves != nil {
+		move := *moves
+		move.next = nil
+		moves = moves.next
+		if ValidateMove(&move, bstate) == nil {
+			legal = append(legal, move)
+		}
+	}
+	return legal
+}
@@ -0,0 +1,82 @@
+/*
+Conversion of moves to the Standard Algebraic Notation (SAN) used by
+PGN, e.g. "Nf3", "exd5", "Rad1" or "Qh4#".
+*/
+
+package game_logic
+
+import (
+	"strings"
+)
+
+// Returns the move played in the position in SAN. The move has to be
+// legal. Castling and promotions aren't played, so neither is written.
+func SAN(move *Move, bstate *BoardState) (string, error) {
+	if err := ValidateMove(move, bstate); err != nil {
+		return "", err
+	}
+	_, piece := getColorAndPiece(move.from[0], move.from[1], bstate.Board)
+	_, target := getColorAndPiece(move.to[0], move.to[1], bstate.Board)
+	capture := target != Empty
+	square := func(pos [2]int) string {
+		return string([]byte{byte('a' + pos[1]), byte('0' + 8 - pos[0])})
+	}
+
+	var sb strings.Builder
+	if piece == 'p' {
+		if capture {
+			sb.WriteByte(square(move.from)[0])
+		}
+	} else {
+		sb.WriteRune(FENLetters[piece] - 'a' + 'A')
+		sb.WriteString(disambiguation(move, piece, bstate))
+	}
+	if capture {
+		sb.WriteByte('x')
+	}
+	sb.WriteString(square(move.to))
+
+	after := MakeMove(move, *bstate)
+	opponent := rune(after.TurnColor[0])
+	if IsInCheck(opponent, &after) {
+		if len(LegalMoves(&after)) == 0 {
+			sb.WriteByte('#')
+		} else {
+			sb.WriteByte('+')
+		}
+	}
+	return sb.String(), nil
+}
+
+// Returns the file, rank or both of the starting field if another
+// piece of the same kind can reach the target field as well.
+func disambiguation(move *Move, piece rune, bstate *BoardState) string {
+	sameFile, sameRank, ambiguous := false, false, false
+	for _, other := range LegalMoves(bstate) {
+		if other.to != move.to || other.from == move.from {
+			continue
+		}
+		_, otherPiece := getColorAndPiece(other.from[0], other.from[1], bstate.Board)
+		if otherPiece != piece {
+			continue
+		}
+		ambiguous = true
+		if other.from[1] == move.from[1] {
+			sameFile = true
+		}
+		if other.from[0] == move.from[0] {
+			sameRank = true
+		}
+	}
+	file := string(rune('a' + move.from[1]))
+	rank := string(rune('0' + 8 - move.from[0]))
+	switch {
+	case !ambiguous:
+		return ""
+	case !sameFile:
+		return file
+	case !sameRank:
+		return rank
+	}
+	return file + rank
+}
@@ -0,0 +1,209 @@
+/*
+Ratings of accounts. Every game updates an Elo rating with a fixed K
+and a Glicko-2 rating, which also tracks how certain it is (the
+rating deviation) and how consistently the player performs (the
+volatility). Each game is rated on its own, as a rating period of
+one game.
+
+Glicko-2 follows "Example of the Glicko-2 system" by Mark E.
+Glickman.
+*/
+package rating
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	InitialRating     = 1500
+	InitialRD         = 350
+	InitialVolatility = 0.06
+)
+
+// Factor between the Glicko and the Glicko-2 scale.
+const glickoScale = 173.7178
+
+// Precision of the volatility iteration.
+const convergence = 0.000001
+
+// Parameters of the rating systems.
+type Config struct {
+	K   float64 // Elo K-factor, the most a game can change the rating.
+	Tau float64 // Glicko-2 system constant, limits volatility changes.
+}
+
+var DefaultConfig = Config{K: 32, Tau: 0.5}
+
+type Glicko struct {
+	Rating     float64 `json:"rating"`
+	RD         float64 `json:"rd"`
+	Volatility float64 `json:"volatility"`
+}
+
+// Ratings of an account in one category.
+type Rating struct {
+	Elo    float64 `json:"elo"`
+	Glicko Glicko  `json:"glicko"`
+	Games  int     `json:"games"`
+}
+
+// Returns the rating of a player without games.
+func New() Rating {
+	return Rating{
+		Elo:    InitialRating,
+		Glicko: Glicko{Rating: InitialRating, RD: InitialRD, Volatility: InitialVolatility},
+	}
+}
+
+// Returns the ratings of both players after a game. Score is the
+// one of white: 1 for a win, 0.5 for a draw and 0 for a loss.
+func (config Config) Rate(white Rating, black Rating, score float64) (Rating, Rating) {
+	newWhite := Rating{
+		Elo:    EloUpdate(white.Elo, black.Elo, score, config.K),
+		Glicko: white.Glicko.Update(black.Glicko, score, config.Tau),
+		Games:  white.Games + 1,
+	}
+	newBlack := Rating{
+		Elo:    EloUpdate(black.Elo, white.Elo, 1-score, config.K),
+		Glicko: black.Glicko.Update(white.Glicko, 1-score, config.Tau),
+		Games:  black.Games + 1,
+	}
+	return newWhite, newBlack
+}
+
+// Returns the expected score of a player with the given Elo rating
+// against the opponent.
+func EloExpected(rating float64, opponent float64) float64 {
+	return 1 / (1 + math.Pow(10, (opponent-rating)/400))
+}
+
+// Returns the Elo rating after a game with the given score.
+func EloUpdate(rating float64, opponent float64, score float64, k float64) float64 {
+	return rating + k*(score-EloExpected(rating, opponent))
+}
+
+// Returns the expected score against the opponent, taking the
+// uncertainty of both ratings into account.
+func (player Glicko) Expected(opponent Glicko) float64 {
+	phi := math.Hypot(player.RD, opponent.RD) / glickoScale
+	mu := (player.Rating - opponent.Rating) / glickoScale
+	return 1 / (1 + math.Exp(-g(phi)*mu))
+}
+
+// Returns the rating after a single game.
+func (player Glicko) Update(opponent Glicko, score float64, tau float64) Glicko {
+	return player.UpdatePeriod([]Glicko{opponent}, []float64{score}, tau)
+}
+
+// Returns the rating after a rating period with games against the
+// given opponents. Without games only the deviation grows.
+func (player Glicko) UpdatePeriod(opponents []Glicko, scores []float64, tau float64) Glicko {
+	mu := (player.Rating - InitialRating) / glickoScale
+	phi := player.RD / glickoScale
+	sigma := player.Volatility
+	if len(opponents) == 0 {
+		return Glicko{
+			Rating:     player.Rating,
+			RD:         math.Sqrt(phi*phi+sigma*sigma) * glickoScale,
+			Volatility: sigma,
+		}
+	}
+
+	// Estimated variance and improvement.
+	var vInv, sum float64
+	for i, opponent := range opponents {
+		muJ := (opponent.Rating - InitialRating) / glickoScale
+		gJ := g(opponent.RD / glickoScale)
+		e := 1 / (1 + math.Exp(-gJ*(mu-muJ)))
+		vInv += gJ * gJ * e * (1 - e)
+		sum += gJ * (scores[i] - e)
+	}
+	v := 1 / vInv
+	delta := v * sum
+
+	sigma = newVolatility(phi, sigma, v, delta, tau)
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	newPhi := 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	newMu := mu + newPhi*newPhi*sum
+	return Glicko{
+		Rating:     newMu*glickoScale + InitialRating,
+		RD:         newPhi * glickoScale,
+		Volatility: sigma,
+	}
+}
+
+func g(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
+
+// Finds the new volatility with the Illinois algorithm.
+func newVolatility(phi float64, sigma float64, v float64, delta float64, tau float64) float64 {
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/(tau*tau)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*tau) < 0 {
+			k++
+		}
+		B = a - k*tau
+	}
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > convergence {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	return math.Exp(A / 2)
+}
+
+// Categories of time controls, rated separately.
+const (
+	Bullet    = "bullet"
+	Blitz     = "blitz"
+	Rapid     = "rapid"
+	Classical = "classical"
+	Unlimited = "unlimited"
+)
+
+// Returns the category of a time control by the estimated duration
+// of a game of 40 moves. Games without any time limit are
+// Unlimited.
+func Category(base time.Duration, increment time.Duration) string {
+	estimate := base + 40*increment
+	switch {
+	case estimate == 0:
+		return Unlimited
+	case estimate < 3*time.Minute:
+		return Bullet
+	case estimate < 8*time.Minute:
+		return Blitz
+	case estimate < 25*time.Minute:
+		return Rapid
+	default:
+		return Classical
+	}
+}
+
+// Reports whether category is a valid category.
+func ValidCategory(category string) bool {
+	switch category {
+	case Bullet, Blitz, Rapid, Classical, Unlimited:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+/*
+Unittest for the rating systems.
+*/
+package rating
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func near(a float64, b float64, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+// Example from Glickman's description of Glicko-2.
+func TestGlickoExample(t *testing.T) {
+	player := Glicko{Rating: 1500, RD: 200, Volatility: 0.06}
+	opponents := []Glicko{
+		{Rating: 1400, RD: 30, Volatility: 0.06},
+		{Rating: 1550, RD: 100, Volatility: 0.06},
+		{Rating: 1700, RD: 300, Volatility: 0.06},
+	}
+	rated := player.UpdatePeriod(opponents, []float64{1, 0, 0}, 0.5)
+	if !near(rated.Rating, 1464.06, 0.01) || !near(rated.RD, 151.52, 0.01) || !near(rated.Volatility, 0.05999, 0.00001) {
+		t.Errorf("expected 1464.06, 151.52 and 0.05999, got %.2f, %.2f and %.5f", rated.Rating, rated.RD, rated.Volatility)
+	}
+
+	idle := player.UpdatePeriod(nil, nil, 0.5)
+	if idle.Rating != player.Rating || idle.RD <= player.RD {
+		t.Errorf("expected only the deviation to grow without games")
+	}
+}
+
+func TestElo(t *testing.T) {
+	if e := EloExpected(1500, 1500); e != 0.5 {
+		t.Errorf("expected 0.5 between equal ratings, got %f", e)
+	}
+	if e := EloExpected(1900, 1500); !near(e, 0.909, 0.001) {
+		t.Errorf("expected 0.909 for 400 points more, got %f", e)
+	}
+
+	white, black := DefaultConfig.Rate(New(), New(), 1)
+	if white.Elo != 1516 || black.Elo != 1484 {
+		t.Errorf("expected 1516 and 1484, got %f and %f", white.Elo, black.Elo)
+	}
+	if white.Games != 1 || white.Glicko.Rating <= InitialRating || white.Glicko.RD >= InitialRD {
+		t.Errorf("expected the Glicko rating of the winner to rise and get more certain, got %+v", white.Glicko)
+	}
+	if !near(white.Glicko.Rating-InitialRating, InitialRating-black.Glicko.Rating, 1e-9) {
+		t.Errorf("expected symmetric changes between equal players")
+	}
+}
+
+func TestCategory(t *testing.T) {
+	cases := []struct {
+		base, increment time.Duration
+		category        string
+	}{
+		{0, 0, Unlimited},
+		{time.Minute, 0, Bullet},
+		{3 * time.Minute, 2 * time.Second, Blitz},
+		{10 * time.Minute, 0, Rapid},
+		{30 * time.Minute, 20 * time.Second, Classical},
+		{0, 10 * time.Second, Blitz},
+	}
+	for _, c := range cases {
+		if category := Category(c.base, c.increment); category != c.category {
+			t.Errorf("%v+%v: expected %s, got %s", c.base, c.increment, c.category, category)
+		}
+	}
+}
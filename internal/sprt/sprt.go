@@ -0,0 +1,148 @@
+/*
+Statistics of matches between two players, A and B, played in pairs
+of games with swapped colours. The results decide a Sequential
+Probability Ratio Test between the hypotheses that A is elo0 or elo1
+Elo stronger than B, using the normal approximation of the
+pentanomial distribution of the pair scores.
+*/
+package sprt
+
+import (
+	"errors"
+	"math"
+)
+
+// Decisions of the test.
+const (
+	H0 = "h0" // A isn't stronger by elo1, the change is rejected.
+	H1 = "h1" // A isn't weaker than elo0, the change is accepted.
+)
+
+// Bounds of the test.
+type Config struct {
+	Elo0  float64
+	Elo1  float64
+	Alpha float64 // Probability to accept H1 when H0 is true.
+	Beta  float64 // Probability to accept H0 when H1 is true.
+}
+
+var DefaultConfig = Config{Elo0: 0, Elo1: 5, Alpha: 0.05, Beta: 0.05}
+
+func (config Config) Validate() error {
+	if config.Elo1 <= config.Elo0 {
+		return errors.New("'elo1' has to be greater than 'elo0'")
+	}
+	if config.Alpha <= 0 || config.Alpha >= 0.5 || config.Beta <= 0 || config.Beta >= 0.5 {
+		return errors.New("'alpha' and 'beta' have to be between 0 and 0.5")
+	}
+	return nil
+}
+
+// Returns the log-likelihood ratios at which H0 and H1 are accepted.
+func (config Config) Bounds() (float64, float64) {
+	return math.Log(config.Beta / (1 - config.Alpha)), math.Log((1 - config.Beta) / config.Alpha)
+}
+
+// Results from the point of view of A.
+type Stats struct {
+	Wins   int
+	Draws  int
+	Losses int
+	// Number of pairs by the points of A in both games, 0 to 2 in
+	// steps of 1/2.
+	Pentanomial [5]int
+}
+
+// Adds a pair of games, given the scores of A.
+func (s *Stats) AddPair(first float64, second float64) {
+	for _, score := range []float64{first, second} {
+		switch score {
+		case 1:
+			s.Wins++
+		case 0.5:
+			s.Draws++
+		default:
+			s.Losses++
+		}
+	}
+	s.Pentanomial[int(math.Round((first+second)*2))]++
+}
+
+func (s Stats) Pairs() int {
+	pairs := 0
+	for _, n := range s.Pentanomial {
+		pairs += n
+	}
+	return pairs
+}
+
+// Returns the mean score of A per game and the variance of the
+// mean score of a pair.
+func (s Stats) moments() (float64, float64) {
+	pairs := float64(s.Pairs())
+	if pairs == 0 {
+		return 0.5, 0
+	}
+	var mean, variance float64
+	for i, n := range s.Pentanomial {
+		mean += float64(i) / 4 * float64(n) / pairs
+	}
+	for i, n := range s.Pentanomial {
+		d := float64(i)/4 - mean
+		variance += d * d * float64(n) / pairs
+	}
+	return mean, variance
+}
+
+// Returns the score of A per game, 0.5 before the first pair.
+func (s Stats) Score() float64 {
+	mean, _ := s.moments()
+	return mean
+}
+
+// Returns the Elo difference of A to B and the margin of its 95%
+// confidence interval. Scores are clamped to keep both finite.
+func (s Stats) Elo() (float64, float64) {
+	mean, variance := s.moments()
+	pairs := s.Pairs()
+	if pairs == 0 {
+		return 0, 0
+	}
+	deviation := 1.96 * math.Sqrt(variance/float64(pairs))
+	return Elo(mean), (Elo(mean+deviation) - Elo(mean-deviation)) / 2
+}
+
+// Returns the log-likelihood ratio of H1 to H0, 0 as long as the
+// pairs don't vary.
+func (s Stats) LLR(config Config) float64 {
+	mean, variance := s.moments()
+	if variance == 0 {
+		return 0
+	}
+	s0, s1 := Expected(config.Elo0), Expected(config.Elo1)
+	return float64(s.Pairs()) * (s1 - s0) * (2*mean - s0 - s1) / (2 * variance)
+}
+
+// Returns H0 or H1 once the LLR crossed a bound, "" before.
+func (s Stats) Decide(config Config) string {
+	lower, upper := config.Bounds()
+	llr := s.LLR(config)
+	switch {
+	case llr >= upper:
+		return H1
+	case llr <= lower:
+		return H0
+	}
+	return ""
+}
+
+// Returns the expected score at an Elo difference.
+func Expected(elo float64) float64 {
+	return 1 / (1 + math.Pow(10, -elo/400))
+}
+
+// Returns the Elo difference of a score, within ±1200.
+func Elo(score float64) float64 {
+	score = math.Min(math.Max(score, 0.001), 0.999)
+	return -400 * math.Log10(1/score-1)
+}
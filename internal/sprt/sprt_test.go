@@ -0,0 +1,69 @@
+/*
+Unittest for the match statistics and the SPRT.
+*/
+package sprt
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a float64, b float64, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+func TestStats(t *testing.T) {
+	var s Stats
+	for i := 0; i < 10; i++ {
+		s.AddPair(1, 0)   // Both won with white.
+		s.AddPair(1, 0.5) // A won with white and drew with black.
+	}
+	if s.Wins != 20 || s.Draws != 10 || s.Losses != 10 || s.Pentanomial != [5]int{0, 0, 10, 10, 0} {
+		t.Fatalf("unexpected counts %+v", s)
+	}
+	if s.Score() != 0.625 {
+		t.Errorf("expected a score of 0.625, got %f", s.Score())
+	}
+	elo, margin := s.Elo()
+	if !near(elo, 88.74, 0.01) || margin <= 0 || margin > elo {
+		t.Errorf("expected about 88.74 Elo with a smaller margin, got %.2f ± %.2f", elo, margin)
+	}
+	if llr := s.LLR(DefaultConfig); !near(llr, 1.118, 0.001) {
+		t.Errorf("expected an LLR of 1.118, got %.3f", llr)
+	}
+	if d := s.Decide(DefaultConfig); d != "" {
+		t.Errorf("expected no decision yet, got %q", d)
+	}
+	for i := 0; i < 20; i++ {
+		s.AddPair(1, 0.5)
+		s.AddPair(1, 0)
+	}
+	if d := s.Decide(DefaultConfig); d != H1 {
+		t.Errorf("expected H1 to be accepted, got %q at LLR %.3f", d, s.LLR(DefaultConfig))
+	}
+
+	var losing Stats
+	for i := 0; i < 60; i++ {
+		losing.AddPair(0, 0.5)
+		losing.AddPair(0.5, 0.5)
+	}
+	if d := losing.Decide(DefaultConfig); d != H0 {
+		t.Errorf("expected H0 to be accepted, got %q at LLR %.3f", d, losing.LLR(DefaultConfig))
+	}
+}
+
+func TestConfig(t *testing.T) {
+	lower, upper := DefaultConfig.Bounds()
+	if !near(lower, -2.944, 0.001) || !near(upper, 2.944, 0.001) {
+		t.Errorf("expected bounds of ±2.944, got %.3f and %.3f", lower, upper)
+	}
+	if err := (Config{Elo0: 5, Elo1: 0, Alpha: 0.05, Beta: 0.05}).Validate(); err == nil {
+		t.Errorf("expected elo1 below elo0 to be invalid")
+	}
+	if err := (Config{Elo0: 0, Elo1: 5, Alpha: 0, Beta: 0.05}).Validate(); err == nil {
+		t.Errorf("expected an alpha of 0 to be invalid")
+	}
+	if Elo(1) != Elo(0.999) || Elo(0.5) != 0 {
+		t.Errorf("expected Elo to be clamped and 0 at 0.5")
+	}
+}
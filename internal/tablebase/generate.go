@@ -0,0 +1,330 @@
+/*
+Retrograde generation of tables.
+
+Every legal position is first examined by its legal moves. Mated
+players are lost in 0 plies, moves into smaller materials (captures)
+are looked up in the tables of the set. Starting from the mates,
+results are then propagated backwards by taking moves back: a
+position before a lost position is won, a position whose moves all
+lead into won positions is lost. Whatever stays unresolved is a draw.
+*/
+
+package tablebase
+
+import (
+	"fmt"
+
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/game_logic"
+)
+
+var knightOffsets = [][2]int{{-2, -1}, {-2, 1}, {-1, -2}, {-1, 2}, {1, -2}, {1, 2}, {2, -1}, {2, 1}}
+var kingOffsets = [][2]int{{-1, -1}, {-1, 0}, {-1, 1}, {0, -1}, {0, 1}, {1, -1}, {1, 0}, {1, 1}}
+var straightDeltas = [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+var diagonalDeltas = [][2]int{{-1, -1}, {-1, 1}, {1, -1}, {1, 1}}
+
+// Flags of positions during generation.
+const (
+	flagDrawEscape uint8 = 1 << iota // A move leads into a drawn position.
+	flagCaptureWin                   // A capture wins.
+)
+
+type generator struct {
+	set      *Set
+	material material
+	values   []uint16
+	count    []uint8 // Successors within the table that are not known to be won for the opponent.
+	flags    []uint8
+	floor    map[int]int // Minimal distance of a loss, forced by captures into won positions.
+	levels   [][]int     // Positions to resolve, by distance to mate.
+}
+
+// Generates the table for the given material and adds it to the set.
+// Tables for the materials left after captures are generated first
+// if the set lacks them.
+func (s *Set) Generate(signature string) (*Table, error) {
+	m, err := parseMaterial(signature)
+	if err != nil {
+		return nil, err
+	}
+	if len(m.slots) <= 2 {
+		return nil, fmt.Errorf("%s: lone kings are always a draw", m.name)
+	}
+	if t, exists := s.tables[m.name]; exists {
+		return t, nil
+	}
+
+	for i, sl := range m.slots {
+		if sl.piece == 'x' {
+			continue
+		}
+		sub := m.without(i)
+		if len(sub.slots) <= 2 {
+			continue
+		}
+		if _, exists := s.tables[sub.name]; exists {
+			continue
+		}
+		if _, exists := s.tables[flippedSignature(sub.name)]; exists {
+			continue
+		}
+		name := sub.name
+		if sub.slots[1].color == 'b' {
+			// Prefer the side with pieces as white.
+			name = flippedSignature(name)
+		}
+		if _, err := s.Generate(name); err != nil {
+			return nil, err
+		}
+	}
+
+	g := &generator{
+		set:      s,
+		material: m,
+		values:   make([]uint16, m.size()),
+		count:    make([]uint8, m.size()),
+		flags:    make([]uint8, m.size()),
+		floor:    make(map[int]int),
+	}
+	g.examine()
+	g.propagate()
+
+	t := &Table{material: m, values: g.values}
+	s.Add(t)
+	return t, nil
+}
+
+// Adds a position to be resolved at the given distance.
+func (g *generator) schedule(idx int, dtm int) {
+	for len(g.levels) <= dtm {
+		g.levels = append(g.levels, nil)
+	}
+	g.levels[dtm] = append(g.levels[dtm], idx)
+}
+
+// Examines the moves of every position of the table.
+func (g *generator) examine() {
+	m := g.material
+	squares := make([]int, len(m.slots))
+	nextSquares := make([]int, len(m.slots))
+
+	for idx := range g.values {
+		stm := m.decode(idx, squares)
+		if !g.canonical(idx, squares, stm) {
+			continue
+		}
+		bstate := m.board(squares, stm)
+		mover, waiting := rune(bstate.TurnColor[0]), 'w'
+		if mover == 'w' {
+			waiting = 'b'
+		}
+		if game_logic.IsInCheck(waiting, &bstate) {
+			continue
+		}
+		g.values[idx] = valueDraw
+
+		moves := game_logic.LegalMoves(&bstate)
+		if len(moves) == 0 {
+			if game_logic.IsInCheck(mover, &bstate) {
+				g.schedule(idx, 0)
+			} else {
+				g.flags[idx] |= flagDrawEscape
+			}
+			continue
+		}
+
+		var successors []int
+		bestWin, floor := -1, 0
+		for _, move := range moves {
+			next := game_logic.MakeMove(&move, bstate)
+			if !m.squaresOf(&next.Board, nextSquares) {
+				// Capture into a smaller material.
+				entry, ok := g.set.Probe(&next)
+				if !ok {
+					panic(fmt.Sprintf("%s: no table for %s", m.name, boardSignature(&next.Board)))
+				}
+				switch entry.WDL {
+				case Loss:
+					if bestWin < 0 || entry.DTM+1 < bestWin {
+						bestWin = entry.DTM + 1
+					}
+				case Win:
+					if entry.DTM+1 > floor {
+						floor = entry.DTM + 1
+					}
+				case Draw:
+					g.flags[idx] |= flagDrawEscape
+				}
+				continue
+			}
+			next_idx := m.encode(nextSquares, 1-stm)
+			if !contains(successors, next_idx) {
+				successors = append(successors, next_idx)
+			}
+		}
+
+		g.count[idx] = uint8(len(successors))
+		if floor > 0 {
+			g.floor[idx] = floor
+		}
+		if bestWin >= 0 {
+			g.flags[idx] |= flagCaptureWin
+			g.schedule(idx, bestWin)
+		} else if len(successors) == 0 && g.flags[idx]&flagDrawEscape == 0 {
+			g.schedule(idx, floor)
+		}
+	}
+}
+
+// Reports whether idx is the canonical index of a position without
+// overlapping pieces.
+func (g *generator) canonical(idx int, squares []int, stm int) bool {
+	for i := range squares {
+		for j := i + 1; j < len(squares); j++ {
+			if squares[i] == squares[j] {
+				return false
+			}
+		}
+	}
+	return g.material.encode(squares, stm) == idx
+}
+
+// Resolves the scheduled positions by increasing distance to mate
+// and takes back moves to reach their predecessors.
+func (g *generator) propagate() {
+	for dtm := 0; dtm < len(g.levels); dtm++ {
+		for i := 0; i < len(g.levels[dtm]); i++ {
+			idx := g.levels[dtm][i]
+			if g.values[idx] != valueDraw {
+				// Already resolved at a smaller distance.
+				continue
+			}
+			g.values[idx] = uint16(dtm + 2)
+
+			for _, prev := range g.predecessors(idx) {
+				if g.values[prev] != valueDraw {
+					continue
+				}
+				if dtm%2 == 0 {
+					// A move into a lost position wins.
+					g.schedule(prev, dtm+1)
+					continue
+				}
+				g.count[prev]--
+				if g.count[prev] > 0 || g.flags[prev] != 0 {
+					continue
+				}
+				// Every move leads into a won position.
+				loss := dtm + 1
+				if g.floor[prev] > loss {
+					loss = g.floor[prev]
+				}
+				g.schedule(prev, loss)
+			}
+		}
+		g.levels[dtm] = nil
+	}
+}
+
+// Returns the distinct legal positions from which a move leads to idx.
+func (g *generator) predecessors(idx int) []int {
+	m := g.material
+	squares := make([]int, len(m.slots))
+	stm := m.decode(idx, squares)
+	bstate := m.board(squares, stm)
+	mover := 'b' // The player who made the last move.
+	if stm == 1 {
+		mover = 'w'
+	}
+
+	var result []int
+	prevSquares := make([]int, len(squares))
+	for i, sl := range m.slots {
+		if sl.color != mover {
+			continue
+		}
+		row, col := squares[i]/8, squares[i]%8
+		for _, from := range retroOrigins(sl.piece, mover, row, col, &bstate.Board) {
+			copy(prevSquares, squares)
+			prevSquares[i] = from[0]*8 + from[1]
+			prev := m.encode(prevSquares, 1-stm)
+			if g.values[prev] == valueIllegal || contains(result, prev) {
+				continue
+			}
+
+			// The move has to be legal under the rules of game_logic.
+			prevBstate := m.board(prevSquares, 1-stm)
+			move, err := game_logic.StringToMoveStruct(squareName(from[0], from[1])+" "+squareName(row, col), mover)
+			if err != nil || game_logic.ValidateMove(&move, &prevBstate) != nil {
+				continue
+			}
+			result = append(result, prev)
+		}
+	}
+	return result
+}
+
+// Returns the fields a piece standing on row, col can have come from
+// without capturing.
+func retroOrigins(piece rune, color rune, row, col int, board *[8][8]rune) [][2]int {
+	empty := func(r, c int) bool {
+		return r >= 0 && r < 8 && c >= 0 && c < 8 && board[r][c] == game_logic.Empty
+	}
+
+	var origins [][2]int
+	jump := func(offsets [][2]int) {
+		for _, o := range offsets {
+			if empty(row+o[0], col+o[1]) {
+				origins = append(origins, [2]int{row + o[0], col + o[1]})
+			}
+		}
+	}
+	slide := func(deltas [][2]int) {
+		for _, d := range deltas {
+			r, c := row+d[0], col+d[1]
+			for empty(r, c) {
+				origins = append(origins, [2]int{r, c})
+				r, c = r+d[0], c+d[1]
+			}
+		}
+	}
+
+	switch piece {
+	case 'x':
+		jump(kingOffsets)
+	case 'k':
+		jump(knightOffsets)
+	case 'b':
+		slide(diagonalDeltas)
+	case 'r':
+		slide(straightDeltas)
+	case 'q':
+		slide(diagonalDeltas)
+		slide(straightDeltas)
+	case 'p':
+		// White pawns move towards row 0, black pawns towards row 7.
+		back, doubleRow := 1, 4
+		if color == 'b' {
+			back, doubleRow = -1, 3
+		}
+		if empty(row+back, col) {
+			origins = append(origins, [2]int{row + back, col})
+			if row == doubleRow && empty(row+2*back, col) {
+				origins = append(origins, [2]int{row + 2*back, col})
+			}
+		}
+	}
+	return origins
+}
+
+func squareName(row, col int) string {
+	return string([]byte{byte('a' + col), byte('0' + 8 - row)})
+}
+
+func contains(list []int, value int) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
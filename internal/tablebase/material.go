@@ -0,0 +1,319 @@
+/*
+Material signatures and the mapping between board states
+and table indexes.
+*/
+
+package tablebase
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/game_logic"
+)
+
+// Order in which the non-king pieces of a side are listed.
+const pieceOrder = "QRBNP"
+
+// Maps the letters of a material signature to game_logic pieces.
+var letterToPiece = map[rune]rune{
+	'K': 'x',
+	'Q': 'q',
+	'R': 'r',
+	'B': 'b',
+	'N': 'k',
+	'P': 'p',
+}
+
+// A piece of the material, e.g. the white queen.
+type slot struct {
+	color rune // 'w' or 'b'
+	piece rune // lowercase game_logic piece
+}
+
+// Describes the pieces on the board for one table.
+type material struct {
+	name  string
+	slots []slot // white king, white pieces, black king, black pieces
+	pawns bool
+}
+
+// Parses a signature like "KQK", "KBNK" or "KRvKN".
+// The white pieces are listed first, both sides start with their king.
+func parseMaterial(signature string) (material, error) {
+	s := strings.ToUpper(strings.ReplaceAll(signature, "v", ""))
+	s = strings.ReplaceAll(s, "V", "")
+	if len(s) < 2 || s[0] != 'K' {
+		return material{}, fmt.Errorf("invalid material %q", signature)
+	}
+	second := strings.IndexByte(s[1:], 'K')
+	if second < 0 {
+		return material{}, fmt.Errorf("invalid material %q: missing black king", signature)
+	}
+	white, black := s[1:second+1], s[second+2:]
+
+	sortSide := func(side string) (string, error) {
+		pieces := []rune(side)
+		for _, p := range pieces {
+			if !strings.ContainsRune(pieceOrder, p) {
+				return "", fmt.Errorf("invalid piece %q in material %q", p, signature)
+			}
+		}
+		sort.Slice(pieces, func(i, j int) bool {
+			return strings.IndexRune(pieceOrder, pieces[i]) < strings.IndexRune(pieceOrder, pieces[j])
+		})
+		return string(pieces), nil
+	}
+	white, err := sortSide(white)
+	if err != nil {
+		return material{}, err
+	}
+	black, err = sortSide(black)
+	if err != nil {
+		return material{}, err
+	}
+
+	m := material{name: "K" + white + "K" + black}
+	m.slots = append(m.slots, slot{'w', 'x'})
+	for _, p := range white {
+		m.slots = append(m.slots, slot{'w', letterToPiece[p]})
+	}
+	m.slots = append(m.slots, slot{'b', 'x'})
+	for _, p := range black {
+		m.slots = append(m.slots, slot{'b', letterToPiece[p]})
+	}
+	for _, s := range m.slots {
+		if s.piece == 'p' {
+			m.pawns = true
+		}
+	}
+	if len(m.slots) > 4 {
+		return material{}, errors.New("tables are limited to four pieces")
+	}
+	return m, nil
+}
+
+// Returns the signature of the pieces on a board.
+func boardSignature(board *[8][8]rune) string {
+	var white, black []rune
+	for row := 0; row < 8; row++ {
+		for col := 0; col < 8; col++ {
+			p := board[row][col]
+			if p == game_logic.Empty || p == 'x' || p == 'X' {
+				continue
+			}
+			letter := pieceToLetter(p)
+			if p >= 'a' && p <= 'z' {
+				white = append(white, letter)
+			} else {
+				black = append(black, letter)
+			}
+		}
+	}
+	order := func(pieces []rune) string {
+		sort.Slice(pieces, func(i, j int) bool {
+			return strings.IndexRune(pieceOrder, pieces[i]) < strings.IndexRune(pieceOrder, pieces[j])
+		})
+		return string(pieces)
+	}
+	return "K" + order(white) + "K" + order(black)
+}
+
+func pieceToLetter(p rune) rune {
+	if p >= 'A' && p <= 'Z' {
+		p = p - 'A' + 'a'
+	}
+	for letter, piece := range letterToPiece {
+		if piece == p {
+			return letter
+		}
+	}
+	return '?'
+}
+
+// Returns the signature with the colors swapped.
+func flippedSignature(signature string) string {
+	second := strings.IndexByte(signature[1:], 'K') + 1
+	return signature[second:] + signature[:second]
+}
+
+// Returns the material left after the piece in slot i is captured.
+func (m material) without(i int) material {
+	var white, black string
+	for j, s := range m.slots {
+		if j == i || s.piece == 'x' {
+			continue
+		}
+		if s.color == 'w' {
+			white += string(pieceToLetter(s.piece))
+		} else {
+			black += string(pieceToLetter(s.piece))
+		}
+	}
+	sub, _ := parseMaterial("K" + white + "K" + black)
+	return sub
+}
+
+// Number of squares the white king is restricted to by symmetry.
+// Pawnless positions can be mirrored on both axes, positions
+// with pawns only from left to right.
+func (m material) kingSquares() int {
+	if m.pawns {
+		return 32
+	}
+	return 16
+}
+
+// Number of entries in a table for this material.
+func (m material) size() int {
+	size := 2 * m.kingSquares()
+	for i := 1; i < len(m.slots); i++ {
+		size *= 64
+	}
+	return size
+}
+
+// Maps squares (row*8 + col, in slot order) and the side to move
+// (0: white, 1: black) to the index of the symmetric canonical position.
+func (m material) encode(squares []int, stm int) int {
+	sq := make([]int, len(squares))
+	copy(sq, squares)
+
+	king := sq[0]
+	flipCol := king%8 > 3
+	flipRow := !m.pawns && king/8 > 3
+	for i := range sq {
+		row, col := sq[i]/8, sq[i]%8
+		if flipRow {
+			row = 7 - row
+		}
+		if flipCol {
+			col = 7 - col
+		}
+		sq[i] = row*8 + col
+	}
+
+	// Identical pieces are interchangeable, keep them ordered.
+	for start := 0; start < len(sq); {
+		end := start + 1
+		for end < len(sq) && m.slots[end] == m.slots[start] {
+			end++
+		}
+		sort.Ints(sq[start:end])
+		start = end
+	}
+
+	idx := stm*m.kingSquares() + (sq[0]/8)*4 + sq[0]%8
+	for _, s := range sq[1:] {
+		idx = idx*64 + s
+	}
+	return idx
+}
+
+// Reverses encode. Fills squares and returns the side to move.
+func (m material) decode(idx int, squares []int) int {
+	for i := len(m.slots) - 1; i > 0; i-- {
+		squares[i] = idx % 64
+		idx /= 64
+	}
+	king := idx % m.kingSquares()
+	squares[0] = (king/4)*8 + king%4
+	return idx / m.kingSquares()
+}
+
+// Builds the board state for the given squares and side to move.
+func (m material) board(squares []int, stm int) game_logic.BoardState {
+	var bstate game_logic.BoardState
+	for row := 0; row < 8; row++ {
+		for col := 0; col < 8; col++ {
+			bstate.Board[row][col] = game_logic.Empty
+		}
+	}
+	for i, s := range m.slots {
+		p := s.piece
+		if s.color == 'b' {
+			p = p - 'a' + 'A'
+		}
+		bstate.Board[squares[i]/8][squares[i]%8] = p
+		if s.piece == 'x' {
+			if s.color == 'w' {
+				bstate.WhiteKingPos = [2]int{squares[i] / 8, squares[i] % 8}
+			} else {
+				bstate.BlackKingPos = [2]int{squares[i] / 8, squares[i] % 8}
+			}
+		}
+	}
+	bstate.WhiteKingMoved = true
+	bstate.BlackKingMoved = true
+	bstate.EnPassant = [2]int{-1, -1}
+	bstate.Winner = "n"
+	bstate.TurnColor = "w"
+	if stm == 1 {
+		bstate.TurnColor = "b"
+	}
+	return bstate
+}
+
+// Extracts the squares of the pieces in slot order.
+// Returns false if the board does not hold exactly this material.
+func (m material) squaresOf(board *[8][8]rune, squares []int) bool {
+	used := make([]bool, len(m.slots))
+	for row := 0; row < 8; row++ {
+		for col := 0; col < 8; col++ {
+			p := board[row][col]
+			if p == game_logic.Empty {
+				continue
+			}
+			s := slot{'w', p}
+			if p >= 'A' && p <= 'Z' {
+				s = slot{'b', p - 'A' + 'a'}
+			}
+			found := false
+			for i := range m.slots {
+				if !used[i] && m.slots[i] == s {
+					used[i] = true
+					squares[i] = row*8 + col
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	for _, u := range used {
+		if !u {
+			return false
+		}
+	}
+	return true
+}
+
+// Mirrors the board vertically and swaps the colors of all pieces.
+func flipColors(bstate *game_logic.BoardState) game_logic.BoardState {
+	flipped := *bstate
+	for row := 0; row < 8; row++ {
+		for col := 0; col < 8; col++ {
+			p := bstate.Board[7-row][col]
+			switch {
+			case p >= 'a' && p <= 'z':
+				p = p - 'a' + 'A'
+			case p >= 'A' && p <= 'Z':
+				p = p - 'A' + 'a'
+			}
+			flipped.Board[row][col] = p
+		}
+	}
+	flipped.WhiteKingPos = [2]int{7 - bstate.BlackKingPos[0], bstate.BlackKingPos[1]}
+	flipped.BlackKingPos = [2]int{7 - bstate.WhiteKingPos[0], bstate.WhiteKingPos[1]}
+	switch bstate.TurnColor {
+	case "w":
+		flipped.TurnColor = "b"
+	case "b":
+		flipped.TurnColor = "w"
+	}
+	return flipped
+}
@@ -0,0 +1,296 @@
+/*
+Endgame tablebases for positions with up to four pieces.
+Tables are generated by retrograde analysis under the rules of
+the game_logic package and hold the distance to mate of every
+position, which allows the server to adjudicate games and to
+play decided endgames perfectly.
+*/
+
+package tablebase
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/game_logic"
+)
+
+// Result of a position from the view of the player to move.
+type WDL int8
+
+const (
+	Loss WDL = -1
+	Draw WDL = 0
+	Win  WDL = 1
+)
+
+func (wdl WDL) String() string {
+	switch wdl {
+	case Win:
+		return "win"
+	case Loss:
+		return "loss"
+	}
+	return "draw"
+}
+
+// Entry of a table.
+type Entry struct {
+	WDL WDL `json:"wdl"`
+	DTM int `json:"dtm"` // Plies to mate, 0 for draws and mated players.
+}
+
+/*
+Values are stored as one uint16 per position:
+
+	0: illegal or redundant position
+	1: draw
+	d+2: mate in d plies, won for the player to move if d is odd
+*/
+const (
+	valueIllegal uint16 = 0
+	valueDraw    uint16 = 1
+)
+
+func decodeValue(v uint16) (Entry, bool) {
+	switch v {
+	case valueIllegal:
+		return Entry{}, false
+	case valueDraw:
+		return Entry{WDL: Draw}, true
+	}
+	dtm := int(v - 2)
+	if dtm%2 == 1 {
+		return Entry{WDL: Win, DTM: dtm}, true
+	}
+	return Entry{WDL: Loss, DTM: dtm}, true
+}
+
+// Table holds the values of all positions of one material.
+type Table struct {
+	material material
+	values   []uint16
+}
+
+// Returns the material signature of the table, e.g. "KQK".
+func (t *Table) Name() string {
+	return t.material.name
+}
+
+// Looks up a board holding exactly the material of the table.
+func (t *Table) probe(bstate *game_logic.BoardState) (Entry, bool) {
+	squares := make([]int, len(t.material.slots))
+	if !t.material.squaresOf(&bstate.Board, squares) {
+		return Entry{}, false
+	}
+	stm := 0
+	if bstate.TurnColor == "b" {
+		stm = 1
+	}
+	return decodeValue(t.values[t.material.encode(squares, stm)])
+}
+
+// Set is a collection of tables that can be probed together.
+type Set struct {
+	tables map[string]*Table
+}
+
+func NewSet() *Set {
+	return &Set{tables: make(map[string]*Table)}
+}
+
+// Adds a table to the set, replacing a table of the same material.
+func (s *Set) Add(t *Table) {
+	s.tables[t.Name()] = t
+}
+
+// Returns the material signatures of all tables in the set.
+func (s *Set) Names() []string {
+	var names []string
+	for name := range s.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Returns the table of the given material or nil.
+func (s *Set) Table(signature string) *Table {
+	return s.tables[signature]
+}
+
+// Looks up a position. Returns false if no table covers it.
+func (s *Set) Probe(bstate *game_logic.BoardState) (Entry, bool) {
+	if bstate.TurnColor != "w" && bstate.TurnColor != "b" {
+		return Entry{}, false
+	}
+	signature := boardSignature(&bstate.Board)
+	if signature == "KK" {
+		return Entry{WDL: Draw}, true
+	}
+	if t, exists := s.tables[signature]; exists {
+		return t.probe(bstate)
+	}
+	if t, exists := s.tables[flippedSignature(signature)]; exists {
+		flipped := flipColors(bstate)
+		return t.probe(&flipped)
+	}
+	return Entry{}, false
+}
+
+// Returns the move that keeps the best result for the player to move:
+// the fastest mate when winning, a drawing move when drawn and the
+// longest resistance when losing.
+func (s *Set) BestMove(bstate *game_logic.BoardState) (game_logic.Move, Entry, bool) {
+	current, ok := s.Probe(bstate)
+	if !ok {
+		return game_logic.Move{}, Entry{}, false
+	}
+
+	var best game_logic.Move
+	found := false
+	bestScore := 0
+	for _, move := range game_logic.LegalMoves(bstate) {
+		next := game_logic.MakeMove(&move, *bstate)
+		entry, ok := s.Probe(&next)
+		if !ok {
+			continue
+		}
+		// Score from the view of the moving player, higher is better.
+		var score int
+		switch entry.WDL {
+		case Loss:
+			score = 100000 - entry.DTM
+		case Draw:
+			score = 0
+		case Win:
+			score = -100000 + entry.DTM
+		}
+		if !found || score > bestScore {
+			best, bestScore, found = move, score, true
+		}
+	}
+	return best, current, found
+}
+
+// Name of the file a table is stored in.
+func FileName(signature string) string {
+	return signature + ".tb"
+}
+
+/*
+File format, little endian:
+
+	"CSTB" | version uint8 | name length uint8 | name |
+	entry count uint32 | gzip compressed uint16 values
+*/
+var fileMagic = [4]byte{'C', 'S', 'T', 'B'}
+
+const fileVersion = 1
+
+// Writes the table to a file.
+func (t *Table) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	w.Write(fileMagic[:])
+	w.WriteByte(fileVersion)
+	w.WriteByte(byte(len(t.material.name)))
+	w.WriteString(t.material.name)
+	binary.Write(w, binary.LittleEndian, uint32(len(t.values)))
+
+	zw := gzip.NewWriter(w)
+	if err := binary.Write(zw, binary.LittleEndian, t.values); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// Reads a table written by Save.
+func Load(path string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+
+	var magic [4]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil || magic != fileMagic {
+		return nil, fmt.Errorf("%s: not a tablebase file", path)
+	}
+	version, err := r.ReadByte()
+	if err != nil || version != fileVersion {
+		return nil, fmt.Errorf("%s: unsupported version %d", path, version)
+	}
+	nameLen, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	name := make([]byte, nameLen)
+	if _, err := io.ReadFull(r, name); err != nil {
+		return nil, err
+	}
+	m, err := parseMaterial(string(name))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	var count uint32
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return nil, err
+	}
+	if int(count) != m.size() {
+		return nil, fmt.Errorf("%s: expected %d entries, found %d", path, m.size(), count)
+	}
+
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]uint16, count)
+	if err := binary.Read(zr, binary.LittleEndian, values); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &Table{material: m, values: values}, nil
+}
+
+// Loads all tables from a directory.
+func LoadDir(dir string) (*Set, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tb"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, errors.New("no tables found in " + dir)
+	}
+	set := NewSet()
+	for _, path := range paths {
+		t, err := Load(path)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSuffix(filepath.Base(path), ".tb") != t.Name() {
+			return nil, fmt.Errorf("%s: holds table %s", path, t.Name())
+		}
+		set.Add(t)
+	}
+	return set, nil
+}
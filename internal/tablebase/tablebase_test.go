@@ -0,0 +1,149 @@
+/*
+Unittest for the tablebase package.
+*/
+package tablebase
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/game_logic"
+)
+
+func TestParseMaterial(t *testing.T) {
+	m, err := parseMaterial("KNBvK")
+	if err != nil {
+		t.Fatalf("fail in parseMaterial: %s", err)
+	}
+	if m.name != "KBNK" {
+		t.Errorf("name should be KBNK, got %s", m.name)
+	}
+	if len(m.slots) != 4 || m.pawns {
+		t.Errorf("unexpected slots %v", m.slots)
+	}
+	if _, err := parseMaterial("KQRKR"); err == nil {
+		t.Errorf("five pieces should be rejected")
+	}
+	if flippedSignature("KRKN") != "KNKR" {
+		t.Errorf("flipped signature should be KNKR")
+	}
+}
+
+func TestEncodeSymmetry(t *testing.T) {
+	m, _ := parseMaterial("KQK")
+	// White king on h8 with queen on g2 mirrors to a1 with b7.
+	a := m.encode([]int{7, 54, 40}, 0)
+	b := m.encode([]int{56, 9, 23}, 0)
+	if a != b {
+		t.Errorf("mirrored positions should share an index: %d != %d", a, b)
+	}
+	squares := make([]int, 3)
+	if stm := m.decode(a, squares); stm != 0 || m.encode(squares, stm) != a {
+		t.Errorf("decode doesn't reverse encode")
+	}
+}
+
+func TestGenerateKQK(t *testing.T) {
+	if testing.Short() {
+		t.Skip("generation takes a few seconds")
+	}
+	set := NewSet()
+	table, err := set.Generate("KQK")
+	if err != nil {
+		t.Fatalf("fail in Generate: %s", err)
+	}
+
+	// The longest win takes 10 moves.
+	longest := 0
+	for _, v := range table.values {
+		if entry, ok := decodeValue(v); ok && entry.WDL == Win && entry.DTM > longest {
+			longest = entry.DTM
+		}
+	}
+	if longest != 19 {
+		t.Errorf("longest win should take 19 plies, got %d", longest)
+	}
+
+	cases := []struct {
+		board string
+		turn  string
+		want  Entry
+	}{
+		// Mate in one with Qh8.
+		{"X.......|........|.x......|........|........|........|.......q|........", "w", Entry{Win, 1}},
+		// Black is mated.
+		{"X.......|.q......|.x......|........|........|........|........|........", "b", Entry{Loss, 0}},
+		// Black is stalemated.
+		{"X.......|..q.....|.x......|........|........|........|........|........", "b", Entry{Draw, 0}},
+		// Black captures the queen.
+		{"X.......|.q......|........|........|........|........|........|.......x", "b", Entry{Draw, 0}},
+	}
+	for _, c := range cases {
+		bstate := boardFromString(c.board, c.turn)
+		got, ok := set.Probe(&bstate)
+		if !ok {
+			t.Errorf("position not found:\n%s", c.board)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("expected %+v, got %+v for\n%s", c.want, got, c.board)
+		}
+	}
+
+	// The same material with colors swapped is found as well.
+	bstate := boardFromString("........|.......Q|........|........|........|.X......|........|x.......", "b")
+	if got, ok := set.Probe(&bstate); !ok || got != (Entry{Win, 1}) {
+		t.Errorf("flipped position should be won, got %+v", got)
+	}
+
+	bstate = boardFromString("X.......|........|.x......|........|........|........|.......q|........", "w")
+	move, _, ok := set.BestMove(&bstate)
+	if !ok {
+		t.Fatalf("no best move found")
+	}
+	next := game_logic.MakeMove(&move, bstate)
+	if winner, _ := game_logic.GameEnd(&next); winner != "w" {
+		t.Errorf("best move %s should mate", move)
+	}
+
+	path := filepath.Join(t.TempDir(), FileName(table.Name()))
+	if err := table.Save(path); err != nil {
+		t.Fatalf("fail in Save: %s", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("fail in Load: %s", err)
+	}
+	if loaded.Name() != "KQK" || len(loaded.values) != len(table.values) {
+		t.Fatalf("loaded table doesn't match")
+	}
+	for i := range table.values {
+		if loaded.values[i] != table.values[i] {
+			t.Fatalf("value %d differs after loading", i)
+		}
+	}
+}
+
+// Builds a board from rows separated by '|', '.' marks empty fields.
+func boardFromString(rows string, turn string) game_logic.BoardState {
+	var bstate game_logic.BoardState
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			p := rune(rows[i*9+j])
+			if p == '.' {
+				p = game_logic.Empty
+			}
+			bstate.Board[i][j] = p
+			if p == 'x' {
+				bstate.WhiteKingPos = [2]int{i, j}
+			}
+			if p == 'X' {
+				bstate.BlackKingPos = [2]int{i, j}
+			}
+		}
+	}
+	bstate.EnPassant = [2]int{-1, -1}
+	bstate.Winner = "n"
+	bstate.TurnColor = turn
+	return bstate
+}
@@ -0,0 +1,182 @@
+/*
+Knockout brackets. Players meet in matches of a few games, the winner
+advances to the next round. A tied match continues with tiebreak
+games and is decided by an armageddon game, in which black advances
+with a draw.
+*/
+package tournament
+
+// Format of knockout tournaments.
+const Knockout = "knockout"
+
+// Kinds of games of a match.
+const (
+	GameRegular    = ""
+	GameTiebreak   = "tiebreak"
+	GameArmageddon = "armageddon"
+)
+
+// Games of a match.
+type MatchConfig struct {
+	Games         int // Regular games, at least 1.
+	TiebreakGames int // Played if the regular games are tied.
+}
+
+// Match of a knockout round. A is the higher seed, B is 0 if A has a
+// bye. In rounds that aren't paired yet, unknown players are 0 too.
+type Match struct {
+	A       int32
+	B       int32
+	Results []Result // Finished games in the order they were played.
+}
+
+// Game of a match to be played.
+type MatchGame struct {
+	White int32
+	Black int32
+	Kind  string
+}
+
+// Returns the number of rounds of a knockout tournament between n
+// players.
+func KnockoutRounds(n int) int {
+	rounds := 0
+	for size := 1; size < n; size *= 2 {
+		rounds++
+	}
+	return rounds
+}
+
+// Returns the seeds of a bracket of size players, a power of 2, in
+// the order they are paired: 1 plays size, 2 plays size-1 and so on,
+// and 1 and 2 can only meet in the final.
+func Seeds(size int) []int {
+	seeds := []int{1}
+	for n := 2; n <= size; n *= 2 {
+		next := make([]int, 0, n)
+		for _, seed := range seeds {
+			next = append(next, seed, n+1-seed)
+		}
+		seeds = next
+	}
+	return seeds
+}
+
+// Returns the matches of the first round. players are in the order
+// of their seeds, the best seeds get the byes.
+func FirstRound(players []int32) []Match {
+	size := 1 << KnockoutRounds(len(players))
+	seeds := Seeds(size)
+	player := func(seed int) int32 {
+		if seed > len(players) {
+			return 0
+		}
+		return players[seed-1]
+	}
+	var matches []Match
+	for i := 0; i < len(seeds); i += 2 {
+		matches = append(matches, Match{A: player(seeds[i]), B: player(seeds[i+1])})
+	}
+	return matches
+}
+
+// Returns the matches between the winners of the previous round, the
+// winner of the upper match being A.
+func NextRound(previous []Match, config MatchConfig) []Match {
+	var matches []Match
+	for i := 0; i+1 < len(previous); i += 2 {
+		matches = append(matches, Match{
+			A: previous[i].Winner(config),
+			B: previous[i+1].Winner(config),
+		})
+	}
+	return matches
+}
+
+// Returns the points of both players in the games of a kind.
+func (m Match) Score(kind string) (float64, float64, int) {
+	var a, b float64
+	games := 0
+	for _, result := range m.Results {
+		if result.Kind != kind {
+			continue
+		}
+		games++
+		if result.White == m.A {
+			a, b = a+result.WhiteScore, b+result.BlackScore
+		} else {
+			a, b = a+result.BlackScore, b+result.WhiteScore
+		}
+	}
+	return a, b, games
+}
+
+// Returns the winner, 0 while the match is undecided.
+func (m Match) Winner(config MatchConfig) int32 {
+	_, winner := m.next(config)
+	return winner
+}
+
+// Returns the next game of the match, false once it is decided.
+func (m Match) Next(config MatchConfig) (MatchGame, bool) {
+	game, winner := m.next(config)
+	if winner != 0 || game == nil {
+		return MatchGame{}, false
+	}
+	return *game, true
+}
+
+func (m Match) next(config MatchConfig) (*MatchGame, int32) {
+	if m.A == 0 || m.B == 0 {
+		return nil, m.A + m.B
+	}
+	// Colours alternate over the regular and the tiebreak games.
+	alternate := func(played int, kind string) *MatchGame {
+		if played%2 == 0 {
+			return &MatchGame{White: m.A, Black: m.B, Kind: kind}
+		}
+		return &MatchGame{White: m.B, Black: m.A, Kind: kind}
+	}
+	leader := func(a float64, b float64) int32 {
+		switch {
+		case a > b:
+			return m.A
+		case b > a:
+			return m.B
+		}
+		return 0
+	}
+
+	a, b, regular := m.Score(GameRegular)
+	if regular < config.Games {
+		// Decided early if the trailing player can't catch up.
+		if remaining := float64(config.Games - regular); a-b > remaining || b-a > remaining {
+			return nil, leader(a, b)
+		}
+		return alternate(regular, GameRegular), 0
+	}
+	if winner := leader(a, b); winner != 0 {
+		return nil, winner
+	}
+
+	a, b, tiebreaks := m.Score(GameTiebreak)
+	if tiebreaks < config.TiebreakGames {
+		return alternate(regular+tiebreaks, GameTiebreak), 0
+	}
+	if winner := leader(a, b); winner != 0 {
+		return nil, winner
+	}
+
+	for _, result := range m.Results {
+		if result.Kind == GameArmageddon {
+			// White has to win, black advances otherwise.
+			if result.WhiteScore == 1 {
+				return nil, result.White
+			}
+			return nil, result.Black
+		}
+	}
+	// The player who had black in the last game gets white.
+	last := m.Results[len(m.Results)-1]
+	return &MatchGame{White: last.Black, Black: last.White, Kind: GameArmageddon}, 0
+}
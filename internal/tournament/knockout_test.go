@@ -0,0 +1,82 @@
+/*
+Unittest for knockout brackets.
+*/
+package tournament
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSeeds(t *testing.T) {
+	if seeds := fmt.Sprint(Seeds(8)); seeds != "[1 8 4 5 2 7 3 6]" {
+		t.Errorf("unexpected bracket order %s", seeds)
+	}
+	matches := FirstRound(players(5))
+	if fmt.Sprint(matches) != "[{1 0 []} {4 5 []} {2 0 []} {3 0 []}]" {
+		t.Errorf("expected byes for the top 3 seeds, got %+v", matches)
+	}
+	if KnockoutRounds(5) != 3 || KnockoutRounds(4) != 2 || KnockoutRounds(2) != 1 {
+		t.Errorf("unexpected number of rounds")
+	}
+}
+
+// Plays the next game of m with the given result for white.
+func play(t *testing.T, m *Match, config MatchConfig, whiteScore float64) MatchGame {
+	game, ok := m.Next(config)
+	if !ok {
+		t.Fatalf("expected another game after %+v", m.Results)
+	}
+	m.Results = append(m.Results, Result{White: game.White, Black: game.Black, Kind: game.Kind, WhiteScore: whiteScore, BlackScore: 1 - whiteScore})
+	return game
+}
+
+func TestMatch(t *testing.T) {
+	config := MatchConfig{Games: 2, TiebreakGames: 2}
+
+	// Decided in the regular games.
+	m := Match{A: 1, B: 2}
+	if game := play(t, &m, config, 1); game.White != 1 {
+		t.Errorf("expected the higher seed to start with white")
+	}
+	if game := play(t, &m, config, 0.5); game.White != 2 {
+		t.Errorf("expected colours to alternate")
+	}
+	if m.Winner(config) != 1 {
+		t.Errorf("expected 1 to win 1.5-0.5")
+	}
+
+	// Decided early.
+	m = Match{A: 1, B: 2}
+	config3 := MatchConfig{Games: 3}
+	play(t, &m, config3, 0)
+	play(t, &m, config3, 1)
+	if m.Winner(config3) != 2 {
+		t.Errorf("expected 2 to win after leading 2-0, got %+v", m.Results)
+	}
+
+	// Tied until the armageddon game.
+	m = Match{A: 1, B: 2}
+	for i := 0; i < 4; i++ {
+		game := play(t, &m, config, 0.5)
+		if kind := []string{GameRegular, GameRegular, GameTiebreak, GameTiebreak}[i]; game.Kind != kind {
+			t.Errorf("game %d: expected kind %q, got %q", i+1, kind, game.Kind)
+		}
+	}
+	game := play(t, &m, config, 0.5)
+	if game.Kind != GameArmageddon || game.White != 1 {
+		t.Errorf("expected an armageddon game with 1 as white, got %+v", game)
+	}
+	if m.Winner(config) != 2 {
+		t.Errorf("expected black to advance with a draw")
+	}
+
+	// Byes are won right away.
+	if (Match{A: 3}).Winner(config) != 3 {
+		t.Errorf("expected a bye to be won")
+	}
+	previous := []Match{{A: 3}, {A: 1, B: 2, Results: m.Results}}
+	if next := NextRound(previous, config); fmt.Sprint(next) != "[{3 2 []}]" {
+		t.Errorf("expected the winners to meet, got %+v", next)
+	}
+}
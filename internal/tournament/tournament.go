@@ -0,0 +1,366 @@
+/*
+Pairings and standings of tournaments between accounts, identified by
+their IDs. Round-robin tournaments play a fixed schedule, Swiss
+tournaments pair each round by the results so far.
+*/
+package tournament
+
+import (
+	"errors"
+	"sort"
+)
+
+// Formats of tournaments, see knockout.go for Knockout.
+const (
+	RoundRobin       = "roundrobin"
+	DoubleRoundRobin = "doubleroundrobin" // Everyone plays everyone with both colours.
+	Swiss            = "swiss"
+)
+
+// Tie-breaks of standings.
+const (
+	SonnebornBerger = "sonnebornberger" // Sum of the points of the beaten opponents, half of the drawn ones.
+	Buchholz        = "buchholz"        // Sum of the points of all opponents.
+)
+
+// Score of a bye.
+const ByeScore = 1
+
+var ErrTooFewPlayers = errors.New("a tournament needs at least 2 players")
+
+func ValidFormat(format string) bool {
+	switch format {
+	case RoundRobin, DoubleRoundRobin, Swiss, Knockout:
+		return true
+	}
+	return false
+}
+
+// Returns the tie-breaks of a format in the order they are applied.
+func TieBreaks(format string) []string {
+	if format == Swiss {
+		return []string{Buchholz, SonnebornBerger}
+	}
+	return []string{SonnebornBerger, Buchholz}
+}
+
+// Game of a round. Black is 0 if White has a bye.
+type Pairing struct {
+	White int32
+	Black int32
+}
+
+func (p Pairing) Bye() bool {
+	return p.Black == 0
+}
+
+// Finished game of a tournament, byes included.
+type Result struct {
+	Round      int
+	Match      int    // Knockout only, index of the match in the round.
+	Kind       string // Knockout only, GameRegular, GameTiebreak or GameArmageddon.
+	White      int32
+	Black      int32
+	WhiteScore float64
+	BlackScore float64 // Both scores are 0 if the game was aborted.
+}
+
+// Returns the number of rounds a round-robin tournament between n
+// players takes.
+func RoundRobinRounds(n int, double bool) int {
+	rounds := n - 1
+	if n%2 == 1 {
+		rounds = n
+	}
+	if double {
+		rounds *= 2
+	}
+	return rounds
+}
+
+// Returns all rounds of a round-robin tournament. With an odd number
+// of players, everyone has a bye once per cycle. Each player has at
+// most one white game more than black games per cycle; a double
+// round robin repeats the cycle with reversed colours.
+func RoundRobinSchedule(players []int32, double bool) ([][]Pairing, error) {
+	if len(players) < 2 {
+		return nil, ErrTooFewPlayers
+	}
+	seats := append([]int32(nil), players...)
+	if len(seats)%2 == 1 {
+		seats = append(seats, 0) // The bye.
+	}
+	// Circle method: the seats 0 to n-2 play x against y when
+	// x + y = round (mod n-1), the remaining one plays the last seat.
+	n := len(seats)
+	var rounds [][]Pairing
+	for r := 0; r < n-1; r++ {
+		var round []Pairing
+		for x := 0; x < n-1; x++ {
+			y := ((r-x)%(n-1) + (n - 1)) % (n - 1)
+			switch {
+			case y == x:
+				// Plays the last seat, alternating colours.
+				if r%2 == 0 {
+					round = append(round, pairing(seats[x], seats[n-1]))
+				} else {
+					round = append(round, pairing(seats[n-1], seats[x]))
+				}
+			case x < y:
+				if (y-x)%2 == 1 {
+					round = append(round, pairing(seats[x], seats[y]))
+				} else {
+					round = append(round, pairing(seats[y], seats[x]))
+				}
+			}
+		}
+		rounds = append(rounds, round)
+	}
+	if double {
+		for _, round := range rounds[:n-1] {
+			var reversed []Pairing
+			for _, p := range round {
+				if p.Bye() {
+					reversed = append(reversed, p)
+				} else {
+					reversed = append(reversed, Pairing{White: p.Black, Black: p.White})
+				}
+			}
+			rounds = append(rounds, reversed)
+		}
+	}
+	return rounds, nil
+}
+
+// Returns the pairing of two seats, the bye seat being 0.
+func pairing(white int32, black int32) Pairing {
+	if white == 0 {
+		return Pairing{White: black}
+	}
+	return Pairing{White: white, Black: black}
+}
+
+// Record of a player over the results so far.
+type record struct {
+	points    float64
+	opponents map[int32]bool
+	bye       bool
+	colors    int    // White games minus black games.
+	last      string // Colour of the last game, "w" or "b".
+}
+
+func records(players []int32, results []Result) map[int32]*record {
+	recs := make(map[int32]*record, len(players))
+	for _, player := range players {
+		recs[player] = &record{opponents: make(map[int32]bool)}
+	}
+	sorted := append([]Result(nil), results...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Round < sorted[j].Round })
+	for _, result := range sorted {
+		white, black := recs[result.White], recs[result.Black]
+		if white == nil {
+			continue
+		}
+		white.points += result.WhiteScore
+		if result.Black == 0 {
+			white.bye = true
+			continue
+		}
+		if black == nil {
+			continue
+		}
+		black.points += result.BlackScore
+		white.opponents[result.Black] = true
+		black.opponents[result.White] = true
+		white.colors++
+		black.colors--
+		white.last, black.last = "w", "b"
+	}
+	return recs
+}
+
+// Returns the pairings of the next round of a Swiss tournament.
+// Players are ranked by points and then by their order in players,
+// and the highest ranked player meets the next one not met yet, like
+// in the Monrad system. Rematches are only allowed if there is no
+// other way. With an odd number of players, the lowest ranked player
+// without a bye gets one.
+func SwissPairings(players []int32, results []Result) ([]Pairing, error) {
+	if len(players) < 2 {
+		return nil, ErrTooFewPlayers
+	}
+	recs := records(players, results)
+	ranked := append([]int32(nil), players...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return recs[ranked[i]].points > recs[ranked[j]].points
+	})
+
+	for _, rematches := range []bool{false, true} {
+		if pairings, ok := pairRanked(ranked, recs, rematches); ok {
+			return pairings, nil
+		}
+	}
+	// Unreachable, with rematches everyone can be paired.
+	return nil, errors.New("no pairing found")
+}
+
+func pairRanked(ranked []int32, recs map[int32]*record, rematches bool) ([]Pairing, bool) {
+	if len(ranked)%2 == 0 {
+		return pairRest(ranked, recs, rematches)
+	}
+	// Players who had a bye only get another one if nobody else can.
+	for _, secondBye := range []bool{false, true} {
+		for i := len(ranked) - 1; i >= 0; i-- {
+			if recs[ranked[i]].bye != secondBye {
+				continue
+			}
+			rest := append(append([]int32(nil), ranked[:i]...), ranked[i+1:]...)
+			if pairings, ok := pairRest(rest, recs, rematches); ok {
+				return append(pairings, Pairing{White: ranked[i]}), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Pairs the highest ranked player with the first possible opponent,
+// backtracking if the rest can't be paired.
+func pairRest(ranked []int32, recs map[int32]*record, rematches bool) ([]Pairing, bool) {
+	if len(ranked) == 0 {
+		return nil, true
+	}
+	top := ranked[0]
+	for i := 1; i < len(ranked); i++ {
+		opponent := ranked[i]
+		if !rematches && recs[top].opponents[opponent] {
+			continue
+		}
+		rest := append(append([]int32(nil), ranked[1:i]...), ranked[i+1:]...)
+		if pairings, ok := pairRest(rest, recs, rematches); ok {
+			return append([]Pairing{colors(top, opponent, recs)}, pairings...), true
+		}
+	}
+	return nil, false
+}
+
+// Gives white to the player who had it less often, then to the one
+// who had black last. Otherwise the higher ranked player a gets white.
+func colors(a int32, b int32, recs map[int32]*record) Pairing {
+	ra, rb := recs[a], recs[b]
+	switch {
+	case ra.colors < rb.colors:
+		return Pairing{White: a, Black: b}
+	case ra.colors > rb.colors:
+		return Pairing{White: b, Black: a}
+	case ra.last == "w" && rb.last != "w":
+		return Pairing{White: b, Black: a}
+	}
+	return Pairing{White: a, Black: b}
+}
+
+type Standing struct {
+	Rank            int // Shared by players with equal points and tie-breaks.
+	Player          int32
+	Points          float64
+	Games           int // Byes not included.
+	Wins            int
+	Draws           int
+	Losses          int
+	Byes            int
+	SonnebornBerger float64
+	Buchholz        float64
+}
+
+func (s Standing) tieBreak(name string) float64 {
+	if name == SonnebornBerger {
+		return s.SonnebornBerger
+	}
+	return s.Buchholz
+}
+
+// Returns the standings of players, best first. Ties in points are
+// broken by tieBreaks in order, then players keep their order in
+// players.
+func Standings(players []int32, results []Result, tieBreaks []string) []Standing {
+	byPlayer := make(map[int32]*Standing, len(players))
+	for _, player := range players {
+		byPlayer[player] = &Standing{Player: player}
+	}
+	add := func(player int32, score float64, opponent int32, opponentScore float64) {
+		s := byPlayer[player]
+		if s == nil {
+			return
+		}
+		s.Points += score
+		switch {
+		case opponent == 0:
+			s.Byes++
+			return
+		case score > opponentScore:
+			s.Wins++
+		case score == opponentScore && score > 0:
+			s.Draws++
+		default:
+			s.Losses++
+		}
+		s.Games++
+	}
+	for _, result := range results {
+		add(result.White, result.WhiteScore, result.Black, result.BlackScore)
+		add(result.Black, result.BlackScore, result.White, result.WhiteScore)
+	}
+	points := func(player int32) float64 {
+		if s := byPlayer[player]; s != nil {
+			return s.Points
+		}
+		return 0
+	}
+	for _, result := range results {
+		if result.Black == 0 {
+			continue
+		}
+		if s := byPlayer[result.White]; s != nil {
+			s.Buchholz += points(result.Black)
+			s.SonnebornBerger += result.WhiteScore * points(result.Black)
+		}
+		if s := byPlayer[result.Black]; s != nil {
+			s.Buchholz += points(result.White)
+			s.SonnebornBerger += result.BlackScore * points(result.White)
+		}
+	}
+
+	standings := make([]Standing, 0, len(players))
+	for _, player := range players {
+		standings = append(standings, *byPlayer[player])
+	}
+	order := func(a Standing, b Standing) int {
+		if a.Points != b.Points {
+			return compare(a.Points, b.Points)
+		}
+		for _, name := range tieBreaks {
+			if a.tieBreak(name) != b.tieBreak(name) {
+				return compare(a.tieBreak(name), b.tieBreak(name))
+			}
+		}
+		return 0
+	}
+	sort.SliceStable(standings, func(i, j int) bool {
+		return order(standings[i], standings[j]) < 0
+	})
+	for i := range standings {
+		if i > 0 && order(standings[i-1], standings[i]) == 0 {
+			standings[i].Rank = standings[i-1].Rank
+		} else {
+			standings[i].Rank = i + 1
+		}
+	}
+	return standings
+}
+
+// Orders higher values first.
+func compare(a float64, b float64) int {
+	if a > b {
+		return -1
+	}
+	return 1
+}
@@ -0,0 +1,141 @@
+/*
+Unittest for pairings and standings.
+*/
+package tournament
+
+import (
+	"testing"
+)
+
+func players(n int) []int32 {
+	var ids []int32
+	for i := 1; i <= n; i++ {
+		ids = append(ids, int32(i))
+	}
+	return ids
+}
+
+func TestRoundRobinSchedule(t *testing.T) {
+	for n := 2; n <= 9; n++ {
+		rounds, err := RoundRobinSchedule(players(n), false)
+		if err != nil {
+			t.Fatalf("fail in RoundRobinSchedule: %s", err)
+		}
+		if len(rounds) != RoundRobinRounds(n, false) {
+			t.Errorf("%d players: expected %d rounds, got %d", n, RoundRobinRounds(n, false), len(rounds))
+		}
+		met := make(map[[2]int32]int)
+		colors := make(map[int32]int)
+		byes := make(map[int32]int)
+		for _, round := range rounds {
+			seen := make(map[int32]bool)
+			for _, p := range round {
+				if seen[p.White] || seen[p.Black] {
+					t.Errorf("%d players: %+v plays twice in a round", n, p)
+				}
+				seen[p.White], seen[p.Black] = true, true
+				if p.Bye() {
+					byes[p.White]++
+					continue
+				}
+				a, b := p.White, p.Black
+				if a > b {
+					a, b = b, a
+				}
+				met[[2]int32{a, b}]++
+				colors[p.White]++
+				colors[p.Black]--
+			}
+		}
+		if len(met) != n*(n-1)/2 {
+			t.Errorf("%d players: expected every pair to meet, got %d pairs", n, len(met))
+		}
+		for player, balance := range colors {
+			if balance < -1 || balance > 1 {
+				t.Errorf("%d players: player %d has colour balance %d", n, player, balance)
+			}
+		}
+		for player, count := range byes {
+			if n%2 == 0 || count != 1 {
+				t.Errorf("%d players: player %d has %d byes", n, player, count)
+			}
+		}
+	}
+
+	rounds, _ := RoundRobinSchedule(players(4), true)
+	if len(rounds) != 6 || rounds[3][0].White != rounds[0][0].Black {
+		t.Errorf("expected the second cycle with reversed colours, got %+v", rounds)
+	}
+}
+
+func TestSwissPairings(t *testing.T) {
+	ids := players(5)
+	var results []Result
+	byes := make(map[int32]bool)
+	for round := 1; round <= 4; round++ {
+		pairings, err := SwissPairings(ids, results)
+		if err != nil {
+			t.Fatalf("fail in SwissPairings: %s", err)
+		}
+		if len(pairings) != 3 {
+			t.Fatalf("expected 2 games and a bye, got %+v", pairings)
+		}
+		for _, p := range pairings {
+			if p.Bye() {
+				if byes[p.White] {
+					t.Errorf("round %d: player %d gets a second bye", round, p.White)
+				}
+				byes[p.White] = true
+				results = append(results, Result{Round: round, White: p.White, WhiteScore: ByeScore})
+				continue
+			}
+			for _, r := range results {
+				if r.White == p.White && r.Black == p.Black || r.White == p.Black && r.Black == p.White {
+					t.Errorf("round %d: rematch %+v", round, p)
+				}
+			}
+			// The lower ID always wins.
+			if p.White < p.Black {
+				results = append(results, Result{Round: round, White: p.White, Black: p.Black, WhiteScore: 1})
+			} else {
+				results = append(results, Result{Round: round, White: p.White, Black: p.Black, BlackScore: 1})
+			}
+		}
+	}
+
+	// The leaders meet first.
+	pairings, _ := SwissPairings(players(4), []Result{
+		{Round: 1, White: 1, Black: 2, WhiteScore: 1},
+		{Round: 1, White: 3, Black: 4, BlackScore: 1},
+	})
+	if pairings[0] != (Pairing{White: 4, Black: 1}) {
+		t.Errorf("expected 4 to meet 1 with white, got %+v", pairings)
+	}
+}
+
+func TestStandings(t *testing.T) {
+	results := []Result{
+		{Round: 1, White: 1, Black: 2, WhiteScore: 1},
+		{Round: 1, White: 3, Black: 4, WhiteScore: 0.5, BlackScore: 0.5},
+		{Round: 2, White: 2, Black: 3, WhiteScore: 1},
+		{Round: 2, White: 4, Black: 1, BlackScore: 1},
+		{Round: 3, White: 1, Black: 3, BlackScore: 1},
+		{Round: 3, White: 2, Black: 4, WhiteScore: 1},
+	}
+	standings := Standings(players(4), results, TieBreaks(RoundRobin))
+	// 1 and 2 have 2 points, 1 beat 2 and 4, 2 beat 3 and 4.
+	if standings[0].Player != 1 || standings[0].Points != 2 || standings[0].SonnebornBerger != 2.5 {
+		t.Errorf("expected 1 to lead by Sonneborn-Berger, got %+v", standings[0])
+	}
+	if standings[1].Player != 2 || standings[1].SonnebornBerger != 2 || standings[1].Rank != 2 {
+		t.Errorf("expected 2 in second place, got %+v", standings[1])
+	}
+	if standings[3].Player != 4 || standings[3].Draws != 1 || standings[3].Losses != 2 || standings[3].Buchholz != 5.5 {
+		t.Errorf("expected 4 last with a draw, got %+v", standings[3])
+	}
+
+	standings = Standings(players(3), []Result{{Round: 1, White: 3, WhiteScore: ByeScore}}, TieBreaks(Swiss))
+	if standings[0].Player != 3 || standings[0].Byes != 1 || standings[0].Games != 0 || standings[1].Rank != 2 || standings[2].Rank != 2 {
+		t.Errorf("expected the bye to count, got %+v", standings)
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Unittest for the websocket package.
+*/
+package websocket
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Echoes every message back to the client.
+func echoHandler(w http.ResponseWriter, r *http.Request) {
+	conn, err := Upgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	for {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if err := conn.WriteMessage(data); err != nil {
+			return
+		}
+	}
+}
+
+func TestEcho(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("fail in Dial: %s", err)
+	}
+	defer conn.Close()
+
+	messages := []string{"hello", strings.Repeat("x", 200), strings.Repeat("y", 70000)}
+	for _, msg := range messages {
+		if err := conn.WriteMessage([]byte(msg)); err != nil {
+			t.Fatalf("fail in WriteMessage: %s", err)
+		}
+		if err := conn.Ping(); err != nil {
+			t.Fatalf("fail in Ping: %s", err)
+		}
+		data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("fail in ReadMessage: %s", err)
+		}
+		if string(data) != msg {
+			t.Errorf("expected a message of length %d, got %d", len(msg), len(data))
+		}
+	}
+
+	var v struct {
+		Move string `json:"move"`
+	}
+	if err := conn.WriteJSON(map[string]string{"move": "e2 e4"}); err != nil {
+		t.Fatalf("fail in WriteJSON: %s", err)
+	}
+	if err := conn.ReadJSON(&v); err != nil || v.Move != "e2 e4" {
+		t.Errorf("expected move e2 e4, got %q (%v)", v.Move, err)
+	}
+}
+
+func TestCloseEndsRead(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		conn.Close()
+	}))
+	defer server.Close()
+
+	conn, err := Dial(context.Background(), "ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("fail in Dial: %s", err)
+	}
+	if _, err := conn.ReadMessage(); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestRejectPlainRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("fail in Get: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUpgradeRequired {
+		t.Errorf("expected status 426, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,123 @@
+/*
+Framework for bots. A bot only chooses moves, the Runner joins the
+session, waits for its turns, submits the moves, reconnects after
+network errors and stops when the game ends:
+
+	type first struct{}
+
+	func (first) ChooseMove(ctx context.Context, pos bot.Position, clock bot.Clock) (bot.Move, error) {
+		return pos.LegalMoves()[0], nil
+	}
+
+	runner := bot.Runner{Client: client.New("http://localhost:8080"), Bot: first{}}
+	state, err := runner.Join(ctx, boardID, password, "w")
+*/
+package bot
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/game_logic"
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/tablebase"
+	"github.com/matetirpak/chess-server-and-api-for-developers/pkg/client"
+)
+
+// Move in the notation of the API, like "e2 e4".
+type Move string
+
+type Bot interface {
+	ChooseMove(ctx context.Context, pos Position, clock Clock) (Move, error)
+}
+
+// Adapts a function to the Bot interface.
+type Func func(ctx context.Context, pos Position, clock Clock) (Move, error)
+
+func (f Func) ChooseMove(ctx context.Context, pos Position, clock Clock) (Move, error) {
+	return f(ctx, pos, clock)
+}
+
+// Position of a game when it is the bot's turn.
+type Position struct {
+	client.State
+	BoardID int32
+	Color   string // Color of the bot, "w" or "b".
+}
+
+// Returns the moves the bot is allowed to make.
+func (pos Position) LegalMoves() []Move {
+	bstate := pos.boardState()
+	var moves []Move
+	for _, move := range game_logic.LegalMoves(&bstate) {
+		moves = append(moves, Move(move.String()))
+	}
+	return moves
+}
+
+func (pos Position) boardState() game_logic.BoardState {
+	return game_logic.BoardState{
+		Board:          pos.Board,
+		WhiteKingPos:   pos.WhiteKingPos,
+		BlackKingPos:   pos.BlackKingPos,
+		WhiteKingMoved: pos.WhiteKingMoved,
+		BlackKingMoved: pos.BlackKingMoved,
+		Winner:         pos.Winner,
+		TurnColor:      pos.TurnColor,
+		EnPassant:      pos.EnPassant,
+	}
+}
+
+// Time of the bot and its opponent when the turn started. Timed is
+// false for games without clocks.
+type Clock struct {
+	Timed     bool
+	Own       time.Duration
+	Opponent  time.Duration
+	Increment time.Duration
+	Delay     time.Duration
+}
+
+func newClock(clock *client.Clock, color string) Clock {
+	if clock == nil {
+		return Clock{}
+	}
+	own, opponent := clock.WhiteMs, clock.BlackMs
+	if color == "b" {
+		own, opponent = opponent, own
+	}
+	return Clock{
+		Timed:     true,
+		Own:       time.Duration(own) * time.Millisecond,
+		Opponent:  time.Duration(opponent) * time.Millisecond,
+		Increment: time.Duration(clock.IncrementMs) * time.Millisecond,
+		Delay:     time.Duration(clock.DelayMs) * time.Millisecond,
+	}
+}
+
+// Bot playing random legal moves.
+type Random struct{}
+
+func (Random) ChooseMove(ctx context.Context, pos Position, clock Clock) (Move, error) {
+	moves := pos.LegalMoves()
+	if len(moves) == 0 {
+		return "", errors.New("no legal moves")
+	}
+	return moves[rand.Intn(len(moves))], nil
+}
+
+// Bot playing perfectly in the positions covered by the tablebases,
+// leaving the others to Fallback.
+type Tablebase struct {
+	Tables   *tablebase.Set
+	Fallback Bot
+}
+
+func (tb Tablebase) ChooseMove(ctx context.Context, pos Position, clock Clock) (Move, error) {
+	bstate := pos.boardState()
+	if move, _, ok := tb.Tables.BestMove(&bstate); ok {
+		return Move(move.String()), nil
+	}
+	return tb.Fallback.ChooseMove(ctx, pos, clock)
+}
@@ -0,0 +1,140 @@
+/*
+Tests of bots played by the runner against the server's router.
+*/
+package bot
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/game_logic"
+	"github.com/matetirpak/chess-server-and-api-for-developers/internal/tablebase"
+	"github.com/matetirpak/chess-server-and-api-for-developers/pkg/client"
+	"github.com/matetirpak/chess-server-and-api-for-developers/pkg/server"
+)
+
+// Bot playing the given moves in order.
+func script(moves ...Move) Bot {
+	return Func(func(ctx context.Context, pos Position, clock Clock) (Move, error) {
+		if len(moves) == 0 {
+			return "", errors.New("out of moves")
+		}
+		move := moves[0]
+		moves = moves[1:]
+		return move, nil
+	})
+}
+
+func newRunners(t *testing.T, white Bot, black Bot) (client.Session, *Runner, *Runner) {
+	db.Games = db.NewMemoryRepository()
+	ts := httptest.NewServer(server.NewRouter())
+	t.Cleanup(ts.Close)
+	c := client.New(ts.URL)
+	session, err := c.CreateSession(context.Background(), client.SessionOptions{Name: "bots", BaseMs: 60000})
+	if err != nil {
+		t.Fatalf("fail in CreateSession: %v", err)
+	}
+	quiet := log.New(io.Discard, "", 0)
+	return session, &Runner{Client: c, Bot: white, Logger: quiet}, &Runner{Client: c, Bot: black, Logger: quiet}
+}
+
+type result struct {
+	state client.State
+	err   error
+}
+
+func play(runner *Runner, session client.Session, color string) chan result {
+	done := make(chan result, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		state, err := runner.Join(ctx, session.BoardID, session.Password, color)
+		done <- result{state, err}
+	}()
+	return done
+}
+
+func TestRunner(t *testing.T) {
+	// Fool's mate.
+	var clocks []Clock
+	blackMoves := script("e7 e5", "d8 h4")
+	black := Func(func(ctx context.Context, pos Position, clock Clock) (Move, error) {
+		clocks = append(clocks, clock)
+		if pos.Color != "b" || len(pos.LegalMoves()) == 0 {
+			t.Errorf("expected legal moves for black, got %+v", pos)
+		}
+		return blackMoves.ChooseMove(ctx, pos, clock)
+	})
+	session, whiteRunner, blackRunner := newRunners(t, script("f2 f3", "g2 g4"), black)
+	whiteDone := play(whiteRunner, session, "w")
+	blackDone := play(blackRunner, session, "b")
+
+	for _, done := range []chan result{whiteDone, blackDone} {
+		r := <-done
+		if r.err != nil {
+			t.Fatalf("fail in Join: %v", r.err)
+		}
+		if r.state.Winner != "b" || r.state.Termination != "checkmate" {
+			t.Errorf("expected black to mate, got %s by %s", r.state.Winner, r.state.Termination)
+		}
+	}
+	if len(clocks) != 2 || !clocks[0].Timed || clocks[0].Own <= 0 || clocks[0].Own > time.Minute {
+		t.Errorf("unexpected clocks %+v", clocks)
+	}
+}
+
+func TestRunnerForfeits(t *testing.T) {
+	session, whiteRunner, blackRunner := newRunners(t, script("e2 e5"), Random{})
+	whiteDone := play(whiteRunner, session, "w")
+	blackDone := play(blackRunner, session, "b")
+
+	if r := <-whiteDone; r.err == nil || client.StatusCode(r.err) != 400 {
+		t.Errorf("expected the invalid move to be returned, got %v", r.err)
+	}
+	if r := <-blackDone; r.err != nil || r.state.Winner != "b" || r.state.Termination != "forfeit" {
+		t.Errorf("expected black to win by forfeit, got %+v, %v", r.state, r.err)
+	}
+}
+
+func TestTablebaseBot(t *testing.T) {
+	if testing.Short() {
+		t.Skip("generation takes a few seconds")
+	}
+	tables := tablebase.NewSet()
+	if _, err := tables.Generate("KQK"); err != nil {
+		t.Fatalf("fail in Generate: %v", err)
+	}
+	tb := Tablebase{Tables: tables, Fallback: script("e2 e4")}
+
+	// White mates in one with Qh8.
+	var pos Position
+	for row := range pos.Board {
+		for col := range pos.Board[row] {
+			pos.Board[row][col] = game_logic.Empty
+		}
+	}
+	pos.Board[0][0] = 'X'
+	pos.Board[2][1] = 'x'
+	pos.Board[6][7] = 'q'
+	pos.BlackKingPos = [2]int{0, 0}
+	pos.WhiteKingPos = [2]int{2, 1}
+	pos.EnPassant = [2]int{-1, -1}
+	pos.Winner = "n"
+	pos.TurnColor = "w"
+	pos.Color = "w"
+	if move, err := tb.ChooseMove(context.Background(), pos, Clock{}); err != nil || move != "h2 h8" {
+		t.Errorf("expected the mate h2 h8, got %q, %v", move, err)
+	}
+
+	// Positions without a table are left to the fallback.
+	pos.Board[7][0] = 'p'
+	if move, err := tb.ChooseMove(context.Background(), pos, Clock{}); err != nil || move != "e2 e4" {
+		t.Errorf("expected the move of the fallback, got %q, %v", move, err)
+	}
+}
@@ -0,0 +1,127 @@
+/*
+Runner playing the games of a bot.
+*/
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/matetirpak/chess-server-and-api-for-developers/pkg/client"
+)
+
+type Runner struct {
+	Client *client.Client
+	Bot    Bot
+	Logger *log.Logger // log.Default() if nil.
+	// Time a single wait for the turn takes at most, one minute by
+	// default. The runner waits again until the game ends.
+	TurnTimeout time.Duration
+	// Pause before reconnecting after the server couldn't be reached,
+	// one second by default.
+	ReconnectWait time.Duration
+}
+
+// Joins a session with the color and plays it to the end.
+func (r *Runner) Join(ctx context.Context, boardID int32, password string, color string) (client.State, error) {
+	seat, err := r.Client.JoinSession(ctx, boardID, password, color)
+	if err != nil {
+		return client.State{}, err
+	}
+	r.logf("Joined game %d as %s", boardID, color)
+	return r.Play(ctx, seat)
+}
+
+// Plays a seat until the game ends and returns the final state. If
+// the bot fails or chooses an invalid move, the seat forfeits and the
+// error is returned.
+func (r *Runner) Play(ctx context.Context, seat client.Seat) (client.State, error) {
+	turnTimeout := r.TurnTimeout
+	if turnTimeout == 0 {
+		turnTimeout = time.Minute
+	}
+	for {
+		turn, err := r.Client.WaitTurn(ctx, seat, turnTimeout)
+		if errors.Is(err, client.ErrTurnTimeout) {
+			continue
+		}
+		if err != nil {
+			if r.reconnect(ctx, err) {
+				continue
+			}
+			return client.State{}, err
+		}
+
+		state, err := r.Client.State(ctx, seat)
+		if err != nil {
+			if r.reconnect(ctx, err) {
+				continue
+			}
+			return client.State{}, err
+		}
+		if state.State == "finished" || state.State == "aborted" {
+			r.logf("Game %d ended: winner %s, %s", seat.BoardID, state.Winner, state.Termination)
+			return state, nil
+		}
+		if !turn || state.TurnColor != seat.Color {
+			continue
+		}
+
+		pos := Position{State: state, BoardID: seat.BoardID, Color: seat.Color}
+		move, err := r.Bot.ChooseMove(ctx, pos, newClock(state.Clock, seat.Color))
+		if err == nil {
+			err = r.Client.Move(ctx, seat, string(move))
+			if err != nil && client.StatusCode(err) != http.StatusBadRequest && r.reconnect(ctx, err) {
+				// Waiting for the turn shows whether the move arrived.
+				continue
+			}
+			if err != nil {
+				err = fmt.Errorf("move %q: %w", move, err)
+			}
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return state, ctx.Err()
+			}
+			r.logf("Game %d: %v, forfeiting", seat.BoardID, err)
+			if forfeitErr := r.Client.Forfeit(ctx, seat); forfeitErr != nil {
+				r.logf("Game %d: failed to forfeit: %v", seat.BoardID, forfeitErr)
+			}
+			return state, err
+		}
+		r.logf("Game %d: %s played %s", seat.BoardID, seat.Color, move)
+	}
+}
+
+// Reports whether err is worth trying again, a network error or an
+// error of a proxy in front of the server, after waiting
+// ReconnectWait.
+func (r *Runner) reconnect(ctx context.Context, err error) bool {
+	status := client.StatusCode(err)
+	if ctx.Err() != nil || (status != 0 && status < http.StatusInternalServerError) {
+		return false
+	}
+	wait := r.ReconnectWait
+	if wait == 0 {
+		wait = time.Second
+	}
+	r.logf("Lost the server: %v, reconnecting in %s", err, wait)
+	select {
+	case <-time.After(wait):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (r *Runner) logf(format string, args ...interface{}) {
+	logger := r.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+	logger.Printf(format, args...)
+}
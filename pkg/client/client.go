@@ -0,0 +1,160 @@
+/*
+Go client for the REST API of the chess server. Covers sessions and
+games with typed methods, sends secrets as Bearer tokens instead of
+query parameters and turns error responses into *Error values.
+
+	c := client.New("http://localhost:8080")
+	session, err := c.CreateSession(ctx, client.SessionOptions{Name: "casual"})
+	seat, err := c.JoinSession(ctx, session.BoardID, session.Password, "w")
+	for {
+		turn, err := c.WaitTurn(ctx, seat, time.Minute)
+		...
+		err = c.Move(ctx, seat, "e2 e4")
+	}
+*/
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Prefix of all routes of the API.
+const BasePath = "/ChessServer/0.1.0"
+
+type Client struct {
+	BaseURL    string       // Scheme and host of the server, like "http://localhost:8080".
+	HTTPClient *http.Client // http.DefaultClient if nil.
+	APIKey     string       // Optional, joins sessions as the account of the key.
+	// Retries of GET and DELETE requests that didn't reach the server
+	// or got 502, 503 or 504, waiting RetryWait before the first one
+	// and twice as long before every further one. Other requests are
+	// never retried, a move could be applied twice.
+	Retries   int
+	RetryWait time.Duration
+}
+
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:   strings.TrimRight(baseURL, "/"),
+		Retries:   2,
+		RetryWait: 200 * time.Millisecond,
+	}
+}
+
+// Error response of the server.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("chess server: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Returns the HTTP status of an error response of the server, 0 for
+// other errors.
+func StatusCode(err error) int {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
+
+// Sends a request to path with body encoded as JSON, or query as
+// query parameters, and decodes the response into out unless it is
+// nil. secret is sent as Bearer token.
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, secret string, body interface{}, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	target := c.BaseURL + BasePath + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	retries := 0
+	if method == http.MethodGet || method == http.MethodDelete {
+		retries = c.Retries
+	}
+	wait := c.RetryWait
+	for attempt := 0; ; attempt++ {
+		err := c.send(ctx, method, target, secret, payload, out)
+		status := StatusCode(err)
+		retry := err != nil && ctx.Err() == nil &&
+			(status == 0 || status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout)
+		if !retry || attempt >= retries {
+			return err
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		wait *= 2
+	}
+}
+
+func (c *Client) send(ctx context.Context, method string, target string, secret string, payload []byte, out interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if secret != "" {
+		req.Header.Set("Authorization", "Bearer "+secret)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &Error{StatusCode: resp.StatusCode, Message: errorMessage(data)}
+	}
+	if out == nil || len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// Extracts the message of an error response, which is plain text or
+// a JSON object with a "message".
+func errorMessage(data []byte) string {
+	var object struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(data, &object) == nil && object.Message != "" {
+		return object.Message
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,201 @@
+/*
+Tests of the client against the server's router.
+*/
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	db "github.com/matetirpak/chess-server-and-api-for-developers/internal/database"
+	"github.com/matetirpak/chess-server-and-api-for-developers/pkg/server"
+)
+
+func newServer(t *testing.T) *Client {
+	db.Games = db.NewMemoryRepository()
+	ts := httptest.NewServer(server.NewRouter())
+	t.Cleanup(ts.Close)
+	return New(ts.URL)
+}
+
+func TestGame(t *testing.T) {
+	c := newServer(t)
+	ctx := context.Background()
+
+	session, err := c.CreateSession(ctx, SessionOptions{Name: "sdk", BaseMs: 60000})
+	if err != nil {
+		t.Fatalf("fail in CreateSession: %v", err)
+	}
+	sessions, err := c.ListSessions(ctx)
+	if err != nil || len(sessions) != 1 || sessions[0].BoardID != session.BoardID || sessions[0].State != "waiting" {
+		t.Fatalf("expected the waiting session, got %+v, %v", sessions, err)
+	}
+	if _, err := c.JoinSession(ctx, session.BoardID, "wrong", "w"); StatusCode(err) != http.StatusUnauthorized {
+		t.Errorf("expected a wrong password to be unauthorized, got %v", err)
+	}
+	white, err := c.JoinSession(ctx, session.BoardID, session.Password, "w")
+	if err != nil {
+		t.Fatalf("fail in JoinSession: %v", err)
+	}
+	black, err := c.JoinSession(ctx, session.BoardID, session.Password, "b")
+	if err != nil {
+		t.Fatalf("fail in JoinSession: %v", err)
+	}
+
+	if turn, err := c.WaitTurn(ctx, white, time.Second); !turn || err != nil {
+		t.Fatalf("expected white's turn, got %v, %v", turn, err)
+	}
+	if _, err := c.WaitTurn(ctx, black, 10*time.Millisecond); !errors.Is(err, ErrTurnTimeout) {
+		t.Errorf("expected black to time out, got %v", err)
+	}
+	if err := c.Move(ctx, white, "e2 e5"); StatusCode(err) != http.StatusBadRequest {
+		t.Errorf("expected an invalid move to be rejected, got %v", err)
+	} else if apiErr := (*Error)(nil); !errors.As(err, &apiErr) || apiErr.Message == "" {
+		t.Errorf("expected the message of the server, got %v", err)
+	}
+	if err := c.Move(ctx, white, "e2 e4"); err != nil {
+		t.Fatalf("fail in Move: %v", err)
+	}
+
+	state, err := c.State(ctx, black)
+	if err != nil {
+		t.Fatalf("fail in State: %v", err)
+	}
+	if state.TurnColor != "b" || state.LastMove == nil || state.LastMove.Move != "e2 e4" || state.Clock == nil || state.Board[4][4] != 'p' {
+		t.Errorf("unexpected state %+v", state)
+	}
+	initial, err := c.StateAt(ctx, black, 0)
+	if err != nil || initial.Board[6][4] != 'p' || initial.LastMove != nil {
+		t.Errorf("expected the initial position, got %+v, %v", initial, err)
+	}
+
+	// A cancelled context stops waiting.
+	waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.WaitTurn(waitCtx, white, time.Minute); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline of the context, got %v", err)
+	}
+
+	if err := c.Forfeit(ctx, black); err != nil {
+		t.Fatalf("fail in Forfeit: %v", err)
+	}
+	if turn, err := c.WaitTurn(ctx, white, time.Second); turn || err != nil {
+		t.Errorf("expected the game to be over, got %v, %v", turn, err)
+	}
+	if state, _ := c.State(ctx, white); state.State != "finished" || state.Winner != "w" || state.Termination != "forfeit" {
+		t.Errorf("expected white to win by forfeit, got %+v", state)
+	}
+
+	if err := c.DeleteSession(ctx, session.BoardID, session.Password); err != nil {
+		t.Fatalf("fail in DeleteSession: %v", err)
+	}
+	if _, err := c.State(ctx, white); StatusCode(err) != http.StatusNotFound {
+		t.Errorf("expected the session to be gone, got %v", err)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	db.Games = db.NewMemoryRepository()
+	router := server.NewRouter()
+	var failures atomic.Int32
+	failures.Store(2)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failures.Add(-1) >= 0 {
+			http.Error(w, "Try again", http.StatusServiceUnavailable)
+			return
+		}
+		router.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+	c := New(ts.URL)
+	c.RetryWait = time.Millisecond
+	ctx := context.Background()
+
+	if _, err := c.ListSessions(ctx); err != nil {
+		t.Errorf("expected GET to succeed after 2 retries, got %v", err)
+	}
+
+	// Requests that change the game aren't retried.
+	failures.Store(1)
+	if _, err := c.CreateSession(ctx, SessionOptions{Name: "once"}); StatusCode(err) != http.StatusServiceUnavailable {
+		t.Errorf("expected POST to fail without a retry, got %v", err)
+	}
+	c.Retries = 0
+	failures.Store(1)
+	if _, err := c.ListSessions(ctx); StatusCode(err) != http.StatusServiceUnavailable {
+		t.Errorf("expected no retry, got %v", err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	c := newServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	session, err := c.CreateSession(ctx, SessionOptions{Name: "watched"})
+	if err != nil {
+		t.Fatalf("fail in CreateSession: %v", err)
+	}
+	white, err := c.JoinSession(ctx, session.BoardID, session.Password, "w")
+	if err != nil {
+		t.Fatalf("fail in JoinSession: %v", err)
+	}
+	black, err := c.JoinSession(ctx, session.BoardID, session.Password, "b")
+	if err != nil {
+		t.Fatalf("fail in JoinSession: %v", err)
+	}
+
+	events := make(chan Event, 16)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Watch(ctx, session.BoardID, session.Password, func(event Event) error {
+			events <- event
+			if event.Type == "gameover" {
+				return errors.New("over")
+			}
+			return nil
+		})
+	}()
+	if event := <-events; event.Type != "state" || event.State == nil {
+		t.Fatalf("expected the feed to start with the state, got %+v", event)
+	}
+
+	if err := c.Move(ctx, white, "e2 e4"); err != nil {
+		t.Fatalf("fail in Move: %v", err)
+	}
+	if err := c.Forfeit(ctx, black); err != nil {
+		t.Fatalf("fail in Forfeit: %v", err)
+	}
+	var types []string
+	for event := range events {
+		types = append(types, event.Type)
+		if event.Type == "move" && (event.Move != "e2 e4" || event.Moveidx != 1) {
+			t.Errorf("unexpected move event %+v", event)
+		}
+		if event.Type == "gameover" {
+			if event.State == nil || event.State.Winner != "w" {
+				t.Errorf("expected white to win, got %+v", event.State)
+			}
+			break
+		}
+	}
+	if err := <-done; err == nil || err.Error() != "over" {
+		t.Errorf("expected the error of the handler, got %v", err)
+	}
+	if types[0] != "move" {
+		t.Errorf("expected the move first, got %v", types)
+	}
+
+	moves, err := c.History(ctx, black)
+	if err != nil || len(moves) != 1 || moves[0].Move != "e2 e4" || moves[0].Color != "w" {
+		t.Errorf("unexpected history %+v, %v", moves, err)
+	}
+	if err := c.Watch(ctx, session.BoardID, "wrong", nil); StatusCode(err) != http.StatusUnauthorized {
+		t.Errorf("expected a wrong password to be unauthorized, got %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Following games with the Server-Sent Events of GameEvents.
+*/
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Event of a game: "state" when the feed opens, "joined", "started",
+// "move", "clock", "drawoffer", "drawdeclined", "gameover" and
+// "aborted", and "deleted", "expired" or "archived" once the game is
+// removed.
+type Event struct {
+	Type    string `json:"type"`
+	Color   string `json:"color,omitempty"`
+	Move    string `json:"move,omitempty"`
+	Moveidx int    `json:"moveidx"`
+	State   *State `json:"state,omitempty"`
+	Clock   *Clock `json:"clock,omitempty"`
+}
+
+// Reports whether no events follow the event.
+func (e Event) Final() bool {
+	switch e.Type {
+	case "deleted", "expired", "archived":
+		return true
+	}
+	return false
+}
+
+// Calls handle with the events of a game until the server closes the
+// feed, ctx is done or handle returns an error, which is returned.
+// secret is the password of the session or the token of a seat.
+func (c *Client) Watch(ctx context.Context, boardID int32, secret string, handle func(Event) error) error {
+	query := url.Values{"boardid": {strconv.Itoa(int(boardID))}}
+	target := c.BaseURL + BasePath + "/game/events?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if secret != "" {
+		req.Header.Set("Authorization", "Bearer "+secret)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		return &Error{StatusCode: resp.StatusCode, Message: errorMessage(data)}
+	}
+
+	// Only the data lines matter, the event name and id repeat the
+	// type and move index of the payload.
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, found := strings.CutPrefix(scanner.Text(), "data: ")
+		if !found {
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return err
+		}
+		if err := handle(event); err != nil {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return scanner.Err()
+}
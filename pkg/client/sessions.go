@@ -0,0 +1,212 @@
+/*
+Methods for sessions and games, and the types of their requests and
+responses. The JSON tags follow the server.
+*/
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Time limits of a new session, all optional. See PostSessions.
+type SessionOptions struct {
+	Name          string `json:"name"`
+	BaseMs        int64  `json:"basems,omitempty"`
+	IncrementMs   int64  `json:"incrementms,omitempty"`
+	DelayMs       int64  `json:"delayms,omitempty"`
+	DelayMode     string `json:"delaymode,omitempty"` // "simple" or "bronstein"
+	MoveTimeMs    int64  `json:"movetimems,omitempty"`
+	OnMoveTimeout string `json:"onmovetimeout,omitempty"` // "forfeit" or "random"
+}
+
+type Session struct {
+	BoardID  int32  `json:"boardid"`
+	Password string `json:"password"`
+}
+
+type SessionInfo struct {
+	Name    string     `json:"name"`
+	BoardID int32      `json:"boardid"`
+	State   string     `json:"state"` // "waiting", "active", "finished" or "aborted"
+	Created time.Time  `json:"created"`
+	Started *time.Time `json:"started,omitempty"`
+	Ended   *time.Time `json:"ended,omitempty"`
+	White   string     `json:"white,omitempty"` // Account names, empty if anonymous.
+	Black   string     `json:"black,omitempty"`
+}
+
+// Color taken in a game, with the token that plays it.
+type Seat struct {
+	BoardID int32
+	Color   string // "w" or "b"
+	Token   string
+}
+
+// Position and lifecycle of a game. The board holds the pieces like
+// the server: lower case for white, upper case for black, 'k' for
+// knights and 'x' for kings.
+type State struct {
+	Board          [8][8]rune  `json:"board"`
+	WhiteKingPos   [2]int      `json:"whitekingpos"`
+	BlackKingPos   [2]int      `json:"blackkingpos"`
+	WhiteKingMoved bool        `json:"whitekingmoved"`
+	BlackKingMoved bool        `json:"blackkingmoved"`
+	Winner         string      `json:"winner"` // "n" while playing, "w", "b" or "r" for a draw.
+	TurnColor      string      `json:"turncolor"`
+	EnPassant      [2]int      `json:"enpassant"`
+	State          string      `json:"state"`
+	Termination    string      `json:"termination,omitempty"`
+	DrawOffer      string      `json:"drawoffer,omitempty"`
+	Clock          *Clock      `json:"clock,omitempty"`
+	LastMove       *MoveRecord `json:"lastmove,omitempty"`
+}
+
+type Clock struct {
+	WhiteMs     int64  `json:"whitems"`
+	BlackMs     int64  `json:"blackms"`
+	IncrementMs int64  `json:"incrementms"`
+	DelayMs     int64  `json:"delayms"`
+	DelayMode   string `json:"delaymode,omitempty"`
+	Running     bool   `json:"running"`
+}
+
+type MoveRecord struct {
+	Color    string `json:"color"`
+	Move     string `json:"move"`
+	ThinkMs  int64  `json:"thinkms"`
+	Fallback bool   `json:"fallback,omitempty"`
+	Opening  bool   `json:"opening,omitempty"`
+}
+
+// Body of PutGame.
+type putGame struct {
+	BoardID int32  `json:"boardid"`
+	Color   string `json:"color"`
+	Move    string `json:"move,omitempty"`
+	Forfeit bool   `json:"forfeit,omitempty"`
+	Draw    string `json:"draw,omitempty"`
+}
+
+// Creates a session and returns its ID and password.
+func (c *Client) CreateSession(ctx context.Context, options SessionOptions) (Session, error) {
+	var session Session
+	err := c.do(ctx, http.MethodPost, "/sessions", nil, "", options, &session)
+	return session, err
+}
+
+func (c *Client) ListSessions(ctx context.Context) ([]SessionInfo, error) {
+	var resp struct {
+		Games []SessionInfo `json:"games"`
+	}
+	err := c.do(ctx, http.MethodGet, "/sessions", nil, "", nil, &resp)
+	return resp.Games, err
+}
+
+// Takes a color of a session. With an API key, the account of the
+// key plays it.
+func (c *Client) JoinSession(ctx context.Context, boardID int32, password string, color string) (Seat, error) {
+	req := struct {
+		BoardID int32  `json:"boardid"`
+		Color   string `json:"color"`
+		APIKey  string `json:"apikey,omitempty"`
+	}{boardID, color, c.APIKey}
+	var resp struct {
+		Token string `json:"token"`
+	}
+	err := c.do(ctx, http.MethodPut, "/sessions", nil, password, req, &resp)
+	return Seat{BoardID: boardID, Color: color, Token: resp.Token}, err
+}
+
+func (c *Client) DeleteSession(ctx context.Context, boardID int32, password string) error {
+	req := struct {
+		BoardID int32 `json:"boardid"`
+	}{boardID}
+	return c.do(ctx, http.MethodDelete, "/sessions", nil, password, req, nil)
+}
+
+// Returns the current state of the game of a seat.
+func (c *Client) State(ctx context.Context, seat Seat) (State, error) {
+	return c.StateAt(ctx, seat, -1)
+}
+
+// Returns the state after moveidx moves, -1 for the current one.
+func (c *Client) StateAt(ctx context.Context, seat Seat, moveidx int) (State, error) {
+	query := url.Values{
+		"boardid":  {strconv.Itoa(int(seat.BoardID))},
+		"color":    {seat.Color},
+		"statereq": {"true"},
+		"moveidx":  {strconv.Itoa(moveidx)},
+	}
+	var state State
+	err := c.do(ctx, http.MethodGet, "/game", query, seat.Token, nil, &state)
+	return state, err
+}
+
+// Returns the moves played so far.
+func (c *Client) History(ctx context.Context, seat Seat) ([]MoveRecord, error) {
+	query := url.Values{
+		"boardid": {strconv.Itoa(int(seat.BoardID))},
+		"color":   {seat.Color},
+	}
+	var resp struct {
+		Moves []MoveRecord `json:"moves"`
+	}
+	err := c.do(ctx, http.MethodGet, "/game/history", query, seat.Token, nil, &resp)
+	return resp.Moves, err
+}
+
+// Returned by WaitTurn if the server gave up waiting.
+var ErrTurnTimeout = errors.New("chess server: timeout waiting for the turn")
+
+// Waits up to timeout, at most 10 minutes, until it is the turn of
+// the seat. Returns true then, and false once the game ended.
+func (c *Client) WaitTurn(ctx context.Context, seat Seat, timeout time.Duration) (bool, error) {
+	query := url.Values{
+		"boardid":   {strconv.Itoa(int(seat.BoardID))},
+		"color":     {seat.Color},
+		"turnreq":   {"true"},
+		"timeoutms": {strconv.FormatInt(timeout.Milliseconds(), 10)},
+	}
+	var resp struct {
+		Message string `json:"message"`
+	}
+	err := c.do(ctx, http.MethodGet, "/game", query, seat.Token, nil, &resp)
+	switch {
+	case StatusCode(err) == http.StatusRequestTimeout:
+		return false, ErrTurnTimeout
+	case err != nil:
+		return false, err
+	case resp.Message == "It's your turn!":
+		return true, nil
+	case resp.Message == "Game has ended.":
+		return false, nil
+	}
+	return false, fmt.Errorf("chess server: unexpected answer %q", resp.Message)
+}
+
+// Plays a move like "e2 e4".
+func (c *Client) Move(ctx context.Context, seat Seat, move string) error {
+	return c.putGame(ctx, seat, putGame{Move: move})
+}
+
+func (c *Client) Forfeit(ctx context.Context, seat Seat) error {
+	return c.putGame(ctx, seat, putGame{Forfeit: true})
+}
+
+// Offers, accepts or declines a draw: action is "offer", "accept" or
+// "decline".
+func (c *Client) Draw(ctx context.Context, seat Seat, action string) error {
+	return c.putGame(ctx, seat, putGame{Draw: action})
+}
+
+func (c *Client) putGame(ctx context.Context, seat Seat, req putGame) error {
+	req.BoardID = seat.BoardID
+	req.Color = seat.Color
+	return c.do(ctx, http.MethodPut, "/game", nil, seat.Token, req, nil)
+}
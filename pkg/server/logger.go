@@ -3,9 +3,13 @@ package server
 import (
 	"log"
 	"net/http"
+	"net/url"
 	"time"
 )
 
+// Query parameters holding secrets, left out of the log.
+var secretParams = []string{"password", "token"}
+
 func Logger(inner http.Handler, name string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -15,9 +19,26 @@ func Logger(inner http.Handler, name string) http.Handler {
 		log.Printf(
 			"%s %s %s %s",
 			r.Method,
-			r.RequestURI,
+			redactURI(r.URL),
 			name,
 			time.Since(start),
 		)
 	})
 }
+
+// Returns the request URI with the values of secret query
+// parameters replaced.
+func redactURI(u *url.URL) string {
+	query := u.Query()
+	redacted := false
+	for _, param := range secretParams {
+		if query.Has(param) {
+			query.Set(param, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return u.RequestURI()
+	}
+	return u.EscapedPath() + "?" + query.Encode()
+}
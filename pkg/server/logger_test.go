@@ -0,0 +1,23 @@
+package server
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRedactURI(t *testing.T) {
+	u, _ := url.Parse("/ChessServer/0.1.0/game?boardid=1&password=secret&color=w&token=abc")
+	logged := redactURI(u)
+	if strings.Contains(logged, "secret") || strings.Contains(logged, "abc") {
+		t.Errorf("secrets weren't redacted: %s", logged)
+	}
+	if !strings.Contains(logged, "boardid=1") || !strings.Contains(logged, "color=w") {
+		t.Errorf("expected the other parameters to be kept: %s", logged)
+	}
+
+	u, _ = url.Parse("/ChessServer/0.1.0/sessions")
+	if logged := redactURI(u); logged != "/ChessServer/0.1.0/sessions" {
+		t.Errorf("expected the URI to stay unchanged, got %s", logged)
+	}
+}
@@ -70,6 +70,168 @@ var routes = Routes{
 		api.PutSessions,
 	},
 
+	// Accounts
+	Route{
+		"GetAccounts",
+		strings.ToUpper("Get"),
+		"/ChessServer/0.1.0/accounts",
+		api.GetAccounts,
+	},
+
+	Route{
+		"PostAccounts",
+		strings.ToUpper("Post"),
+		"/ChessServer/0.1.0/accounts",
+		api.PostAccounts,
+	},
+
+	Route{
+		"GetAccountKeys",
+		strings.ToUpper("Get"),
+		"/ChessServer/0.1.0/accounts/keys",
+		api.GetAccountKeys,
+	},
+
+	Route{
+		"PostAccountKeys",
+		strings.ToUpper("Post"),
+		"/ChessServer/0.1.0/accounts/keys",
+		api.PostAccountKeys,
+	},
+
+	Route{
+		"DeleteAccountKeys",
+		strings.ToUpper("Delete"),
+		"/ChessServer/0.1.0/accounts/keys",
+		api.DeleteAccountKeys,
+	},
+
+	Route{
+		"GetRating",
+		strings.ToUpper("Get"),
+		"/ChessServer/0.1.0/accounts/rating",
+		api.GetRating,
+	},
+
+	Route{
+		"GetRatingHistory",
+		strings.ToUpper("Get"),
+		"/ChessServer/0.1.0/accounts/rating/history",
+		api.GetRatingHistory,
+	},
+
+	Route{
+		"GetPrediction",
+		strings.ToUpper("Get"),
+		"/ChessServer/0.1.0/accounts/rating/predict",
+		api.GetPrediction,
+	},
+
+	Route{
+		"GetStats",
+		strings.ToUpper("Get"),
+		"/ChessServer/0.1.0/accounts/stats",
+		api.GetStats,
+	},
+
+	Route{
+		"GetHeadToHead",
+		strings.ToUpper("Get"),
+		"/ChessServer/0.1.0/accounts/headtohead",
+		api.GetHeadToHead,
+	},
+
+	Route{
+		"GetLeaderboard",
+		strings.ToUpper("Get"),
+		"/ChessServer/0.1.0/leaderboard",
+		api.GetLeaderboard,
+	},
+
+	Route{
+		"PostMatchmaking",
+		strings.ToUpper("Post"),
+		"/ChessServer/0.1.0/matchmaking",
+		api.PostMatchmaking,
+	},
+
+	Route{
+		"GetTournaments",
+		strings.ToUpper("Get"),
+		"/ChessServer/0.1.0/tournaments",
+		api.GetTournaments,
+	},
+
+	Route{
+		"PostTournaments",
+		strings.ToUpper("Post"),
+		"/ChessServer/0.1.0/tournaments",
+		api.PostTournaments,
+	},
+
+	Route{
+		"PutTournaments",
+		strings.ToUpper("Put"),
+		"/ChessServer/0.1.0/tournaments",
+		api.PutTournaments,
+	},
+
+	Route{
+		"PostTournamentStart",
+		strings.ToUpper("Post"),
+		"/ChessServer/0.1.0/tournaments/start",
+		api.PostTournamentStart,
+	},
+
+	Route{
+		"GetTournamentStandings",
+		strings.ToUpper("Get"),
+		"/ChessServer/0.1.0/tournaments/standings",
+		api.GetTournamentStandings,
+	},
+
+	Route{
+		"GetTournamentPairings",
+		strings.ToUpper("Get"),
+		"/ChessServer/0.1.0/tournaments/pairings",
+		api.GetTournamentPairings,
+	},
+
+	Route{
+		"GetTournamentCrosstable",
+		strings.ToUpper("Get"),
+		"/ChessServer/0.1.0/tournaments/crosstable",
+		api.GetTournamentCrosstable,
+	},
+
+	Route{
+		"GetTournamentBracket",
+		strings.ToUpper("Get"),
+		"/ChessServer/0.1.0/tournaments/bracket",
+		api.GetTournamentBracket,
+	},
+
+	Route{
+		"GetSPRT",
+		strings.ToUpper("Get"),
+		"/ChessServer/0.1.0/sprt",
+		api.GetSPRT,
+	},
+
+	Route{
+		"PostSPRT",
+		strings.ToUpper("Post"),
+		"/ChessServer/0.1.0/sprt",
+		api.PostSPRT,
+	},
+
+	Route{
+		"DeleteSPRT",
+		strings.ToUpper("Delete"),
+		"/ChessServer/0.1.0/sprt",
+		api.DeleteSPRT,
+	},
+
 	// Game
 	Route{
 		"GetGame",
@@ -78,6 +240,34 @@ var routes = Routes{
 		api.GetGame,
 	},
 
+	Route{
+		"GetHistory",
+		strings.ToUpper("Get"),
+		"/ChessServer/0.1.0/game/history",
+		api.GetHistory,
+	},
+
+	Route{
+		"GetJournal",
+		strings.ToUpper("Get"),
+		"/ChessServer/0.1.0/game/journal",
+		api.GetJournal,
+	},
+
+	Route{
+		"GameStream",
+		strings.ToUpper("Get"),
+		"/ChessServer/0.1.0/game/ws",
+		api.GameStream,
+	},
+
+	Route{
+		"GameEvents",
+		strings.ToUpper("Get"),
+		"/ChessServer/0.1.0/game/events",
+		api.GameEvents,
+	},
+
 	Route{
 		"PutGame",
 		strings.ToUpper("Put"),